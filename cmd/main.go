@@ -1,24 +1,38 @@
 package main
 
 import (
+	"context"
 	"log"
+	"ticketing/internal/apperr"
 	"ticketing/internal/broker"
 	"ticketing/internal/database"
+	"ticketing/internal/jobs"
+	"ticketing/internal/media"
+	"ticketing/internal/oidc"
 	"ticketing/internal/queue"
 	"ticketing/internal/router"
+	"ticketing/internal/scheduler"
+	"ticketing/internal/storage"
 	"ticketing/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 
 	"sync"
+	_ "time/tzdata" // embed the IANA database so event time zones resolve in minimal images
 )
 
 // Starts API server
 func startAPI(wg *sync.WaitGroup) {
 	defer wg.Done()
-	app := fiber.New()
+	// Leave room for multipart overhead around the largest image upload. Other
+	// routes are held to the default limit by middleware.BodyLimit.
+	app := fiber.New(fiber.Config{
+		BodyLimit:    media.MaxUploadSize + 1<<20,
+		ErrorHandler: apperr.Handler, // Render every error as problem+json
+	})
 	utils.InitRedis()
 
 	db, err := database.New()
@@ -26,13 +40,27 @@ func startAPI(wg *sync.WaitGroup) {
 		log.Fatalf("could not connect to the database: %v", err)
 	}
 
+	utils.InitKeys(db)
+	go utils.Keys.Run(context.Background())
+
 	queueService, err := queue.NewQueueService()
 	if err != nil {
 		log.Fatalf("could not init the queue service: %v", err)
 	}
 
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("could not init the media storage: %v", err)
+	}
+
+	sso, err := oidc.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("could not init the OIDC provider: %v", err)
+	}
+
 	app.Use(cors.New())
-	router.RegisterRoutes(app, db, *queueService)
+	app.Use(requestid.New())
+	router.RegisterRoutes(app, db, *queueService, store, sso)
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
 	log.Fatal(app.Listen(":3000"))
@@ -52,6 +80,10 @@ func startWorker(wg *sync.WaitGroup) {
 		log.Fatalf("Failed to initialize RabbitMQ: %v", err)
 	}
 
+	sched := scheduler.New(db, scheduler.SystemClock{})
+	jobs.Register(sched, db)
+	go sched.Run(context.Background())
+
 	log.Println("Worker started, listening for ticket booking requests...")
 	broker.ConsumeBookingRequests(db)
 }
@@ -0,0 +1,152 @@
+// Package access decides what a user may do on the platform and with each
+// event. Handlers ask it instead of comparing Event.UserID themselves, so
+// team roles and the admin role apply everywhere the owner's rights do.
+package access
+
+import (
+	"errors"
+	"ticketing/internal/database"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Permission is an action on the platform or on an event.
+type Permission string
+
+// Subject is the user a decision is made for.
+type Subject struct {
+	UserID        string
+	Role          string // Platform role, see database.Role constants
+	EmailVerified bool   // Whether the user confirmed their email when the token was issued
+	MFAPending    bool   // Whether the policy required 2FA the user had not enabled; Role is then attendee
+}
+
+// SubjectFromClaims reads the subject from verified token claims. Tokens
+// issued before roles existed carry no role and are treated as attendees.
+// Tokens without email_verified are treated as unverified. Users who still
+// have to enable 2FA under the security policy only get attendee rights.
+func SubjectFromClaims(claims jwt.MapClaims) (Subject, error) {
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return Subject{}, errors.New("user_id claim not found or invalid")
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = database.RoleAttendee
+	}
+	verified, _ := claims["email_verified"].(bool)
+	pending, _ := claims["mfa_pending"].(bool)
+	if pending {
+		role = database.RoleAttendee
+	}
+	return Subject{UserID: userID, Role: role, EmailVerified: verified, MFAPending: pending}, nil
+}
+
+// Platform permissions, granted by the user's role.
+const (
+	CreateEvents     Permission = "create_events"     // Create events, series, venues and templates
+	ManageCategories Permission = "manage_categories" // Edit the category taxonomy
+	ManageUsers      Permission = "manage_users"      // List users, change roles, decide organizer requests
+	ManageAnyEvent   Permission = "manage_any_event"  // Act as the owner of every event
+)
+
+// platformPermissions lists what each platform role may do.
+var platformPermissions = map[string][]Permission{
+	database.RoleAttendee:  {},
+	database.RoleOrganizer: {CreateEvents},
+	database.RoleAdmin:     {CreateEvents, ManageCategories, ManageUsers, ManageAnyEvent},
+}
+
+// RoleCan reports whether a platform role grants perm.
+func RoleCan(role string, perm Permission) bool {
+	for _, p := range platformPermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope is what an API key may be used for, on top of the permissions of
+// the user who created it.
+type Scope string
+
+// API key scopes. Keys can only call routes that name one of their scopes.
+const (
+	ScopeEventsRead   Scope = "events:read"   // List and read events
+	ScopeEventsWrite  Scope = "events:write"  // Create, edit and publish events
+	ScopeTicketsRead  Scope = "tickets:read"  // List an event's attendees
+	ScopeCheckinWrite Scope = "checkin:write" // Check attendees in
+)
+
+// Event permissions.
+const (
+	EditEvent     Permission = "edit_event"     // Change details, schedule, status and images
+	ViewAttendees Permission = "view_attendees" // List ticket holders
+	IssueRefunds  Permission = "issue_refunds"  // Refund or cancel tickets
+	ScanTickets   Permission = "scan_tickets"   // Check attendees in
+	ManageEvent   Permission = "manage_event"   // Owner only: manage the team, cancel and delete
+)
+
+// rolePermissions lists what each team role may do.
+var rolePermissions = map[string][]Permission{
+	database.TeamRoleCoOrganizer: {EditEvent, ViewAttendees, IssueRefunds, ScanTickets},
+	database.TeamRoleBoxOffice:   {ViewAttendees, IssueRefunds},
+	database.TeamRoleCheckIn:     {ViewAttendees, ScanTickets},
+}
+
+// ownerPermissions is every permission; the owner holds them all.
+var ownerPermissions = []Permission{EditEvent, ViewAttendees, IssueRefunds, ScanTickets, ManageEvent}
+
+// RolePermissions returns the permissions granted by a team role.
+func RolePermissions(role string) []Permission {
+	return rolePermissions[role]
+}
+
+// Permissions returns what the subject may do with event: everything for
+// the owner and admins, the team role's permissions for accepted team
+// members, nothing otherwise.
+func Permissions(db database.Service, event *database.Event, subject Subject) ([]Permission, error) {
+	if subject.UserID == "" || subject.MFAPending {
+		return nil, nil
+	}
+	if event.UserID == subject.UserID || RoleCan(subject.Role, ManageAnyEvent) {
+		return ownerPermissions, nil
+	}
+
+	member, err := db.GetEventMember(event.EventID, subject.UserID)
+	if err != nil {
+		if errors.Is(err, database.ErrMemberNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !member.Active() {
+		return nil, nil
+	}
+	return rolePermissions[member.Role], nil
+}
+
+// Can reports whether the subject holds perm on event.
+func Can(db database.Service, event *database.Event, subject Subject, perm Permission) (bool, error) {
+	perms, err := Permissions(db, event, subject)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range perms {
+		if p == perm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CanView reports whether the subject may see event. Drafts are visible to
+// the owner, the event team and admins only.
+func CanView(db database.Service, event *database.Event, subject Subject) (bool, error) {
+	if event.VisibleTo(subject.UserID) {
+		return true, nil
+	}
+	perms, err := Permissions(db, event, subject)
+	return len(perms) > 0, err
+}
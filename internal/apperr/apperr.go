@@ -0,0 +1,209 @@
+// Package apperr defines the error handlers return for failed requests and
+// renders it as an RFC 7807 problem document. Sentinel errors from the
+// domain packages are mapped to statuses and codes in one place, so
+// handlers can return them unchanged.
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Generic error codes. Domain errors use more specific codes, see sentinels.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodePayloadTooLarge    = "payload_too_large"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+// Error is a failed request: the HTTP status, a stable machine-readable
+// code and a human-readable detail.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Extra  map[string]interface{} // Extension members added to the problem document
+	Err    error                  // Underlying cause; logged for 5xx, never rendered
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With returns a copy of e with an extension member added.
+func (e *Error) With(key string, value interface{}) *Error {
+	c := *e
+	c.Extra = make(map[string]interface{}, len(e.Extra)+1)
+	for k, v := range e.Extra {
+		c.Extra[k] = v
+	}
+	c.Extra[key] = value
+	return &c
+}
+
+// New creates an error with the given status, code and detail.
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// BadRequest reports a request that cannot be processed as sent.
+func BadRequest(code, detail string) *Error {
+	return New(fiber.StatusBadRequest, code, detail)
+}
+
+// Unauthorized reports a missing or invalid credential.
+func Unauthorized(detail string) *Error {
+	return New(fiber.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+// Forbidden reports an authenticated caller lacking permission.
+func Forbidden(code, detail string) *Error {
+	return New(fiber.StatusForbidden, code, detail)
+}
+
+// NotFound reports a missing resource.
+func NotFound(code, detail string) *Error {
+	return New(fiber.StatusNotFound, code, detail)
+}
+
+// Conflict reports a request that clashes with the resource's state.
+func Conflict(code, detail string) *Error {
+	return New(fiber.StatusConflict, code, detail)
+}
+
+// Internal reports an unexpected failure. The cause is logged, not shown.
+func Internal(detail string, cause error) *Error {
+	return &Error{Status: fiber.StatusInternalServerError, Code: CodeInternal, Detail: detail, Err: cause}
+}
+
+// Invalid maps err like From but reports it as 400. Use it for resources
+// referenced from a request body, where "not found" means a bad request.
+func Invalid(err error) *Error {
+	e := *From(err)
+	if e.Status >= fiber.StatusInternalServerError {
+		return &e
+	}
+	e.Status = fiber.StatusBadRequest
+	return &e
+}
+
+// From converts any error into an *Error: errors that already are one are
+// returned as is, known sentinels get their status and code, and anything
+// else becomes a 500.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return New(fe.Code, codeForStatus(fe.Code), fe.Message)
+	}
+
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return &Error{Status: s.status, Code: s.code, Detail: err.Error(), Err: err}
+		}
+	}
+	if e := fromValidation(err); e != nil {
+		return e
+	}
+
+	return Internal("An unexpected error occurred", err)
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeInvalidRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case fiber.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	}
+	if status >= fiber.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// problem is an RFC 7807 problem document.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Handler is the Fiber ErrorHandler. It renders every error returned by a
+// handler or middleware as application/problem+json.
+func Handler(c *fiber.Ctx, err error) error {
+	e := From(err)
+	requestID, _ := c.Locals("requestid").(string)
+
+	if e.Status >= fiber.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", requestID, c.Method(), c.Path(), e)
+	}
+
+	doc := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  c.Path(),
+		Code:      e.Code,
+		RequestID: requestID,
+	}
+
+	body, merr := json.Marshal(doc)
+	if merr == nil && len(e.Extra) > 0 {
+		// Extension members sit next to the standard ones
+		var members map[string]interface{}
+		if merr = json.Unmarshal(body, &members); merr == nil {
+			for k, v := range e.Extra {
+				if _, taken := members[k]; !taken {
+					members[k] = v
+				}
+			}
+			body, merr = json.Marshal(members)
+		}
+	}
+	if merr != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(http.StatusText(fiber.StatusInternalServerError))
+	}
+
+	c.Set(fiber.HeaderContentType, "application/problem+json")
+	return c.Status(e.Status).Send(body)
+}
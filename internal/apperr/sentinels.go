@@ -0,0 +1,105 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+	"ticketing/internal/database"
+	"ticketing/internal/keys"
+	"ticketing/internal/media"
+	"ticketing/internal/recurrence"
+	"ticketing/internal/storage"
+	"ticketing/internal/utils"
+	"ticketing/internal/validation"
+)
+
+// sentinels maps domain errors to their HTTP status and code. The error's
+// message, including any context wrapped around the sentinel, is used as the
+// detail, so it must be safe to show to clients.
+var sentinels = []struct {
+	err    error
+	status int
+	code   string
+}{
+	// Events and schedules
+	{database.ErrEventNotFound, http.StatusNotFound, "event_not_found"},
+	{database.ErrEventHasTickets, http.StatusConflict, "event_has_tickets"},
+	{database.ErrEventNotOnSale, http.StatusConflict, "event_not_on_sale"},
+	{database.ErrEventInPast, http.StatusConflict, "event_ended"},
+	{database.ErrInvalidTransition, http.StatusConflict, "invalid_transition"},
+	{database.ErrVersionConflict, http.StatusPreconditionFailed, "version_conflict"},
+	{database.ErrCapacityBelowSold, http.StatusConflict, "capacity_below_sold"},
+	{database.ErrScheduleMissing, http.StatusBadRequest, "schedule_missing"},
+	{database.ErrScheduleOrder, http.StatusBadRequest, "schedule_order"},
+	{database.ErrDoorsAfterStart, http.StatusBadRequest, "doors_after_start"},
+	{database.ErrInvalidTimeZone, http.StatusBadRequest, "invalid_time_zone"},
+	{database.ErrStartsInPast, http.StatusBadRequest, "starts_in_past"},
+	{database.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{database.ErrInvalidSort, http.StatusBadRequest, "invalid_sort"},
+	{database.ErrVenueNotFound, http.StatusNotFound, "venue_not_found"},
+	{database.ErrDetailsSchemaNotFound, http.StatusNotFound, "details_schema_not_found"},
+
+	// Tickets
+	{database.ErrTicketNotFound, http.StatusNotFound, "ticket_not_found"},
+	{database.ErrTicketCancelled, http.StatusConflict, "ticket_cancelled"},
+	{database.ErrTicketCheckedIn, http.StatusConflict, "ticket_checked_in"},
+
+	// Series and templates
+	{database.ErrSeriesNotFound, http.StatusNotFound, "series_not_found"},
+	{database.ErrNotSeriesMember, http.StatusNotFound, "not_series_member"},
+	{database.ErrNoOccurrences, http.StatusBadRequest, "no_occurrences"},
+	{recurrence.ErrInvalidRule, http.StatusBadRequest, "invalid_recurrence_rule"},
+	{database.ErrTemplateNotFound, http.StatusNotFound, "template_not_found"},
+
+	// Taxonomy
+	{database.ErrCategoryNotFound, http.StatusNotFound, "category_not_found"},
+	{database.ErrCategoryExists, http.StatusConflict, "category_exists"},
+	{database.ErrCollectionNotFound, http.StatusNotFound, "collection_not_found"},
+	{database.ErrCollectionExists, http.StatusConflict, "collection_exists"},
+	{database.ErrInvalidSlug, http.StatusBadRequest, "invalid_slug"},
+
+	// Media
+	{database.ErrMediaNotFound, http.StatusNotFound, "media_not_found"},
+	{database.ErrInvalidMediaKind, http.StatusBadRequest, "invalid_media_kind"},
+	{storage.ErrNotFound, http.StatusNotFound, "media_not_found"},
+	{media.ErrTooLarge, http.StatusRequestEntityTooLarge, "image_too_large"},
+	{media.ErrUnsupportedType, http.StatusBadRequest, "unsupported_image_type"},
+	{media.ErrTooManyPixels, http.StatusBadRequest, "image_too_many_pixels"},
+	{media.ErrCorrupt, http.StatusBadRequest, "image_corrupt"},
+
+	// Teams and users
+	{database.ErrMemberNotFound, http.StatusNotFound, "member_not_found"},
+	{database.ErrMemberExists, http.StatusConflict, "member_exists"},
+	{database.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{database.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{database.ErrNoOrganizerRequest, http.StatusNotFound, "organizer_request_not_found"},
+	{database.ErrAlreadyOrganizer, http.StatusConflict, "already_organizer"},
+	{database.ErrOrganizerRequestDup, http.StatusConflict, "organizer_request_pending"},
+	{database.ErrEmailTaken, http.StatusConflict, "email_taken"},
+
+	// Credentials
+	{database.ErrRefreshTokenInvalid, http.StatusUnauthorized, "refresh_token_invalid"},
+	{database.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{database.ErrUserTokenInvalid, http.StatusBadRequest, "token_invalid"},
+	{utils.ErrTokenRevoked, http.StatusUnauthorized, "token_revoked"},
+	{utils.ErrPasswordLength, http.StatusBadRequest, "weak_password"},
+	{utils.ErrPasswordWeak, http.StatusBadRequest, "weak_password"},
+	{database.ErrMFAAlreadyEnabled, http.StatusConflict, "mfa_already_enabled"},
+	{database.ErrMFANotEnabled, http.StatusConflict, "mfa_not_enabled"},
+	{database.ErrMFANotEnrolled, http.StatusConflict, "mfa_not_enrolled"},
+	{database.ErrMFACodeInvalid, http.StatusBadRequest, "invalid_mfa_code"},
+	{utils.ErrMFAChallengeInvalid, http.StatusUnauthorized, "mfa_token_invalid"},
+	{utils.ErrLoginThrottled, http.StatusTooManyRequests, "login_throttled"},
+	{utils.ErrAccountLocked, http.StatusTooManyRequests, "account_locked"},
+	{database.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+	{keys.ErrUnknownKey, http.StatusUnauthorized, CodeUnauthorized},
+}
+
+// fromValidation converts a failed struct validation into a 400 listing
+// every failing field.
+func fromValidation(err error) *Error {
+	var invalid *validation.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	return BadRequest(CodeValidationFailed, "Request validation failed").With("fields", invalid.Fields)
+}
@@ -1,9 +1,11 @@
 package broker
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"ticketing/internal/database"
+	"ticketing/internal/queue"
 
 	"github.com/google/uuid"
 )
@@ -24,19 +26,24 @@ func ConsumeBookingRequests(db database.Service) {
 	}
 
 	for msg := range msgs {
-		var req database.TicketBookingReq
-		err := json.Unmarshal(msg.Body, &req)
+		env, err := queue.DecodeEnvelope(msg.Body)
 		if err != nil {
 			log.Printf("Failed to unmarshal message: %v", err)
 			continue
 		}
 
+		req, err := queue.DecodeBooking(env)
+		if err != nil {
+			log.Printf("Failed to decode message %s: %v", env.MessageID, err)
+			continue
+		}
+
 		// Process booking
 		err = processBooking(db, req)
 		if err != nil {
-			log.Printf("Failed to book ticket: %v", err)
+			log.Printf("Failed to book ticket (correlation_id=%s): %v", env.CorrelationID, err)
 		} else {
-			log.Println("Ticket booked successfully!")
+			log.Printf("Ticket booked successfully! (correlation_id=%s)", env.CorrelationID)
 		}
 	}
 }
@@ -49,6 +56,12 @@ func processBooking(db database.Service, req database.TicketBookingReq) error {
 		return err
 	}
 
+	// Only events that are on sale accept bookings
+	if event.Status != database.EventStatusOnSale {
+		log.Printf("Event %v is %s, rejecting booking", req.EventID, event.Status)
+		return database.ErrEventNotOnSale
+	}
+
 	// Check ticket availability
 	totalSold, err := db.GetTotalTicketsSold(req.EventID)
 	if err != nil {
@@ -59,13 +72,35 @@ func processBooking(db database.Service, req database.TicketBookingReq) error {
 	remainingCapacity := event.Capacity - totalSold
 	if req.Quantity > remainingCapacity {
 		log.Printf("Insufficient capacity for event %v", req.EventID)
-		return err
+		return fmt.Errorf("insufficient capacity for event %v", req.EventID)
 	}
 
-	// Create ticket
+	// Requests queued before bookings carried the account are matched by
+	// email. Without a matching account the ticket is still issued, with no
+	// owner, like tickets booked before accounts were recorded.
+	if req.UserID == "" {
+		user, err := db.GetUserByEmail(req.Email)
+		switch {
+		case err == nil:
+			req.UserID = user.UserID
+		case errors.Is(err, database.ErrUserNotFound):
+			log.Printf("No account for booking email %s, issuing the ticket without one", req.Email)
+		default:
+			log.Printf("Error looking up booking email %s: %v", req.Email, err)
+			return err
+		}
+	}
+
+	// Store the ticket under the ID the client got back when booking; only
+	// payloads queued before that ID existed get a new one
+	ticketID := req.TicketID
+	if ticketID == "" {
+		ticketID = uuid.New().String()
+	}
 	ticket := &database.Ticket{
-		TicketID: uuid.New().String(),
+		TicketID: ticketID,
 		EventID:  req.EventID,
+		UserID:   req.UserID,
 		Email:    req.Email,
 		Quantity: req.Quantity,
 	}
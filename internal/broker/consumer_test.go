@@ -0,0 +1,125 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"ticketing/internal/database"
+	"ticketing/internal/queue"
+)
+
+// bookingDB serves one on-sale event and records the tickets created.
+type bookingDB struct {
+	database.Service
+	users     map[string]string // Lowercase email to user ID
+	lookupErr error
+	tickets   []database.Ticket
+}
+
+func (b *bookingDB) GetEvent(eventID string) (*database.Event, error) {
+	return &database.Event{EventID: eventID, Status: database.EventStatusOnSale, Capacity: 10}, nil
+}
+
+func (b *bookingDB) GetTotalTicketsSold(eventID string) (int, error) {
+	return 0, nil
+}
+
+func (b *bookingDB) GetUserByEmail(email string) (*database.User, error) {
+	if b.lookupErr != nil {
+		return nil, b.lookupErr
+	}
+	// Matched like the database does, ignoring case and spaces
+	userID, ok := b.users[strings.ToLower(strings.TrimSpace(email))]
+	if !ok {
+		return nil, database.ErrUserNotFound
+	}
+	return &database.User{UserID: userID, Email: email}, nil
+}
+
+func (b *bookingDB) CreateTicket(ticket *database.Ticket) error {
+	b.tickets = append(b.tickets, *ticket)
+	return nil
+}
+
+func TestProcessLegacyBooking(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		lookupErr error
+		wantErr   bool
+		wantUser  string
+	}{
+		{name: "matched by email", email: "ada@example.com", wantUser: "u-1"},
+		{name: "matched ignoring case", email: " Ada@Example.COM", wantUser: "u-1"},
+		{name: "no account", email: "guest@example.com", wantUser: ""},
+		{name: "lookup failure", email: "ada@example.com", lookupErr: errors.New("connection reset"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &bookingDB{users: map[string]string{"ada@example.com": "u-1"}, lookupErr: tt.lookupErr}
+			err := processBooking(db, database.TicketBookingReq{EventID: "e-1", Email: tt.email, Quantity: 2})
+			if tt.wantErr {
+				if err == nil || len(db.tickets) != 0 {
+					t.Fatalf("err = %v, tickets = %d; want an error and no ticket", err, len(db.tickets))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("processBooking: %v", err)
+			}
+			if len(db.tickets) != 1 {
+				t.Fatalf("created %d tickets, want 1", len(db.tickets))
+			}
+			if got := db.tickets[0]; got.UserID != tt.wantUser || got.Email != tt.email || got.Quantity != 2 {
+				t.Errorf("ticket = %+v, want user %q", got, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestProcessBookingKeepsTicketID(t *testing.T) {
+	enqueued := database.TicketBookingReq{TicketID: "t-42", EventID: "e-1", UserID: "u-1", Email: "ada@example.com", Quantity: 1}
+	env, err := queue.NewBookingEnvelope(enqueued, "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode the message the way the consumer does
+	decoded, err := queue.DecodeEnvelope(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := queue.DecodeBooking(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &bookingDB{}
+	if err := processBooking(db, req); err != nil {
+		t.Fatalf("processBooking: %v", err)
+	}
+	if len(db.tickets) != 1 || db.tickets[0].TicketID != enqueued.TicketID {
+		t.Fatalf("stored tickets %+v, want ID %q", db.tickets, enqueued.TicketID)
+	}
+
+	// A legacy payload without an ID still gets a ticket
+	legacy, err := queue.DecodeEnvelope([]byte(`{"event_id": "e-1", "user_id": "u-1", "email": "ada@example.com", "quantity": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = queue.DecodeBooking(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := processBooking(db, req); err != nil {
+		t.Fatalf("processBooking legacy: %v", err)
+	}
+	if len(db.tickets) != 2 || db.tickets[1].TicketID == "" {
+		t.Errorf("legacy booking stored %+v, want a generated ID", db.tickets)
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"encoding/json"
 	"log"
 	"ticketing/internal/database"
+	"ticketing/internal/queue"
 
 	"github.com/streadway/amqp"
 )
 
-func PublishBookingRequest(ticketReq database.TicketBookingReq) error {
+func PublishBookingRequest(ticketReq database.TicketBookingReq, correlationID string) error {
 	ch := GetRabbitMQChannel()
 
-	body, err := json.Marshal(ticketReq)
+	env, err := queue.NewBookingEnvelope(ticketReq, correlationID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(env)
 	if err != nil {
 		return err
 	}
@@ -22,8 +28,12 @@ func PublishBookingRequest(ticketReq database.TicketBookingReq) error {
 		false,                  // Mandatory
 		false,                  // Immediate
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:   "application/json",
+			Type:          env.Type,
+			MessageId:     env.MessageID,
+			CorrelationId: env.CorrelationID,
+			Timestamp:     env.CreatedAt,
+			Body:          body,
 		},
 	)
 	if err != nil {
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey lets a partner system act as the user who created it, limited to
+// its scopes. Only the key's hash is stored; Prefix identifies it in lists.
+type APIKey struct {
+	KeyID      string     `gorm:"type:varchar(255);primaryKey" json:"key_id"`
+	UserID     string     `gorm:"type:varchar(255);not null;index" json:"-"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"` // First characters of the key
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	Scopes     StringList `gorm:"type:jsonb;not null;default:'[]'" json:"scopes"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Expired reports whether the key may no longer be used at now.
+func (k *APIKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyDTO represents a user creating an API key.
+type CreateAPIKeyDTO struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=events:read events:write tickets:read checkin:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"` // Defaults to 90
+}
+
+// ErrAPIKeyNotFound is returned for unknown API keys.
+var ErrAPIKeyNotFound = errors.New("API key not found")
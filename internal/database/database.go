@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Service represents a service that interacts with a database.
@@ -19,13 +21,99 @@ type Service interface {
 	Close() error
 	CreateEvent(event *Event) error
 	GetEvent(uniqueID string) (*Event, error)
+	ListEvents(filter EventFilter) (*EventPage, error)
 	UpdateEvent(event *Event) error
-	DeleteEvent(uniqueID, userId string) error
+	DeleteEvent(eventID, userID string, force bool) (int, error)
+	ListDeletedEvents(userID string) ([]Event, error)
+	GetDeletedEvent(eventID string) (*Event, error)
+	RestoreEvent(eventID, userID string) (*Event, error)
 	GetTotalTicketsSold(eventID string) (int, error)
+	SetEventStatus(eventID, from, to string) error
+	CancelEvent(eventID, from string) error
+	ListEventTickets(eventID string) ([]Ticket, error)
+	CheckInTicket(eventID, ticketID string, now time.Time) (*Ticket, error)
+	CancelTicket(eventID, ticketID string) (*Ticket, error)
+	ListUserTickets(filter TicketFilter) ([]UserTicket, error)
 	CreateTicket(ticket *Ticket) error
 	GetTicket(ticketID string) (*Ticket, error)
 	CreateUser(user *User) error
 	GetUserByEmail(email string) (*User, error)
+	CreateVenue(venue *Venue) error
+	CreateCategory(category *Category) error
+	GetCategory(slug string) (*Category, error)
+	ListCategoryCounts() ([]CategoryCount, error)
+	ListTagCounts(limit int) ([]TagCount, error)
+	CreateCollection(collection *Collection) error
+	GetCollection(slug string) (*Collection, error)
+	ListCollections() ([]Collection, error)
+	ListCollectionEvents(collectionID, viewerID string) ([]Event, error)
+	AddEventToCollection(entry *CollectionEvent) error
+	RemoveEventFromCollection(collectionID, eventID string) error
+	CreateTemplate(template *EventTemplate) error
+	GetTemplate(templateID string) (*EventTemplate, error)
+	ListTemplates(userID string) ([]EventTemplate, error)
+	DeleteTemplate(templateID, userID string) error
+	CreateMedia(media *EventMedia) ([]EventMedia, error)
+	GetMedia(mediaID string) (*EventMedia, error)
+	ListEventMedia(eventID string) ([]EventMedia, error)
+	DeleteMedia(mediaID string) error
+	AddEventMember(member *EventMember) error
+	GetEventMember(eventID, userID string) (*EventMember, error)
+	ListEventMembers(eventID string) ([]EventMember, error)
+	AcceptEventMember(eventID, userID string) (*EventMember, error)
+	UpdateEventMemberRole(eventID, userID, role string) (*EventMember, error)
+	RemoveEventMember(eventID, userID string) error
+	GetUser(userID string) (*User, error)
+	UpdateProfile(userID string, updates map[string]interface{}) (*User, error)
+	RequestEmailChange(userID, email string, token *UserToken) error
+	ConfirmEmailChange(hash string, now time.Time) (*User, error)
+	ListUsers(filter UserFilter) ([]User, error)
+	SetUserRole(userID, role string) (*User, error)
+	RequestOrganizer(userID string) (*User, error)
+	DecideOrganizerRequest(userID string, approve bool) (*User, error)
+	TransferEvent(eventID, userID string) (*Event, error)
+	CreateRefreshToken(token *RefreshToken) error
+	RotateRefreshToken(hash string, next *RefreshToken, now time.Time) (*RefreshToken, error)
+	RevokeRefreshToken(hash string, now time.Time) error
+	RevokeUserRefreshTokens(userID string, now time.Time) error
+	CreateUserToken(token *UserToken) error
+	VerifyEmail(hash string, now time.Time) (*User, error)
+	ResetPassword(hash, passwordHash string, now time.Time) (*User, error)
+	ChangePassword(userID, passwordHash string, now time.Time) error
+	UnlockAccount(hash string, now time.Time) (*User, error)
+	ResolveIdentity(identity *UserIdentity, newUser *User) (*User, error)
+	StartMFAEnrollment(userID, secret string) error
+	EnableMFA(userID string, counter int64, codeHashes []string, now time.Time) error
+	DisableMFA(userID string) error
+	UseTOTPCounter(userID string, counter int64) error
+	UseRecoveryCode(userID, hash string, now time.Time) error
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	GetSecurityPolicy() (*SecurityPolicy, error)
+	UpdateSecurityPolicy(policy *SecurityPolicy) error
+	RecordFailedLogin(attempt *FailedLogin) error
+	ListFailedLogins(filter FailedLoginFilter) ([]FailedLogin, error)
+	CreateAPIKey(key *APIKey) error
+	ListAPIKeys(userID string) ([]APIKey, error)
+	GetAPIKeyByHash(hash string) (*APIKey, error)
+	TouchAPIKey(keyID string, now time.Time) error
+	DeleteAPIKey(keyID, userID string) error
+	ListSigningKeys(now time.Time) ([]SigningKey, error)
+	RotateSigningKey(key *SigningKey, retireAt, replaceBefore time.Time) error
+	UpdateSigningKey(keyID, privateKey string) error
+	CreateDetailsSchema(schema *DetailsSchema) error
+	GetDetailsSchema(schemaID string) (*DetailsSchema, error)
+	GetDefaultDetailsSchema(category string) (*DetailsSchema, error)
+	ListDetailsSchemas(category, userID string) ([]DetailsSchema, error)
+	CreateSeries(series *EventSeries, occurrences []Event) error
+	GetSeries(seriesID string) (*EventSeries, error)
+	ListSeriesEvents(seriesID string) ([]Event, error)
+	UpdateSeriesEvents(series *EventSeries, occurrences []Event) error
+	GetVenue(venueID string) (*Venue, error)
+	EnqueueJob(job *Job) error
+	LeaseJobs(workerID string, now time.Time, lease time.Duration, limit int) ([]Job, error)
+	ExtendJobLease(id uint, workerID string, until time.Time) error
+	CompleteJob(id uint, workerID string, now time.Time) error
+	FailJob(id uint, workerID string, now time.Time, retryAt time.Time, jobErr error) error
 }
 
 type service struct {
@@ -67,11 +155,64 @@ func New() (Service, error) {
 	// Disable foreign key checks
 	db.Exec("SET CONSTRAINTS ALL DEFERRED;")
 
+	// Data migrations are recorded in schema_migrations. Databases created
+	// before that table existed are judged by their schema, once.
+	var alreadyApplied []string
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		alreadyApplied = appliedBeforeLedger(db)
+	}
+
 	// Ensure the correct order of migration
-	if err := db.AutoMigrate(&Event{}, &Ticket{}, &User{}); err != nil {
+	if err := db.AutoMigrate(&Venue{}, &DetailsSchema{}, &Category{}, &Event{}, &EventSeries{}, &Collection{}, &CollectionEvent{}, &EventTemplate{}, &EventMedia{}, &EventMember{}, &Ticket{}, &User{}, &RefreshToken{}, &UserToken{}, &FailedLogin{}, &RecoveryCode{}, &SecurityPolicy{}, &UserIdentity{}, &APIKey{}, &SigningKey{}, &Job{}, &SchemaMigration{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
 	}
 
+	// Keep the built-in details schemas in sync with the code
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&builtInDetailsSchemas).Error; err != nil {
+		return nil, fmt.Errorf("failed to seed details schemas: %w", err)
+	}
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&builtInCategories).Error; err != nil {
+		return nil, fmt.Errorf("failed to seed categories: %w", err)
+	}
+
+	// Full-text search index backing event listing
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_events_search ON events USING GIN (to_tsvector('english', name || ' ' || description))").Error; err != nil {
+		return nil, fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	// Containment index backing tag filters
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_events_tags ON events USING GIN (tags)").Error; err != nil {
+		return nil, fmt.Errorf("failed to create tags index: %w", err)
+	}
+
+	// Case-insensitive index backing lookups by email
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))").Error; err != nil {
+		return nil, fmt.Errorf("failed to create email index: %w", err)
+	}
+
+	if err := runBackfills(db, alreadyApplied); err != nil {
+		return nil, err
+	}
+
+	// Bootstrap platform admins from a comma-separated list of emails. Once
+	// an admin exists, roles are managed under /admin/users only, so demoted
+	// admins are not promoted again on the next boot.
+	if admins := strings.Split(os.Getenv("ADMIN_EMAILS"), ","); os.Getenv("ADMIN_EMAILS") != "" {
+		var existing int64
+		if err := db.Model(&User{}).Where("role = ?", RoleAdmin).Count(&existing).Error; err != nil {
+			return nil, fmt.Errorf("failed to count admins: %w", err)
+		}
+		if existing == 0 {
+			for i := range admins {
+				admins[i] = strings.TrimSpace(admins[i])
+			}
+			if err := db.Model(&User{}).Where("email IN ?", admins).Update("role", RoleAdmin).Error; err != nil {
+				return nil, fmt.Errorf("failed to promote admins: %w", err)
+			}
+		}
+	}
+
 	dbInstance = &service{
 		db: db,
 	}
@@ -122,6 +263,9 @@ func (s *service) Close() error {
 // Defined the error for event not found
 var ErrEventNotFound = errors.New("event not found")
 
+// ErrEventHasTickets is returned when deleting an event that still has active tickets.
+var ErrEventHasTickets = errors.New("event has active tickets")
+
 // CreateEvent creates a new event in the database.
 func (s *service) CreateEvent(event *Event) error {
 	return s.db.Create(event).Error
@@ -130,7 +274,7 @@ func (s *service) CreateEvent(event *Event) error {
 // GetEvent retrieves an event by its unique ID.
 func (s *service) GetEvent(eventID string) (*Event, error) {
 	var event Event
-	if err := s.db.First(&event, "event_id = ?", eventID).Error; err != nil {
+	if err := s.db.Preload("Venue").First(&event, "event_id = ?", eventID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrEventNotFound
 		}
@@ -139,21 +283,127 @@ func (s *service) GetEvent(eventID string) (*Event, error) {
 	return &event, nil
 }
 
-// UpdateEvent updates an existing event in the database.
+// UpdateEvent updates an existing event in the database. The write only
+// applies if the stored version still matches event.Version; otherwise
+// ErrVersionConflict is returned. On success event.Version is incremented.
 func (s *service) UpdateEvent(event *Event) error {
-	return s.db.Save(event).Error
+	return updateEventVersioned(s.db, event)
 }
 
-// DeleteEvent deletes an event by its unique ID.
-func (s *service) DeleteEvent(uniqueID, userID string) error {
-	return s.db.Delete(&Event{}, "unique_id = ? AND user_id = ?", uniqueID, userID).Error
+func updateEventVersioned(db *gorm.DB, event *Event) error {
+	expected := event.Version
+	event.Version++
+	res := db.Model(event).
+		Omit(clause.Associations).
+		Where("version = ?", expected).
+		Select("*").
+		Updates(event)
+	if res.Error != nil || res.RowsAffected == 0 {
+		event.Version = expected
+		if res.Error != nil {
+			return res.Error
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// DeleteEvent soft-deletes an event owned by userID. Events with active
+// tickets are refused with ErrEventHasTickets unless force is set, in which
+// case the event and its tickets are cancelled first. It returns the number
+// of tickets cancelled.
+func (s *service) DeleteEvent(eventID, userID string, force bool) (int, error) {
+	var cancelled int
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var event Event
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, "event_id = ? AND user_id = ?", eventID, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&Ticket{}).
+			Where("event_id = ? AND status = ?", eventID, TicketStatusActive).
+			Count(&active).Error; err != nil {
+			return err
+		}
+
+		if active > 0 {
+			if !force {
+				return ErrEventHasTickets
+			}
+			res := tx.Model(&Ticket{}).
+				Where("event_id = ? AND status = ?", eventID, TicketStatusActive).
+				Update("status", TicketStatusCancelled)
+			if res.Error != nil {
+				return res.Error
+			}
+			cancelled = int(res.RowsAffected)
+		}
+
+		// Only a forced delete cancels the event, so a restore brings back
+		// events deleted without tickets in the state they were in.
+		if cancelled > 0 && !event.IsTerminal() {
+			if err := tx.Model(&event).Updates(map[string]interface{}{"status": EventStatusCancelled, "version": gorm.Expr("version + 1")}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&event).Error
+	})
+	return cancelled, err
+}
+
+// ListDeletedEvents returns the soft-deleted events owned by userID, or
+// every deleted event when userID is empty.
+func (s *service) ListDeletedEvents(userID string) ([]Event, error) {
+	var events []Event
+	q := s.db.Unscoped().Where("deleted_at IS NOT NULL")
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	err := q.Order("deleted_at DESC").Find(&events).Error
+	return events, err
+}
+
+// GetDeletedEvent retrieves a soft-deleted event by its unique ID.
+func (s *service) GetDeletedEvent(eventID string) (*Event, error) {
+	var event Event
+	if err := s.db.Unscoped().
+		First(&event, "event_id = ? AND deleted_at IS NOT NULL", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// RestoreEvent undoes the soft delete of an event owned by userID, or of
+// any event when userID is empty.
+func (s *service) RestoreEvent(eventID, userID string) (*Event, error) {
+	q := s.db.Unscoped().Model(&Event{}).Where("event_id = ? AND deleted_at IS NOT NULL", eventID)
+	if userID != "" {
+		q = q.Where("user_id = ?", userID)
+	}
+	res := q.Update("deleted_at", nil)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrEventNotFound
+	}
+	return s.GetEvent(eventID)
 }
 
 // GetTotalTicketsSold retrieves the total number of tickets sold for a specific event.
 func (s *service) GetTotalTicketsSold(eventID string) (int, error) {
 	var totalSold int64
 	if err := s.db.Model(&Ticket{}).
-		Where("event_id = ?", eventID).
+		Where("event_id = ? AND status = ?", eventID, TicketStatusActive).
 		Select("COALESCE(SUM(quantity), 0)").
 		Scan(&totalSold).Error; err != nil {
 		return 0, err
@@ -161,6 +411,136 @@ func (s *service) GetTotalTicketsSold(eventID string) (int, error) {
 	return int(totalSold), nil
 }
 
+// SetEventStatus moves an event from one state to another. The update only
+// applies if the event is still in the from state, so concurrent transitions
+// cannot both succeed.
+func (s *service) SetEventStatus(eventID, from, to string) error {
+	res := s.db.Model(&Event{}).
+		Where("event_id = ? AND status = ?", eventID, from).
+		Updates(map[string]interface{}{"status": to, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// CancelEvent marks an event and all of its active tickets as cancelled.
+func (s *service) CancelEvent(eventID, from string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Event{}).
+			Where("event_id = ? AND status = ?", eventID, from).
+			Updates(map[string]interface{}{"status": EventStatusCancelled, "version": gorm.Expr("version + 1")})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidTransition
+		}
+		return tx.Model(&Ticket{}).
+			Where("event_id = ? AND status = ?", eventID, TicketStatusActive).
+			Update("status", TicketStatusCancelled).Error
+	})
+}
+
+// ListEventTickets returns every ticket booked for an event.
+func (s *service) ListEventTickets(eventID string) ([]Ticket, error) {
+	var tickets []Ticket
+	err := s.db.Where("event_id = ?", eventID).Order("created_at").Find(&tickets).Error
+	return tickets, err
+}
+
+// CheckInTicket marks an active ticket of an event as used at now. A ticket
+// is only let in once: a second check-in returns ErrTicketCheckedIn with the
+// ticket, so the time of the first one can be shown.
+func (s *service) CheckInTicket(eventID, ticketID string, now time.Time) (*Ticket, error) {
+	res := s.db.Model(&Ticket{}).
+		Where("event_id = ? AND ticket_id = ? AND status = ? AND checked_in_at IS NULL", eventID, ticketID, TicketStatusActive).
+		Update("checked_in_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	ticket, err := s.eventTicket(eventID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		if ticket.Status != TicketStatusActive {
+			return ticket, ErrTicketCancelled
+		}
+		return ticket, ErrTicketCheckedIn
+	}
+	return ticket, nil
+}
+
+// CancelTicket cancels an active ticket of an event, which frees its seats
+// for sale again.
+func (s *service) CancelTicket(eventID, ticketID string) (*Ticket, error) {
+	res := s.db.Model(&Ticket{}).
+		Where("event_id = ? AND ticket_id = ? AND status = ?", eventID, ticketID, TicketStatusActive).
+		Update("status", TicketStatusCancelled)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	ticket, err := s.eventTicket(eventID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrTicketCancelled
+	}
+	return ticket, nil
+}
+
+// eventTicket loads a ticket booked for eventID.
+func (s *service) eventTicket(eventID, ticketID string) (*Ticket, error) {
+	var ticket Ticket
+	if err := s.db.First(&ticket, "event_id = ? AND ticket_id = ?", eventID, ticketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketNotFound
+		}
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// ListUserTickets returns the tickets a user booked, newest first, with
+// their events. Tickets of deleted events are listed too.
+func (s *service) ListUserTickets(filter TicketFilter) ([]UserTicket, error) {
+	q := s.db.Where("user_id = ?", filter.UserID)
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	var tickets []Ticket
+	if err := q.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+
+	eventIDs := make([]string, 0, len(tickets))
+	for _, t := range tickets {
+		eventIDs = append(eventIDs, t.EventID)
+	}
+	var events []Event
+	if len(eventIDs) > 0 {
+		if err := s.db.Unscoped().Where("event_id IN ?", eventIDs).Find(&events).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[string]*Event, len(events))
+	for i := range events {
+		events[i].Localize()
+		byID[events[i].EventID] = &events[i]
+	}
+
+	result := make([]UserTicket, 0, len(tickets))
+	for _, t := range tickets {
+		result = append(result, UserTicket{Ticket: t, Event: byID[t.EventID]})
+	}
+	return result, nil
+}
+
 // CreateTicket saves a new ticket in the database.
 func (s *service) CreateTicket(ticket *Ticket) error {
 	return s.db.Model(&Ticket{}).Create(ticket).Error
@@ -169,7 +549,7 @@ func (s *service) GetTicket(ticketID string) (*Ticket, error) {
 	var ticket Ticket
 	if err := s.db.First(&ticket, "ticket_id = ?", ticketID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("ticket not found")
+			return nil, ErrTicketNotFound
 		}
 		return nil, err
 	}
@@ -180,10 +560,1224 @@ func (s *service) CreateUser(user *User) error {
 	return s.db.Model(&User{}).Create(user).Error
 }
 
+// GetUserByEmail finds the account with the given email, ignoring case and
+// surrounding spaces. Should older accounts differ only in case, the exact
+// match wins, then the oldest.
 func (s *service) GetUserByEmail(email string) (*User, error) {
+	email = strings.TrimSpace(email)
 	var res User
-	if err := s.db.Model(&User{}).Where("email = ?", email).First(&res).Error; err != nil {
+	err := s.db.Model(&User{}).Where("LOWER(email) = LOWER(?)", email).
+		Order(gorm.Expr("email = ? DESC", email)).Order("created_at").
+		Take(&res).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
 	return &res, nil
 }
+
+// CreateVenue saves a new venue.
+func (s *service) CreateVenue(venue *Venue) error {
+	return s.db.Create(venue).Error
+}
+
+// GetVenue retrieves a venue by its unique ID.
+func (s *service) GetVenue(venueID string) (*Venue, error) {
+	var venue Venue
+	if err := s.db.First(&venue, "venue_id = ?", venueID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVenueNotFound
+		}
+		return nil, err
+	}
+	return &venue, nil
+}
+
+// CreateSeries saves a series and all of its materialized occurrences atomically.
+func (s *service) CreateSeries(series *EventSeries, occurrences []Event) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(series).Error; err != nil {
+			return err
+		}
+		return tx.Omit(clause.Associations).Create(&occurrences).Error
+	})
+}
+
+// GetSeries retrieves a series by its unique ID.
+func (s *service) GetSeries(seriesID string) (*EventSeries, error) {
+	var series EventSeries
+	if err := s.db.First(&series, "series_id = ?", seriesID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+	return &series, nil
+}
+
+// ListSeriesEvents returns the occurrences of a series ordered by start time.
+func (s *service) ListSeriesEvents(seriesID string) ([]Event, error) {
+	var events []Event
+	err := s.db.Where("series_id = ?", seriesID).Order("starts_at").Find(&events).Error
+	return events, err
+}
+
+// UpdateSeriesEvents saves edited occurrences, and the series itself when
+// non-nil, in a single transaction.
+func (s *service) UpdateSeriesEvents(series *EventSeries, occurrences []Event) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if series != nil {
+			if err := tx.Save(series).Error; err != nil {
+				return err
+			}
+		}
+		for i := range occurrences {
+			if err := updateEventVersioned(tx, &occurrences[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateDetailsSchema saves an organizer-defined details schema.
+func (s *service) CreateDetailsSchema(schema *DetailsSchema) error {
+	return s.db.Create(schema).Error
+}
+
+// GetDetailsSchema retrieves a details schema by ID.
+func (s *service) GetDetailsSchema(schemaID string) (*DetailsSchema, error) {
+	var schema DetailsSchema
+	if err := s.db.First(&schema, "schema_id = ?", schemaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDetailsSchemaNotFound
+		}
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// GetDefaultDetailsSchema returns the built-in schema for a category.
+func (s *service) GetDefaultDetailsSchema(category string) (*DetailsSchema, error) {
+	var schema DetailsSchema
+	if err := s.db.First(&schema, "category = ? AND built_in", category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDetailsSchemaNotFound
+		}
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// ListDetailsSchemas returns the built-in schemas and those defined by
+// userID, optionally restricted to a category.
+func (s *service) ListDetailsSchemas(category, userID string) ([]DetailsSchema, error) {
+	q := s.db.Where("built_in OR user_id = ?", userID)
+	if category != "" {
+		q = q.Where("category = ?", category)
+	}
+	var schemas []DetailsSchema
+	err := q.Order("built_in DESC, name").Find(&schemas).Error
+	return schemas, err
+}
+
+// CreateCategory saves a new category.
+func (s *service) CreateCategory(category *Category) error {
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(category)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCategoryExists
+	}
+	return nil
+}
+
+// GetCategory retrieves a category by slug.
+func (s *service) GetCategory(slug string) (*Category, error) {
+	var category Category
+	if err := s.db.First(&category, "slug = ?", slug).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// ListCategoryCounts returns every category with the number of published or
+// on-sale events in it and its descendants.
+func (s *service) ListCategoryCounts() ([]CategoryCount, error) {
+	var categories []Category
+	if err := s.db.Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var direct []struct {
+		Category string
+		Count    int
+	}
+	if err := s.db.Model(&Event{}).
+		Select("category, COUNT(*) AS count").
+		Where("status IN ?", listableStatuses).
+		Group("category").
+		Scan(&direct).Error; err != nil {
+		return nil, err
+	}
+
+	// Roll each category's own count up through its ancestors.
+	parents := make(map[string]string, len(categories))
+	for _, c := range categories {
+		if c.ParentSlug != nil {
+			parents[c.Slug] = *c.ParentSlug
+		}
+	}
+	totals := make(map[string]int, len(categories))
+	for _, d := range direct {
+		seen := map[string]bool{}
+		for slug := d.Category; slug != "" && !seen[slug]; slug = parents[slug] {
+			seen[slug] = true
+			totals[slug] += d.Count
+		}
+	}
+
+	counts := make([]CategoryCount, 0, len(categories))
+	for _, c := range categories {
+		counts = append(counts, CategoryCount{Category: c, EventCount: totals[c.Slug]})
+	}
+	return counts, nil
+}
+
+// ListTagCounts returns the most used tags on published or on-sale events.
+func (s *service) ListTagCounts(limit int) ([]TagCount, error) {
+	var counts []TagCount
+	err := s.db.Model(&Event{}).
+		Select("tag, COUNT(*) AS event_count").
+		Joins("CROSS JOIN LATERAL jsonb_array_elements_text(events.tags) AS tag").
+		Where("events.status IN ?", listableStatuses).
+		Group("tag").
+		Order("event_count DESC, tag").
+		Limit(limit).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CreateCollection saves a new collection.
+// The unique slug index settles concurrent creates, and also keeps the slug
+// of a deleted collection reserved.
+func (s *service) CreateCollection(collection *Collection) error {
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(collection)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCollectionExists
+	}
+	return nil
+}
+
+// GetCollection retrieves a collection by slug.
+func (s *service) GetCollection(slug string) (*Collection, error) {
+	var collection Collection
+	if err := s.db.First(&collection, "slug = ?", slug).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCollectionNotFound
+		}
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// ListCollections returns every collection.
+func (s *service) ListCollections() ([]Collection, error) {
+	var collections []Collection
+	err := s.db.Order("name").Find(&collections).Error
+	return collections, err
+}
+
+// ListCollectionEvents returns the events in a collection in curated order,
+// hiding drafts not owned by viewerID.
+func (s *service) ListCollectionEvents(collectionID, viewerID string) ([]Event, error) {
+	var events []Event
+	err := s.db.
+		Joins("JOIN collection_events ON collection_events.event_id = events.event_id").
+		Where("collection_events.collection_id = ?", collectionID).
+		Where(draftVisibilityClause, EventStatusDraft, viewerID, viewerID).
+		Order("collection_events.position, collection_events.created_at").
+		Find(&events).Error
+	return events, err
+}
+
+// AddEventToCollection adds an event to a collection or moves it to a new position.
+func (s *service) AddEventToCollection(entry *CollectionEvent) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "collection_id"}, {Name: "event_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position"}),
+	}).Create(entry).Error
+}
+
+// RemoveEventFromCollection removes an event from a collection.
+func (s *service) RemoveEventFromCollection(collectionID, eventID string) error {
+	res := s.db.Delete(&CollectionEvent{}, "collection_id = ? AND event_id = ?", collectionID, eventID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// CreateTemplate saves a new event template.
+func (s *service) CreateTemplate(template *EventTemplate) error {
+	return s.db.Create(template).Error
+}
+
+// GetTemplate retrieves an event template by ID.
+func (s *service) GetTemplate(templateID string) (*EventTemplate, error) {
+	var template EventTemplate
+	if err := s.db.First(&template, "template_id = ?", templateID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// ListTemplates returns the event templates owned by userID.
+func (s *service) ListTemplates(userID string) ([]EventTemplate, error) {
+	var templates []EventTemplate
+	err := s.db.Where("user_id = ?", userID).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// DeleteTemplate deletes an event template owned by userID.
+func (s *service) DeleteTemplate(templateID, userID string) error {
+	res := s.db.Where("template_id = ? AND user_id = ?", templateID, userID).Delete(&EventTemplate{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// CreateMedia saves an event image. A new cover replaces the event's
+// previous cover, which is returned so its files can be removed.
+func (s *service) CreateMedia(media *EventMedia) ([]EventMedia, error) {
+	var replaced []EventMedia
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if media.Kind == MediaKindCover {
+			if err := tx.Where("event_id = ? AND kind = ?", media.EventID, MediaKindCover).Find(&replaced).Error; err != nil {
+				return err
+			}
+			if len(replaced) > 0 {
+				if err := tx.Unscoped().Delete(&replaced).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(media).Error
+	})
+	return replaced, err
+}
+
+// GetMedia retrieves an event image by ID.
+func (s *service) GetMedia(mediaID string) (*EventMedia, error) {
+	var media EventMedia
+	if err := s.db.First(&media, "media_id = ?", mediaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, err
+	}
+	return &media, nil
+}
+
+// ListEventMedia returns an event's images, cover first.
+func (s *service) ListEventMedia(eventID string) ([]EventMedia, error) {
+	var media []EventMedia
+	err := s.db.Where("event_id = ?", eventID).
+		Order(clause.Expr{SQL: "kind = ? DESC, position, created_at", Vars: []interface{}{MediaKindCover}}).
+		Find(&media).Error
+	return media, err
+}
+
+// DeleteMedia removes an event image record.
+func (s *service) DeleteMedia(mediaID string) error {
+	res := s.db.Unscoped().Delete(&EventMedia{}, "media_id = ?", mediaID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMediaNotFound
+	}
+	return nil
+}
+
+// AddEventMember saves a team invitation.
+func (s *service) AddEventMember(member *EventMember) error {
+	res := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(member)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMemberExists
+	}
+	return nil
+}
+
+// GetEventMember retrieves a user's team membership on an event.
+func (s *service) GetEventMember(eventID, userID string) (*EventMember, error) {
+	var member EventMember
+	if err := s.db.First(&member, "event_id = ? AND user_id = ?", eventID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// ListEventMembers returns an event's team, including pending invitations.
+func (s *service) ListEventMembers(eventID string) ([]EventMember, error) {
+	var members []EventMember
+	err := s.db.Where("event_id = ?", eventID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+// AcceptEventMember marks a pending invitation as accepted.
+func (s *service) AcceptEventMember(eventID, userID string) (*EventMember, error) {
+	var member EventMember
+	res := s.db.Model(&member).
+		Clauses(clause.Returning{}).
+		Where("event_id = ? AND user_id = ? AND accepted_at IS NULL", eventID, userID).
+		Update("accepted_at", time.Now())
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrMemberNotFound
+	}
+	return &member, nil
+}
+
+// UpdateEventMemberRole changes a team member's role.
+func (s *service) UpdateEventMemberRole(eventID, userID, role string) (*EventMember, error) {
+	var member EventMember
+	res := s.db.Model(&member).
+		Clauses(clause.Returning{}).
+		Where("event_id = ? AND user_id = ?", eventID, userID).
+		Update("role", role)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrMemberNotFound
+	}
+	return &member, nil
+}
+
+// RemoveEventMember removes a user from an event team or withdraws their invitation.
+func (s *service) RemoveEventMember(eventID, userID string) error {
+	res := s.db.Delete(&EventMember{}, "event_id = ? AND user_id = ?", eventID, userID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// GetUser retrieves a user by ID.
+func (s *service) GetUser(userID string) (*User, error) {
+	var user User
+	if err := s.db.First(&user, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns users matching the filter, newest first.
+func (s *service) ListUsers(filter UserFilter) ([]User, error) {
+	q := s.db.Model(&User{})
+	if filter.Role != "" {
+		q = q.Where("role = ?", filter.Role)
+	}
+	if filter.OrganizerStatus != "" {
+		q = q.Where("organizer_status = ?", filter.OrganizerStatus)
+	}
+	var users []User
+	err := q.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&users).Error
+	return users, err
+}
+
+// SetUserRole changes a user's platform role. Making a user an organizer
+// also settles any pending organizer request.
+func (s *service) SetUserRole(userID, role string) (*User, error) {
+	updates := map[string]interface{}{"role": role}
+	if role == RoleOrganizer {
+		updates["organizer_status"] = OrganizerStatusApproved
+	}
+	return s.updateUser(s.db.Where("user_id = ?", userID), updates, ErrUserNotFound)
+}
+
+// RequestOrganizer files a request for the organizer role.
+func (s *service) RequestOrganizer(userID string) (*User, error) {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != RoleAttendee {
+		return nil, ErrAlreadyOrganizer
+	}
+	if user.OrganizerStatus == OrganizerStatusPending {
+		return nil, ErrOrganizerRequestDup
+	}
+	return s.updateUser(
+		s.db.Where("user_id = ? AND role = ? AND organizer_status <> ?", userID, RoleAttendee, OrganizerStatusPending),
+		map[string]interface{}{"organizer_status": OrganizerStatusPending},
+		ErrOrganizerRequestDup,
+	)
+}
+
+// DecideOrganizerRequest approves or rejects a pending organizer request.
+func (s *service) DecideOrganizerRequest(userID string, approve bool) (*User, error) {
+	updates := map[string]interface{}{"organizer_status": OrganizerStatusRejected}
+	if approve {
+		updates = map[string]interface{}{"organizer_status": OrganizerStatusApproved, "role": RoleOrganizer}
+	}
+	return s.updateUser(
+		s.db.Where("user_id = ? AND organizer_status = ?", userID, OrganizerStatusPending),
+		updates,
+		ErrNoOrganizerRequest,
+	)
+}
+
+// updateUser applies updates to the single user matched by q and returns it,
+// or notFound when nothing matched.
+func (s *service) updateUser(q *gorm.DB, updates map[string]interface{}, notFound error) (*User, error) {
+	var user User
+	res := q.Model(&user).Clauses(clause.Returning{}).Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, notFound
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the given columns of a user.
+func (s *service) UpdateProfile(userID string, updates map[string]interface{}) (*User, error) {
+	return s.updateUser(s.db.Where("user_id = ?", userID), updates, ErrUserNotFound)
+}
+
+// RequestEmailChange stores the address a user wants to switch to and the
+// token mailed to it. Links sent for earlier requests stop working.
+func (s *service) RequestEmailChange(userID, email string, token *UserToken) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := emailAvailable(tx, userID, email); err != nil {
+			return err
+		}
+		res := tx.Model(&User{}).Where("user_id = ?", userID).Update("pending_email", email)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+		if err := tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, UserTokenPurposeChangeEmail).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ConfirmEmailChange consumes an email change token and makes the pending
+// address the user's verified email.
+func (s *service) ConfirmEmailChange(hash string, now time.Time) (*User, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, hash, UserTokenPurposeChangeEmail, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "user_id = ?", token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+		if user.PendingEmail == "" {
+			return ErrUserTokenInvalid
+		}
+		// Someone may have registered the address since it was requested
+		if err := emailAvailable(tx, user.UserID, user.PendingEmail); err != nil {
+			return err
+		}
+		user.Email, user.PendingEmail, user.EmailVerifiedAt = user.PendingEmail, "", &now
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":             user.Email,
+			"pending_email":     "",
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		// Sessions opened under the old address end with it
+		return tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.UserID).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// emailAvailable returns ErrEmailTaken if another user has email.
+func emailAvailable(tx *gorm.DB, userID, email string) error {
+	var count int64
+	if err := tx.Model(&User{}).
+		Where("LOWER(email) = LOWER(?) AND user_id <> ?", email, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// TransferEvent hands an event to another owner. The previous owner keeps
+// no access unless they are on the event team.
+func (s *service) TransferEvent(eventID, userID string) (*Event, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Event{}).Where("event_id = ?", eventID).
+			Updates(map[string]interface{}{"user_id": userID, "version": gorm.Expr("version + 1")})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrEventNotFound
+		}
+		// The new owner no longer needs a team role
+		return tx.Delete(&EventMember{}, "event_id = ? AND user_id = ?", eventID, userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetEvent(eventID)
+}
+
+// CreateRefreshToken stores a newly issued refresh token.
+func (s *service) CreateRefreshToken(token *RefreshToken) error {
+	return s.db.Create(token).Error
+}
+
+// RotateRefreshToken marks the token with the given hash as used and stores
+// next in its family for the same user. A token that was already used is a
+// sign of theft: its whole family is revoked and ErrRefreshTokenReused is
+// returned. The rotated token is returned on success.
+func (s *service) RotateRefreshToken(hash string, next *RefreshToken, now time.Time) (*RefreshToken, error) {
+	var (
+		current RefreshToken
+		reused  bool
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, "token_hash = ?", hash).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil || !current.ExpiresAt.After(now) {
+			return ErrRefreshTokenInvalid
+		}
+		if current.UsedAt != nil {
+			reused = true
+			return tx.Model(&RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+				Update("revoked_at", now).Error
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		next.FamilyID = current.FamilyID
+		next.UserID = current.UserID
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return &current, nil
+}
+
+// RevokeRefreshToken revokes the family of the token with the given hash,
+// ending that login session.
+func (s *service) RevokeRefreshToken(hash string, now time.Time) error {
+	var token RefreshToken
+	if err := s.db.First(&token, "token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		return err
+	}
+	return s.db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", now).Error
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of a user, ending all
+// of their sessions once their access tokens expire.
+func (s *service) RevokeUserRefreshTokens(userID string, now time.Time) error {
+	return s.db.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// CreateUserToken stores a token mailed to a user.
+func (s *service) CreateUserToken(token *UserToken) error {
+	return s.db.Create(token).Error
+}
+
+// consumeUserToken marks the unused, unexpired token with the given hash and
+// purpose as used and returns it.
+func consumeUserToken(tx *gorm.DB, hash, purpose string, now time.Time) (*UserToken, error) {
+	var token UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&token, "token_hash = ? AND purpose = ?", hash, purpose).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+	if token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return nil, ErrUserTokenInvalid
+	}
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// VerifyEmail consumes an email verification token and marks its user's
+// email as verified.
+func (s *service) VerifyEmail(hash string, now time.Time) (*User, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, hash, UserTokenPurposeVerifyEmail, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "user_id = ?", token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResetPassword consumes a password reset token and sets its user's
+// password. Every other reset token and every refresh token of the user is
+// invalidated so old sessions and links stop working.
+func (s *service) ResetPassword(hash, passwordHash string, now time.Time) (*User, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, hash, UserTokenPurposeResetPassword, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "user_id = ?", token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+		if err := tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.UserID, UserTokenPurposeResetPassword).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		// Following the emailed link also proves the address is theirs
+		updates := map[string]interface{}{"password_hash": passwordHash}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = now
+			user.EmailVerifiedAt = &now
+		}
+		return setPassword(tx, user.UserID, updates, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UnlockAccount consumes an account unlock token and returns its user.
+func (s *service) UnlockAccount(hash string, now time.Time) (*User, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, hash, UserTokenPurposeUnlockAccount, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "user_id = ?", token.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResolveIdentity returns the user linked to an external identity. An
+// identity seen for the first time is linked to the user with newUser's
+// email, or newUser is created if there is none. Callers must only pass
+// emails the provider verified.
+func (s *service) ResolveIdentity(identity *UserIdentity, newUser *User) (*User, error) {
+	var user User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var linked UserIdentity
+		err := tx.First(&linked, "issuer = ? AND subject = ?", identity.Issuer, identity.Subject).Error
+		if err == nil {
+			return tx.First(&user, "user_id = ?", linked.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		err = tx.Where("LOWER(email) = LOWER(?)", newUser.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = *newUser
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.EmailVerifiedAt == nil:
+			// The provider verified the address, so the account is theirs.
+			// Whoever registered it never proved they own the address and
+			// may have done so to wait for the owner, so nothing they set
+			// up survives.
+			if err := claimAccount(tx, &user, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		identity.UserID = user.UserID
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// claimAccount hands an unverified account to the owner of its email. The
+// password, second factor and pending email change are cleared, refresh
+// tokens are revoked, and API keys and unused mailed tokens are deleted.
+func claimAccount(tx *gorm.DB, user *User, now time.Time) error {
+	updates := map[string]interface{}{
+		"email_verified_at": now,
+		"password_hash":     "",
+		"pending_email":     "",
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"totp_last_counter": 0,
+	}
+	if err := setPassword(tx, user.UserID, updates, now); err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.UserID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.UserID).Delete(&APIKey{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ? AND used_at IS NULL", user.UserID).Delete(&UserToken{}).Error; err != nil {
+		return err
+	}
+
+	user.EmailVerifiedAt = &now
+	user.PasswordHash = ""
+	user.PendingEmail = ""
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastCounter = 0
+	return nil
+}
+
+// StartMFAEnrollment stores a new TOTP secret for a user who has not
+// enabled 2FA yet. Enrolling again replaces the unconfirmed secret.
+func (s *service) StartMFAEnrollment(userID, secret string) error {
+	res := s.db.Model(&User{}).Where("user_id = ? AND totp_enabled_at IS NULL", userID).
+		Update("totp_secret", secret)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableMFA confirms a user's enrollment with the time step of their first
+// code and stores their recovery codes.
+func (s *service) EnableMFA(userID string, counter int64, codeHashes []string, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&User{}).Where("user_id = ? AND totp_enabled_at IS NULL AND totp_secret <> ''", userID).
+			Updates(map[string]interface{}{"totp_enabled_at": now, "totp_last_counter": counter})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrMFANotEnrolled
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// DisableMFA turns 2FA off and deletes the user's secret and recovery codes.
+func (s *service) DisableMFA(userID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&User{}).Where("user_id = ? AND totp_enabled_at IS NOT NULL", userID).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil, "totp_last_counter": 0})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrMFANotEnabled
+		}
+		return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// UseTOTPCounter records that a code for the given time step was accepted.
+// Steps at or before the last accepted one are refused, so each code works
+// once.
+func (s *service) UseTOTPCounter(userID string, counter int64) error {
+	res := s.db.Model(&User{}).Where("user_id = ? AND totp_last_counter < ?", userID, counter).
+		Update("totp_last_counter", counter)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used.
+func (s *service) UseRecoveryCode(userID, hash string, now time.Time) error {
+	res := s.db.Model(&RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", now)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones.
+func (s *service) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// GetSecurityPolicy returns the security policy, which is empty until an
+// admin sets it.
+func (s *service) GetSecurityPolicy() (*SecurityPolicy, error) {
+	policy := SecurityPolicy{ID: 1, MFARequiredRoles: StringList{}}
+	if err := s.db.Where(SecurityPolicy{ID: 1}).FirstOrInit(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// UpdateSecurityPolicy stores the security policy.
+func (s *service) UpdateSecurityPolicy(policy *SecurityPolicy) error {
+	policy.ID = 1
+	return s.db.Save(policy).Error
+}
+
+// RecordFailedLogin stores an audit record of a rejected login.
+func (s *service) RecordFailedLogin(attempt *FailedLogin) error {
+	return s.db.Create(attempt).Error
+}
+
+// ListFailedLogins returns failed logins matching the filter, newest first.
+func (s *service) ListFailedLogins(filter FailedLoginFilter) ([]FailedLogin, error) {
+	q := s.db.Model(&FailedLogin{})
+	if filter.Email != "" {
+		q = q.Where("email = ?", filter.Email)
+	}
+	if filter.IP != "" {
+		q = q.Where("ip = ?", filter.IP)
+	}
+	var attempts []FailedLogin
+	err := q.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&attempts).Error
+	return attempts, err
+}
+
+// CreateAPIKey stores a new API key.
+func (s *service) CreateAPIKey(key *APIKey) error {
+	return s.db.Create(key).Error
+}
+
+// ListAPIKeys returns a user's API keys, newest first.
+func (s *service) ListAPIKeys(userID string) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, expired or not.
+func (s *service) GetAPIKeyByHash(hash string) (*APIKey, error) {
+	var key APIKey
+	if err := s.db.First(&key, "key_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// apiKeyTouchInterval is how stale last_used_at may get, so busy keys do
+// not write on every request.
+const apiKeyTouchInterval = time.Minute
+
+// TouchAPIKey records that a key was used at now.
+func (s *service) TouchAPIKey(keyID string, now time.Time) error {
+	return s.db.Model(&APIKey{}).
+		Where("key_id = ? AND (last_used_at IS NULL OR last_used_at < ?)", keyID, now.Add(-apiKeyTouchInterval)).
+		Update("last_used_at", now).Error
+}
+
+// DeleteAPIKey revokes one of a user's API keys.
+func (s *service) DeleteAPIKey(keyID, userID string) error {
+	result := s.db.Where("key_id = ? AND user_id = ?", keyID, userID).Delete(&APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ChangePassword sets a user's password and revokes their refresh tokens.
+func (s *service) ChangePassword(userID, passwordHash string, now time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, userID, map[string]interface{}{"password_hash": passwordHash}, now)
+	})
+}
+
+func setPassword(tx *gorm.DB, userID string, updates map[string]interface{}, now time.Time) error {
+	result := tx.Model(&User{}).Where("user_id = ?", userID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return tx.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+// ListSigningKeys returns the signing keys that have not expired at now,
+// newest first.
+func (s *service) ListSigningKeys(now time.Time) ([]SigningKey, error) {
+	var keys []SigningKey
+	err := s.db.Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// RotateSigningKey stores key as the new signing key and schedules every
+// other active key to expire at retireAt. Rotations are serialized across
+// replicas; if an active key created at or after replaceBefore exists, another
+// replica has rotated already and ErrSigningKeyFresh is returned.
+func (s *service) RotateSigningKey(key *SigningKey, retireAt, replaceBefore time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLock).Error; err != nil {
+			return err
+		}
+		var fresh int64
+		if err := tx.Model(&SigningKey{}).
+			Where("expires_at IS NULL AND created_at >= ?", replaceBefore).
+			Count(&fresh).Error; err != nil {
+			return err
+		}
+		if fresh > 0 {
+			return ErrSigningKeyFresh
+		}
+
+		if err := tx.Model(&SigningKey{}).
+			Where("expires_at IS NULL").
+			Update("expires_at", retireAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+// UpdateSigningKey replaces the stored private key of keyID, used to seal
+// keys written before they were encrypted.
+func (s *service) UpdateSigningKey(keyID, privateKey string) error {
+	return s.db.Model(&SigningKey{}).Where("key_id = ?", keyID).Update("private_key", privateKey).Error
+}
+
+// EnqueueJob stores a job to be run by the scheduler at job.RunAt.
+func (s *service) EnqueueJob(job *Job) error {
+	if job.Payload == "" {
+		job.Payload = "{}"
+	}
+	return s.db.Create(job).Error
+}
+
+// LeaseJobs atomically claims up to limit due jobs for workerID. Rows locked by
+// another replica are skipped, and jobs whose lease expired are picked up again,
+// giving at-least-once execution. Jobs whose lease expired on their last
+// attempt, because the worker crashed or overran, are marked failed instead.
+func (s *service) LeaseJobs(workerID string, now time.Time, lease time.Duration, limit int) ([]Job, error) {
+	var jobs []Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Job{}).
+			Where("completed_at IS NULL AND failed_at IS NULL AND locked_until < ? AND attempts >= max_attempts", now).
+			Updates(map[string]interface{}{
+				"failed_at":    now,
+				"locked_by":    "",
+				"locked_until": nil,
+				"last_error":   gorm.Expr("COALESCE(NULLIF(last_error, ''), 'lease expired')"),
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Raw(`
+			UPDATE jobs SET locked_by = ?, locked_until = ?, attempts = attempts + 1, updated_at = ?
+			WHERE id IN (
+				SELECT id FROM jobs
+				WHERE completed_at IS NULL AND failed_at IS NULL
+				  AND attempts < max_attempts
+				  AND run_at <= ?
+				  AND (locked_until IS NULL OR locked_until < ?)
+				ORDER BY run_at
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`,
+			workerID, now.Add(lease), now, now, now, limit,
+		).Scan(&jobs).Error
+	})
+	return jobs, err
+}
+
+// ExtendJobLease moves the lease workerID holds on a running job to until.
+// It returns ErrJobLeaseLost if another worker has leased the job since, or
+// the job was failed because its lease expired on the last attempt.
+func (s *service) ExtendJobLease(id uint, workerID string, until time.Time) error {
+	res := s.db.Model(&Job{}).
+		Where("id = ? AND locked_by = ? AND completed_at IS NULL AND failed_at IS NULL", id, workerID).
+		Update("locked_until", until)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// CompleteJob marks a leased job as done. It returns ErrJobLeaseLost if
+// another worker has leased the job since.
+func (s *service) CompleteJob(id uint, workerID string, now time.Time) error {
+	res := s.db.Model(&Job{}).
+		Where("id = ? AND locked_by = ?", id, workerID).
+		Updates(map[string]interface{}{
+			"completed_at": now,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   "",
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+// FailJob releases a leased job after a failed attempt. The job is retried at
+// retryAt unless it has used up its attempts, in which case it is marked failed.
+// It returns ErrJobLeaseLost if another worker has leased the job since.
+func (s *service) FailJob(id uint, workerID string, now time.Time, retryAt time.Time, jobErr error) error {
+	res := s.db.Model(&Job{}).
+		Where("id = ? AND locked_by = ?", id, workerID).
+		Updates(map[string]interface{}{
+			"run_at":       retryAt,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   jobErr.Error(),
+			"failed_at":    gorm.Expr("CASE WHEN attempts >= max_attempts THEN ?::timestamptz ELSE NULL END", now),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DetailsSchema is a JSON Schema template that event_details must satisfy.
+// Built-in schemas have no owner and apply by default to events in their category.
+type DetailsSchema struct {
+	SchemaID  string          `gorm:"type:varchar(255);primaryKey" json:"schema_id"`          // Unique schema identifier
+	Name      string          `gorm:"type:varchar(255);not null" json:"name"`                 // Display name
+	Category  string          `gorm:"type:varchar(100);index" json:"category"`                // Category the schema is meant for
+	UserID    string          `gorm:"type:varchar(255);index" json:"user_id,omitempty"`       // Organizer who defined it, empty for built-ins
+	BuiltIn   bool            `gorm:"not null;default:false" json:"built_in"`                 // Whether this is a platform default for its category
+	Schema    json.RawMessage `gorm:"type:jsonb;not null" json:"schema" swaggertype:"object"` // JSON Schema applied to event_details.details
+	CreatedAt time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// CreateDetailsSchemaDTO represents the data for defining a details schema.
+type CreateDetailsSchemaDTO struct {
+	Name     string                 `json:"name" validate:"required,max=255"` // Display name
+	Category string                 `json:"category"`                         // Category the schema is meant for
+	Schema   map[string]interface{} `json:"schema" validate:"required"`       // JSON Schema document
+}
+
+var ErrDetailsSchemaNotFound = errors.New("details schema not found")
+
+// builtInDetailsSchemas are seeded on startup and used for events in their
+// category that don't pick a schema explicitly.
+var builtInDetailsSchemas = []DetailsSchema{
+	{
+		SchemaID: "builtin-concert",
+		Name:     "Concert",
+		Category: "concert",
+		BuiltIn:  true,
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"required": ["performers"],
+			"properties": {
+				"performers": {"type": "array", "minItems": 1, "items": {"type": "string", "minLength": 1}},
+				"genre": {"type": "string"},
+				"age_limit": {"type": "integer", "minimum": 0, "maximum": 21},
+				"seated": {"type": "boolean"}
+			}
+		}`),
+	},
+	{
+		SchemaID: "builtin-conference",
+		Name:     "Conference",
+		Category: "conference",
+		BuiltIn:  true,
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"tracks": {"type": "array", "items": {"type": "string"}},
+				"speakers": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"required": ["name"],
+						"properties": {
+							"name": {"type": "string", "minLength": 1},
+							"title": {"type": "string"},
+							"url": {"type": "string", "format": "uri"}
+						}
+					}
+				},
+				"language": {"type": "string", "minLength": 2, "maxLength": 5},
+				"online": {"type": "boolean"}
+			}
+		}`),
+	},
+	{
+		SchemaID: "builtin-sports",
+		Name:     "Sports",
+		Category: "sports",
+		BuiltIn:  true,
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"required": ["sport"],
+			"properties": {
+				"sport": {"type": "string", "minLength": 1},
+				"home_team": {"type": "string"},
+				"away_team": {"type": "string"},
+				"league": {"type": "string"}
+			}
+		}`),
+	},
+}
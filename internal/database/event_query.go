@@ -0,0 +1,236 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned for an unknown sort key.
+var ErrInvalidSort = errors.New("invalid sort key")
+
+const remainingCapacityExpr = "events.capacity - COALESCE(sold.quantity, 0)"
+
+// eventSortColumns maps public sort keys to SQL expressions.
+var eventSortColumns = map[string]string{
+	"created_at": "events.created_at",
+	"starts_at":  "events.starts_at",
+	"name":       "events.name",
+	"capacity":   "events.capacity",
+	"remaining":  remainingCapacityExpr,
+}
+
+// eventCursor marks the position of the last row of a page under a given sort.
+type eventCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// ListEvents returns a page of events matching the filter, ordered by the
+// requested sort key with the primary key as tie-breaker.
+func (s *service) ListEvents(filter EventFilter) (*EventPage, error) {
+	sortKey, desc := strings.TrimPrefix(filter.Sort, "-"), strings.HasPrefix(filter.Sort, "-")
+	if filter.Sort == "" {
+		sortKey, desc = "created_at", true
+	}
+	sortExpr, ok := eventSortColumns[sortKey]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	sold := s.db.Model(&Ticket{}).
+		Select("event_id, SUM(quantity) AS quantity").
+		Where("status = ?", TicketStatusActive).
+		Group("event_id")
+
+	q := s.db.Model(&Event{}).
+		Select("events.*, "+remainingCapacityExpr+" AS remaining_capacity").
+		Joins("LEFT JOIN (?) AS sold ON sold.event_id = events.event_id", sold)
+
+	q, err := applyEventFilter(q, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		cur, value, err := decodeEventCursor(filter.Cursor, sortKey)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(fmt.Sprintf("(%s, events.id) %s (?, ?)", sortExpr, cmp), value, cur.ID)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var rows []EventListing
+	if err := q.Order(fmt.Sprintf("%s %s, events.id %s", sortExpr, dir, dir)).
+		Limit(limit + 1).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		rows[i].Localize()
+	}
+
+	page := &EventPage{Events: rows}
+	if len(rows) > limit {
+		page.Events = rows[:limit]
+		next, err := encodeEventCursor(sortKey, page.Events[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+func applyEventFilter(q *gorm.DB, filter EventFilter) (*gorm.DB, error) {
+	if filter.Search != "" {
+		q = q.Where("to_tsvector('english', events.name || ' ' || events.description) @@ websearch_to_tsquery('english', ?)", filter.Search)
+	}
+	if filter.UserID != "" {
+		q = q.Where("events.user_id = ?", filter.UserID)
+	}
+	q = q.Where(draftVisibilityClause, EventStatusDraft, filter.ViewerID, filter.ViewerID)
+	if filter.Status != "" {
+		q = q.Where("events.status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		q = q.Where("events.starts_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("events.starts_at < ?", *filter.To)
+	}
+	if filter.Category != "" {
+		q = q.Where(`events.category IN (
+			WITH RECURSIVE tree AS (
+				SELECT CAST(? AS varchar) AS slug
+				UNION
+				SELECT categories.slug FROM categories JOIN tree ON categories.parent_slug = tree.slug
+			)
+			SELECT slug FROM tree)`, filter.Category)
+	}
+	if len(filter.Tags) > 0 {
+		tags, err := json.Marshal(NormalizeTags(filter.Tags))
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("events.tags @> ?::jsonb", string(tags))
+	}
+	if filter.Collection != "" {
+		q = q.Where(`events.event_id IN (
+			SELECT collection_events.event_id FROM collection_events
+			JOIN collections ON collections.collection_id = collection_events.collection_id
+			WHERE collections.slug = ? AND collections.deleted_at IS NULL)`, filter.Collection)
+	}
+	if filter.MinAvailable != nil {
+		q = q.Where(remainingCapacityExpr+" >= ?", *filter.MinAvailable)
+	}
+	if len(filter.Details) > 0 {
+		details := make(map[string]interface{}, len(filter.Details))
+		for k, v := range filter.Details {
+			// Numbers and booleans match their JSON types; anything else is a string.
+			var typed interface{}
+			if err := json.Unmarshal([]byte(v), &typed); err != nil {
+				typed = v
+			}
+			setDetailPath(details, strings.Split(k, "."), typed)
+		}
+		contains, err := json.Marshal(map[string]interface{}{"details": details})
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("events.event_details @> ?::jsonb", string(contains))
+	}
+	return q, nil
+}
+
+// setDetailPath nests value under path, so "venue.section" becomes
+// {"venue": {"section": value}} for jsonb containment.
+func setDetailPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+func encodeEventCursor(sortKey string, last EventListing) (string, error) {
+	var value interface{}
+	switch sortKey {
+	case "created_at":
+		value = last.CreatedAt
+	case "starts_at":
+		value = last.StartsAt
+	case "name":
+		value = last.Name
+	case "capacity":
+		value = last.Capacity
+	case "remaining":
+		value = last.RemainingCapacity
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(eventCursor{Sort: sortKey, Value: raw, ID: last.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeEventCursor(cursor, sortKey string) (*eventCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var cur eventCursor
+	if err := json.Unmarshal(data, &cur); err != nil || cur.Sort != sortKey {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch sortKey {
+	case "created_at", "starts_at":
+		var t time.Time
+		err = json.Unmarshal(cur.Value, &t)
+		value = t
+	case "name":
+		var name string
+		err = json.Unmarshal(cur.Value, &name)
+		value = name
+	default:
+		var n int
+		err = json.Unmarshal(cur.Value, &n)
+		value = n
+	}
+	if err != nil {
+		return nil, nil, ErrInvalidCursor
+	}
+	return &cur, value, nil
+}
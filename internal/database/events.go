@@ -4,47 +4,256 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // Event represents the event model in the database.// Event represents the event model in the database.
 type Event struct {
-	gorm.Model   `swaggerignore:"true"`
-	Name         string             `gorm:"type:varchar(255);not null"`        // Name of the event
-	Description  string             `gorm:"type:text;not null"`                // Event description
-	EventID      string             `gorm:"type:varchar(255);unique;not null"` // Unique event identifier
-	Capacity     int                `gorm:"not null" json:"capacity"`          // Total capacity of the event
-	UserID       string             `json:"user_id"`
-	EventDetails EventDetailsStruct ` gorm:"type:jsonb" json:"event_details"` // Additional event details (not stored in DB)
+	gorm.Model      `swaggerignore:"true"`
+	Name            string             `gorm:"type:varchar(255);not null"`        // Name of the event
+	Description     string             `gorm:"type:text;not null"`                // Event description
+	EventID         string             `gorm:"type:varchar(255);unique;not null"` // Unique event identifier
+	Capacity        int                `gorm:"not null" json:"capacity"`          // Total capacity of the event
+	UserID          string             `json:"user_id"`
+	Category        string             `gorm:"type:varchar(100);index" json:"category"`                  // Category slug used for filtering
+	Tags            StringList         `gorm:"type:jsonb;not null;default:'[]'" json:"tags"`             // Free-form lowercase tags
+	StartsAt        time.Time          `gorm:"index" json:"starts_at"`                                   // Start of the event
+	EndsAt          time.Time          `json:"ends_at"`                                                  // End of the event
+	TimeZone        string             `gorm:"type:varchar(64);not null;default:'UTC'" json:"time_zone"` // IANA time zone the event takes place in
+	DoorsOpenAt     *time.Time         `json:"doors_open_at,omitempty"`                                  // When admission starts
+	VenueID         *string            `gorm:"type:varchar(255);index" json:"venue_id,omitempty"`        // Venue the event is held at
+	Venue           *Venue             `gorm:"foreignKey:VenueID;references:VenueID" json:"venue,omitempty"`
+	SeriesID        *string            `gorm:"type:varchar(255);index" json:"series_id,omitempty"`              // Series this event is an occurrence of
+	Status          string             `gorm:"type:varchar(20);not null;default:'on_sale';index" json:"status"` // Lifecycle state, see EventStatus constants
+	Version         int                `gorm:"not null;default:1" json:"version"`                               // Incremented on every update, exposed as the ETag
+	DetailsSchemaID *string            `gorm:"type:varchar(255)" json:"details_schema_id,omitempty"`            // Schema event_details must satisfy; defaults to the category's built-in
+	EventDetails    EventDetailsStruct ` gorm:"type:jsonb" json:"event_details"`                                // Additional event details, validated against the details schema
 	// Tickets      []Ticket           `gorm:"foreignKey:EventID" json:"tickets"` // Associated tickets
 }
 
 // CreateEventDTO represents the data for creating a new event.
 type CreateEventDTO struct {
-	Name         string             `json:"name" validate:"required,min=3,max=255"` // Event name
-	Description  string             `json:"description" validate:"required"`        // Event description
-	Capacity     int                `json:"capacity" validate:"required,min=1"`     // Total capacity of the event
-	EventDetails EventDetailsStruct ` gorm:"type:jsonb" json:"event_details"`
+	Name            string             `json:"name" validate:"required,min=3,max=255"` // Event name
+	Description     string             `json:"description" validate:"required"`        // Event description
+	Capacity        int                `json:"capacity" validate:"required,min=1"`     // Total capacity of the event
+	Category        string             `json:"category"`                               // Category slug
+	Tags            []string           `json:"tags"`                                   // Free-form tags
+	StartsAt        time.Time          `json:"starts_at" validate:"required"`          // Start time (RFC3339)
+	EndsAt          time.Time          `json:"ends_at" validate:"required"`            // End time (RFC3339), after starts_at
+	TimeZone        string             `json:"time_zone"`                              // IANA time zone, defaults to UTC
+	DoorsOpenAt     *time.Time         `json:"doors_open_at"`                          // Optional doors-open time, not after starts_at
+	VenueID         *string            `json:"venue_id"`                               // Optional venue reference
+	DetailsSchemaID *string            `json:"details_schema_id"`                      // Optional details schema; defaults to the category's built-in
+	EventDetails    EventDetailsStruct ` gorm:"type:jsonb" json:"event_details"`
+}
+
+// Event lifecycle states.
+const (
+	EventStatusDraft     = "draft"     // Visible to the owner and event team only
+	EventStatusPublished = "published" // Visible to everyone, not yet bookable
+	EventStatusOnSale    = "on_sale"   // Visible and bookable
+	EventStatusCancelled = "cancelled" // Terminal; all tickets are cancelled
+	EventStatusCompleted = "completed" // Terminal; the event has taken place
+)
+
+// listableStatuses are the states counted in category and tag facets:
+// events people can still browse to and attend.
+var listableStatuses = []string{EventStatusPublished, EventStatusOnSale}
+
+// eventTransitions lists the states each state may move to.
+var eventTransitions = map[string][]string{
+	EventStatusDraft:     {EventStatusPublished, EventStatusCancelled},
+	EventStatusPublished: {EventStatusDraft, EventStatusOnSale, EventStatusCancelled, EventStatusCompleted},
+	EventStatusOnSale:    {EventStatusPublished, EventStatusCancelled, EventStatusCompleted},
+}
+
+// ErrInvalidTransition is returned when an event cannot move to the requested state.
+var ErrInvalidTransition = errors.New("invalid event status transition")
+
+// ErrEventNotOnSale is returned when booking an event that is not on sale.
+var ErrEventNotOnSale = errors.New("event is not on sale")
+
+// CanTransition reports whether the event may move from its current state to next.
+func (e *Event) CanTransition(next string) bool {
+	for _, allowed := range eventTransitions[e.Status] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether the event is cancelled or completed.
+func (e *Event) IsTerminal() bool {
+	return e.Status == EventStatusCancelled || e.Status == EventStatusCompleted
+}
+
+// VisibleTo reports whether userID may see the event without team access;
+// drafts are owner-only. Handlers use access.CanView to include the team.
+func (e *Event) VisibleTo(userID string) bool {
+	return e.Status != EventStatusDraft || e.UserID == userID
+}
+
+// ErrVersionConflict is returned when an event changed since it was read.
+var ErrVersionConflict = errors.New("event was modified concurrently")
+
+// ETag returns the entity tag for the event's current version.
+func (e *Event) ETag() string {
+	return fmt.Sprintf(`"%d"`, e.Version)
+}
+
+// Editable returns the fields organizers may change, in the shape accepted
+// by PUT and patched by PATCH /events/:id.
+func (e *Event) Editable() CreateEventDTO {
+	return CreateEventDTO{
+		Name:            e.Name,
+		Description:     e.Description,
+		Capacity:        e.Capacity,
+		Category:        e.Category,
+		Tags:            e.Tags,
+		StartsAt:        e.StartsAt,
+		EndsAt:          e.EndsAt,
+		TimeZone:        e.TimeZone,
+		DoorsOpenAt:     e.DoorsOpenAt,
+		VenueID:         e.VenueID,
+		DetailsSchemaID: e.DetailsSchemaID,
+		EventDetails:    e.EventDetails,
+	}
+}
+
+// ApplyEditable overwrites the event's editable fields.
+func (e *Event) ApplyEditable(dto CreateEventDTO) {
+	e.Name = dto.Name
+	e.Description = dto.Description
+	e.Capacity = dto.Capacity
+	e.Category = dto.Category
+	e.Tags = NormalizeTags(dto.Tags)
+	e.StartsAt = dto.StartsAt
+	e.EndsAt = dto.EndsAt
+	e.TimeZone = dto.TimeZone
+	e.DoorsOpenAt = dto.DoorsOpenAt
+	e.VenueID = dto.VenueID
+	e.Venue = nil
+	e.DetailsSchemaID = dto.DetailsSchemaID
+	e.EventDetails = dto.EventDetails
+}
+
+// Schedule validation errors.
+var (
+	ErrScheduleMissing = errors.New("starts_at and ends_at are required")
+	ErrScheduleOrder   = errors.New("ends_at must be after starts_at")
+	ErrDoorsAfterStart = errors.New("doors_open_at must not be after starts_at")
+	ErrInvalidTimeZone = errors.New("time_zone must be a valid IANA time zone")
+	ErrEventInPast     = errors.New("event has already ended")
+	ErrStartsInPast    = errors.New("starts_at must be in the future")
+	ErrVenueNotFound   = errors.New("venue not found")
+)
+
+// ValidateSchedule checks the event's start, end, doors-open time and time zone.
+func (e *Event) ValidateSchedule() error {
+	if e.StartsAt.IsZero() || e.EndsAt.IsZero() {
+		return ErrScheduleMissing
+	}
+	if !e.EndsAt.After(e.StartsAt) {
+		return ErrScheduleOrder
+	}
+	if e.DoorsOpenAt != nil && e.DoorsOpenAt.After(e.StartsAt) {
+		return ErrDoorsAfterStart
+	}
+	if e.TimeZone == "" {
+		e.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(e.TimeZone); err != nil {
+		return ErrInvalidTimeZone
+	}
+	return nil
+}
+
+// HasEnded reports whether the event finished before now.
+func (e *Event) HasEnded(now time.Time) bool {
+	return !e.EndsAt.IsZero() && e.EndsAt.Before(now)
+}
+
+// Localize converts the event's timestamps into its own time zone so that
+// responses render local times with the correct offset.
+func (e *Event) Localize() {
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		return
+	}
+	e.StartsAt = e.StartsAt.In(loc)
+	e.EndsAt = e.EndsAt.In(loc)
+	if e.DoorsOpenAt != nil {
+		doors := e.DoorsOpenAt.In(loc)
+		e.DoorsOpenAt = &doors
+	}
+}
+
+// AfterFind renders loaded events in their own time zone.
+func (e *Event) AfterFind(tx *gorm.DB) error {
+	e.Localize()
+	return nil
+}
+
+// EventListing is an event together with its computed remaining capacity.
+type EventListing struct {
+	Event
+	RemainingCapacity int `json:"remaining_capacity"`
+}
+
+// EventFilter holds the search, filter, sort and pagination options for listing events.
+type EventFilter struct {
+	Search       string            // Full-text search over name and description
+	UserID       string            // Organizer filter
+	ViewerID     string            // Requesting user; drafts owned by others are hidden
+	Status       string            // Lifecycle state filter
+	From         *time.Time        // Lower bound on the start time (inclusive)
+	To           *time.Time        // Upper bound on the start time (exclusive)
+	Category     string            // Category slug; events in descendant categories match too
+	Tags         []string          // Events must carry every tag
+	Collection   string            // Collection slug
+	MinAvailable *int              // Minimum remaining capacity
+	Details      map[string]string // Exact matches on keys inside EventDetails; dots address nested objects
+	Sort         string            // Sort key, prefixed with "-" for descending
+	Cursor       string            // Opaque cursor returned by the previous page
+	Limit        int               // Page size
+}
+
+// EventPage is a single page of listed events.
+type EventPage struct {
+	Events     []EventListing `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 type EventDetailsStruct struct {
 	Details map[string]interface{} ` gorm:"type:jsonb" json:"details"`
 }
 
-// Scan implements the `sql.Scanner` interface for EventDetails.
-func (m EventDetailsStruct) Scan(value interface{}) error {
-	if value == nil {
+// Scan implements the `sql.Scanner` interface for EventDetails. It needs a
+// pointer receiver so the decoded details end up on the loaded event.
+func (m *EventDetailsStruct) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = EventDetailsStruct{}
 		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("invalid data type for EventDetails")
 	}
-	if data, ok := value.([]byte); ok {
-		err := json.Unmarshal(data, &m) // Unmarshal into the map
-		if err != nil {
-			return err
-		}
-		return nil
+
+	var decoded EventDetailsStruct
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
 	}
-	return errors.New("invalid data type for EventDetails")
+	*m = decoded
+	return nil
 }
 
 // Value implements the `driver.Valuer` interface for EventDetails.
@@ -53,5 +262,5 @@ func (m EventDetailsStruct) Value() (driver.Value, error) {
 	if err != nil {
 		return nil, err
 	}
-	return serialized, nil // Return the serialized JSON
+	return string(serialized), nil // jsonb accepts the JSON text as-is
 }
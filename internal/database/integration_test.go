@@ -0,0 +1,47 @@
+//go:build integration
+
+package database
+
+import (
+	"os"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to TEST_DATABASE_DSN, migrates models and empties their
+// tables. Run against a throwaway Postgres database:
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres dbname=ticketing_test sslmode=disable" \
+//		go test -tags integration ./internal/database/
+func testDB(t *testing.T, models ...interface{}) *service {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("TRUNCATE " + stmt.Table + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &service{db: db}
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// Job is a unit of delayed work picked up by the scheduler once RunAt has passed.
+type Job struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Name        string     `gorm:"type:varchar(100);not null;index" json:"name"`    // Registered handler name
+	Payload     string     `gorm:"type:jsonb;not null;default:'{}'" json:"payload"` // Handler-specific JSON payload
+	RunAt       time.Time  `gorm:"not null;index" json:"run_at"`                    // Earliest time the job may run
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`              // Number of times the job was leased
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`          // Attempts before the job is marked failed
+	LockedBy    string     `gorm:"type:varchar(100)" json:"locked_by,omitempty"`    // Worker holding the lease
+	LockedUntil *time.Time `json:"locked_until,omitempty"`                          // Lease expiry; expired leases are re-run
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`           // Error from the most recent attempt
+	CompletedAt *time.Time `json:"completed_at,omitempty"`                          // Set once a handler succeeds
+	FailedAt    *time.Time `json:"failed_at,omitempty"`                             // Set once MaxAttempts is exhausted
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ErrJobLeaseLost is returned when a worker reports on a job another worker
+// has leased since.
+var ErrJobLeaseLost = errors.New("job lease was taken over by another worker")
@@ -0,0 +1,188 @@
+//go:build integration
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testJobsDB(t *testing.T) *service {
+	return testDB(t, &Job{})
+}
+
+func enqueue(t *testing.T, s *service, name string, runAt time.Time) *Job {
+	t.Helper()
+	job := &Job{Name: name, RunAt: runAt}
+	if err := s.EnqueueJob(job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func storedJob(t *testing.T, s *service, id uint) Job {
+	t.Helper()
+	var job Job
+	if err := s.db.First(&job, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func lease(t *testing.T, s *service, worker string, now time.Time, limit int) []Job {
+	t.Helper()
+	jobs, err := s.LeaseJobs(worker, now, time.Minute, limit)
+	if err != nil {
+		t.Fatalf("LeaseJobs: %v", err)
+	}
+	return jobs
+}
+
+func TestLeaseJobsOrderAndLimit(t *testing.T) {
+	s := testJobsDB(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	later := enqueue(t, s, "b", now.Add(-time.Minute))
+	first := enqueue(t, s, "a", now.Add(-time.Hour))
+	enqueue(t, s, "future", now.Add(time.Hour))
+
+	jobs := lease(t, s, "w1", now, 1)
+	if len(jobs) != 1 || jobs[0].ID != first.ID {
+		t.Fatalf("leased %+v, want the job due first", jobs)
+	}
+	if got := jobs[0]; got.Attempts != 1 || got.LockedBy != "w1" || got.LockedUntil == nil || !got.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Errorf("leased job = %+v", got)
+	}
+
+	jobs = lease(t, s, "w2", now, 10)
+	if len(jobs) != 1 || jobs[0].ID != later.ID {
+		t.Fatalf("second lease got %+v, want only the other due job", jobs)
+	}
+	if jobs := lease(t, s, "w3", now, 10); len(jobs) != 0 {
+		t.Errorf("leased %d jobs that are held or not due", len(jobs))
+	}
+}
+
+func TestLeaseJobsSkipsLockedRows(t *testing.T) {
+	s := testJobsDB(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	locked := enqueue(t, s, "a", now.Add(-time.Hour))
+	free := enqueue(t, s, "b", now.Add(-time.Minute))
+
+	// Another replica is in the middle of leasing the first job
+	tx := s.db.Begin()
+	defer tx.Rollback()
+	var id uint
+	if err := tx.Raw("SELECT id FROM jobs WHERE id = ? FOR UPDATE", locked.ID).Scan(&id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []Job)
+	go func() {
+		jobs, _ := s.LeaseJobs("w1", now, time.Minute, 10)
+		done <- jobs
+	}()
+	select {
+	case jobs := <-done:
+		if len(jobs) != 1 || jobs[0].ID != free.ID {
+			t.Errorf("leased %+v, want only the unlocked job", jobs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("LeaseJobs blocked on a locked row")
+	}
+}
+
+func TestExpiredLeaseReleasedThenFailed(t *testing.T) {
+	s := testJobsDB(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	job := enqueue(t, s, "a", now)
+	if err := s.db.Model(&Job{}).Where("id = ?", job.ID).Update("max_attempts", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	lease(t, s, "crashed", now, 10)
+	if jobs := lease(t, s, "w2", now.Add(30*time.Second), 10); len(jobs) != 0 {
+		t.Fatal("job leased again before its lease expired")
+	}
+	now = now.Add(2 * time.Minute)
+	if jobs := lease(t, s, "w2", now, 10); len(jobs) != 1 || jobs[0].Attempts != 2 {
+		t.Fatalf("expired lease: leased %+v, want the job on attempt 2", jobs)
+	}
+
+	// The second worker dies too, on the last attempt
+	now = now.Add(2 * time.Minute)
+	if jobs := lease(t, s, "w3", now, 10); len(jobs) != 0 {
+		t.Fatalf("leased %+v after max attempts", jobs)
+	}
+	got := storedJob(t, s, job.ID)
+	if got.FailedAt == nil || got.LockedBy != "" || got.LockedUntil != nil || got.LastError != "lease expired" {
+		t.Errorf("job = %+v, want failed with lease expired", got)
+	}
+}
+
+func TestFailJob(t *testing.T) {
+	s := testJobsDB(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	job := enqueue(t, s, "a", now)
+	if err := s.db.Model(&Job{}).Where("id = ?", job.ID).Update("max_attempts", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	lease(t, s, "w1", now, 10)
+	if err := s.FailJob(job.ID, "w2", now, now, errors.New("boom")); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("FailJob by another worker = %v, want ErrJobLeaseLost", err)
+	}
+	retryAt := now.Add(time.Minute)
+	if err := s.FailJob(job.ID, "w1", now, retryAt, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	got := storedJob(t, s, job.ID)
+	if got.FailedAt != nil || !got.RunAt.Equal(retryAt) || got.LockedBy != "" || got.LastError != "boom" {
+		t.Fatalf("after attempt 1: job = %+v, want a retry at %v", got, retryAt)
+	}
+	if jobs := lease(t, s, "w1", now, 10); len(jobs) != 0 {
+		t.Fatal("job retried before retry_at")
+	}
+
+	lease(t, s, "w1", retryAt, 10)
+	if err := s.FailJob(job.ID, "w1", retryAt, retryAt.Add(time.Minute), errors.New("boom again")); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedJob(t, s, job.ID); got.FailedAt == nil || !got.FailedAt.Equal(retryAt) {
+		t.Errorf("after the last attempt: job = %+v, want failed", got)
+	}
+}
+
+func TestExtendAndCompleteJob(t *testing.T) {
+	s := testJobsDB(t)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	job := enqueue(t, s, "a", now)
+	lease(t, s, "w1", now, 10)
+
+	until := now.Add(5 * time.Minute)
+	if err := s.ExtendJobLease(job.ID, "w1", until); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedJob(t, s, job.ID); got.LockedUntil == nil || !got.LockedUntil.Equal(until) {
+		t.Errorf("locked until %v, want %v", got.LockedUntil, until)
+	}
+	if jobs := lease(t, s, "w2", now.Add(2*time.Minute), 10); len(jobs) != 0 {
+		t.Error("renewed lease taken by another worker")
+	}
+	if err := s.ExtendJobLease(job.ID, "w2", until); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("ExtendJobLease by another worker = %v, want ErrJobLeaseLost", err)
+	}
+	if err := s.CompleteJob(job.ID, "w2", now); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("CompleteJob by another worker = %v, want ErrJobLeaseLost", err)
+	}
+
+	if err := s.CompleteJob(job.ID, "w1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExtendJobLease(job.ID, "w1", until); !errors.Is(err, ErrJobLeaseLost) {
+		t.Errorf("ExtendJobLease after completion = %v, want ErrJobLeaseLost", err)
+	}
+	if jobs := lease(t, s, "w2", until.Add(time.Hour), 10); len(jobs) != 0 {
+		t.Error("completed job leased again")
+	}
+}
@@ -0,0 +1,32 @@
+package database
+
+import "time"
+
+// FailedLogin is an audit record of a rejected login attempt. Records are
+// kept for unknown emails too, so attacks on non-existent accounts show up.
+type FailedLogin struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Email     string    `gorm:"type:varchar(255);not null;index" json:"email"`
+	UserID    *string   `gorm:"type:varchar(255);index" json:"user_id,omitempty"` // Nil when no account has the email
+	IP        string    `gorm:"type:varchar(64);not null;index" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(512)" json:"user_agent"`
+	Reason    string    `gorm:"type:varchar(30);not null" json:"reason"` // See the FailedLoginReason constants
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// Reasons a login attempt was rejected.
+const (
+	FailedLoginReasonUnknownEmail  = "unknown_email"
+	FailedLoginReasonWrongPassword = "wrong_password"
+	FailedLoginReasonWrongMFACode  = "wrong_mfa_code"
+	FailedLoginReasonThrottled     = "throttled"
+	FailedLoginReasonLocked        = "locked"
+)
+
+// FailedLoginFilter holds the filters for listing failed logins.
+type FailedLoginFilter struct {
+	Email  string
+	IP     string
+	Limit  int
+	Offset int
+}
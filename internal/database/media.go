@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Media kinds.
+const (
+	MediaKindCover   = "cover"   // The event's main image; an event has at most one
+	MediaKindGallery = "gallery" // Additional images shown with the event
+)
+
+// EventMedia is an image uploaded for an event. The files themselves live in
+// the media storage under Key and ThumbnailKey.
+type EventMedia struct {
+	gorm.Model   `swaggerignore:"true"`
+	MediaID      string `gorm:"type:varchar(255);unique;not null" json:"media_id"` // Unique media identifier
+	EventID      string `gorm:"type:varchar(255);not null;index" json:"event_id"`  // Event the image belongs to
+	UserID       string `gorm:"type:varchar(255);not null" json:"user_id"`         // Uploader
+	Kind         string `gorm:"type:varchar(20);not null" json:"kind"`             // cover or gallery
+	ContentType  string `gorm:"type:varchar(50);not null" json:"content_type"`
+	Size         int64  `gorm:"not null" json:"size"` // Size of the original in bytes
+	Width        int    `gorm:"not null" json:"width"`
+	Height       int    `gorm:"not null" json:"height"`
+	Key          string `gorm:"type:varchar(512);not null" json:"-"` // Storage key of the original
+	ThumbnailKey string `gorm:"type:varchar(512);not null" json:"-"` // Storage key of the thumbnail
+	Position     int    `gorm:"not null;default:0" json:"position"`  // Sort order inside the gallery
+}
+
+// ErrMediaNotFound is returned when an event image does not exist.
+var ErrMediaNotFound = errors.New("media not found")
+
+// ErrInvalidMediaKind is returned for kinds other than cover and gallery.
+var ErrInvalidMediaKind = errors.New("kind must be cover or gallery")
+
+// ValidMediaKind reports whether kind is a known media kind.
+func ValidMediaKind(kind string) bool {
+	return kind == MediaKindCover || kind == MediaKindGallery
+}
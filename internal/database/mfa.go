@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is lost. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	UserID    string     `gorm:"type:varchar(255);not null;index" json:"-"`
+	CodeHash  string     `gorm:"type:varchar(64);not null;index" json:"-"` // SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"-"`
+}
+
+// SecurityPolicy holds the platform-wide security settings. There is a
+// single row, with ID 1.
+type SecurityPolicy struct {
+	ID               uint       `gorm:"primaryKey" json:"-"`
+	MFARequiredRoles StringList `gorm:"type:jsonb;not null;default:'[]'" json:"mfa_required_roles"` // Platform roles that must enable 2FA to use their privileges
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// RequiresMFA reports whether users with role must enable 2FA.
+func (p *SecurityPolicy) RequiresMFA(role string) bool {
+	for _, r := range p.MFARequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SecurityPolicyDTO represents an admin changing the security policy.
+type SecurityPolicyDTO struct {
+	MFARequiredRoles []string `json:"mfa_required_roles" validate:"dive,oneof=organizer admin"`
+}
+
+// MFACodeDTO represents a TOTP code or a recovery code.
+type MFACodeDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFALoginDTO represents the second step of a login with 2FA.
+type MFALoginDTO struct {
+	MFAToken string `json:"mfa_token" validate:"required"` // Challenge token returned by /users/login
+	Code     string `json:"code" validate:"required"`      // TOTP code or recovery code
+}
+
+// DisableMFADTO represents a user turning 2FA off.
+type DisableMFADTO struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"` // TOTP code or recovery code
+}
+
+// Two-factor authentication errors.
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("start two-factor enrollment first")
+	ErrMFACodeInvalid    = errors.New("invalid two-factor code")
+)
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemaMigration records a one-off data migration that has run.
+type SchemaMigration struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// backfill is a one-off data migration that fills in a column added to
+// tables that already held rows.
+type backfill struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// Data migration names, as recorded in schema_migrations.
+const (
+	backfillOrganizerRoles = "backfill_organizer_roles"
+	backfillTicketOwners   = "backfill_ticket_owners"
+	backfillEmailVerified  = "backfill_email_verified"
+)
+
+// backfills run in order after AutoMigrate. Each runs once per database, in
+// the transaction that records it, so replicas starting together cannot run
+// one twice.
+var backfills = []backfill{
+	// Users who already run events become organizers when roles are introduced
+	{backfillOrganizerRoles, func(tx *gorm.DB) error {
+		return tx.Model(&User{}).
+			Where("role = ? AND user_id IN (SELECT user_id FROM events)", RoleAttendee).
+			Updates(map[string]interface{}{"role": RoleOrganizer, "organizer_status": OrganizerStatusApproved}).Error
+	}},
+	// Bookings made before tickets recorded their account belong to the
+	// user with the ticket's email
+	{backfillTicketOwners, func(tx *gorm.DB) error {
+		return tx.Exec(`UPDATE tickets SET user_id = users.user_id FROM users
+			WHERE tickets.user_id = '' AND LOWER(tickets.email) = LOWER(users.email)`).Error
+	}},
+	// Accounts created before email verification existed stay usable
+	{backfillEmailVerified, func(tx *gorm.DB) error {
+		return tx.Model(&User{}).Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error
+	}},
+}
+
+// appliedBeforeLedger returns the backfills a database created before
+// schema_migrations existed has already run, judged by the columns and
+// indexes AutoMigrate had added. It must be called before AutoMigrate, and
+// only when the schema_migrations table does not exist yet. New databases
+// have no rows to backfill, so every backfill counts as applied.
+func appliedBeforeLedger(db *gorm.DB) []string {
+	m := db.Migrator()
+	var applied []string
+	if !m.HasTable(&User{}) || m.HasColumn(&User{}, "Role") {
+		applied = append(applied, backfillOrganizerRoles)
+	}
+	if !m.HasTable(&Ticket{}) || m.HasIndex(&Ticket{}, "UserID") {
+		applied = append(applied, backfillTicketOwners)
+	}
+	if !m.HasTable(&User{}) || m.HasColumn(&User{}, "EmailVerifiedAt") {
+		applied = append(applied, backfillEmailVerified)
+	}
+	return applied
+}
+
+// runBackfills records alreadyApplied, then runs every backfill that
+// schema_migrations does not list yet.
+func runBackfills(db *gorm.DB, alreadyApplied []string) error {
+	now := time.Now()
+	for _, name := range alreadyApplied {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&SchemaMigration{Name: name, AppliedAt: now}).Error; err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", name, err)
+		}
+	}
+
+	for _, b := range backfills {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Another replica inserting the same name holds this insert
+			// until it commits, after which it does nothing
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&SchemaMigration{Name: b.name, AppliedAt: now})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return b.run(tx)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to run migration %s: %w", b.name, err)
+		}
+	}
+	return nil
+}
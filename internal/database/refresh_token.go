@@ -0,0 +1,32 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// RefreshToken is a long-lived, single-use credential exchanged for a new
+// access token. Each refresh rotates it: the presented token is marked used
+// and a new one in the same family is issued. Presenting a used token again
+// means it leaked, so the whole family is revoked.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"` // SHA-256 of the opaque token
+	FamilyID  string     `gorm:"type:varchar(255);not null;index" json:"-"`      // Shared by every token rotated from the same login
+	UserID    string     `gorm:"type:varchar(255);not null;index" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"-"` // Set when the token is rotated
+	RevokedAt *time.Time `json:"-"` // Set on logout or reuse detection
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"-"`
+}
+
+// RefreshDTO represents a refresh or logout request.
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Refresh token errors.
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all sessions from this login were revoked")
+)
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventSeries groups recurring occurrences that share a recurrence rule.
+// Each occurrence is materialized as its own Event with its own capacity and bookings.
+type EventSeries struct {
+	gorm.Model      `swaggerignore:"true"`
+	SeriesID        string             `gorm:"type:varchar(255);unique;not null" json:"series_id"`   // Unique series identifier
+	UserID          string             `gorm:"type:varchar(255);not null" json:"user_id"`            // Organizer owning the series
+	Name            string             `gorm:"type:varchar(255);not null" json:"name"`               // Default occurrence name
+	Description     string             `gorm:"type:text;not null" json:"description"`                // Default occurrence description
+	Capacity        int                `gorm:"not null" json:"capacity"`                             // Default capacity per occurrence
+	Category        string             `gorm:"type:varchar(100)" json:"category"`                    // Category slug
+	TimeZone        string             `gorm:"type:varchar(64);not null" json:"time_zone"`           // IANA time zone the rule is evaluated in
+	VenueID         *string            `gorm:"type:varchar(255)" json:"venue_id,omitempty"`          // Default venue
+	StartsAt        time.Time          `json:"starts_at"`                                            // Start of the first occurrence (DTSTART)
+	DurationMinutes int                `gorm:"not null" json:"duration_minutes"`                     // Length of each occurrence
+	RRule           string             `gorm:"type:varchar(255)" json:"rrule,omitempty"`             // RRULE, e.g. FREQ=WEEKLY;BYDAY=FR;COUNT=10
+	RDates          TimeList           `gorm:"type:jsonb" json:"rdates,omitempty"`                   // Extra occurrence start times
+	ExDates         TimeList           `gorm:"type:jsonb" json:"exdates,omitempty"`                  // Excluded occurrence start times
+	DetailsSchemaID *string            `gorm:"type:varchar(255)" json:"details_schema_id,omitempty"` // Schema event_details must satisfy
+	EventDetails    EventDetailsStruct `gorm:"type:jsonb" json:"event_details"`                      // Default event details
+}
+
+// CreateSeriesDTO represents the data for creating an event series.
+type CreateSeriesDTO struct {
+	Name            string             `json:"name" validate:"required,min=3,max=255"` // Occurrence name
+	Description     string             `json:"description" validate:"required"`        // Occurrence description
+	Capacity        int                `json:"capacity" validate:"required,min=1"`     // Capacity per occurrence
+	Category        string             `json:"category"`                               // Category slug
+	TimeZone        string             `json:"time_zone"`                              // IANA time zone, defaults to UTC
+	VenueID         *string            `json:"venue_id"`                               // Optional venue reference
+	StartsAt        time.Time          `json:"starts_at" validate:"required"`          // Start of the first occurrence
+	EndsAt          time.Time          `json:"ends_at" validate:"required"`            // End of the first occurrence
+	RRule           string             `json:"rrule"`                                  // Recurrence rule
+	RDates          []time.Time        `json:"rdates"`                                 // Specific extra dates
+	ExDates         []time.Time        `json:"exdates"`                                // Dates to skip
+	DetailsSchemaID *string            `json:"details_schema_id"`                      // Optional details schema
+	EventDetails    EventDetailsStruct `json:"event_details"`
+}
+
+// SeriesEditDTO is a partial update applied to one or more occurrences.
+type SeriesEditDTO struct {
+	Name            *string             `json:"name"`                                        // New name
+	Description     *string             `json:"description"`                                 // New description
+	Capacity        *int                `json:"capacity" validate:"omitempty,min=1"`         // New capacity, not below tickets sold
+	VenueID         *string             `json:"venue_id"`                                    // New venue
+	StartTime       *string             `json:"start_time"`                                  // New local start time as HH:MM
+	DurationMinutes *int                `json:"duration_minutes" validate:"omitempty,min=1"` // New duration
+	EventDetails    *EventDetailsStruct `json:"event_details"`                               // New event details
+}
+
+// Series edit scopes.
+const (
+	EditScopeThis      = "this"
+	EditScopeFollowing = "following"
+	EditScopeAll       = "all"
+)
+
+// SeriesWithEvents is a series together with its materialized occurrences.
+type SeriesWithEvents struct {
+	EventSeries
+	Occurrences []Event `json:"occurrences"`
+}
+
+var (
+	ErrSeriesNotFound    = errors.New("series not found")
+	ErrNotSeriesMember   = errors.New("event does not belong to this series")
+	ErrCapacityBelowSold = errors.New("capacity cannot be lower than tickets already sold")
+	ErrNoOccurrences     = errors.New("recurrence produced no occurrences")
+)
+
+// TimeList is a list of timestamps stored as a JSON array.
+type TimeList []time.Time
+
+// Scan implements the `sql.Scanner` interface for TimeList.
+func (l *TimeList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("invalid data type for TimeList")
+}
+
+// Value implements the `driver.Valuer` interface for TimeList.
+func (l TimeList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	serialized, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(serialized), nil
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// SigningKey is an asymmetric key used to sign access tokens. The newest key
+// without an ExpiresAt signs new tokens; rotated-out keys keep verifying
+// tokens they signed until ExpiresAt.
+type SigningKey struct {
+	KeyID      string     `gorm:"primaryKey;type:varchar(64)" json:"kid"` // Published as the JWT "kid" header
+	Algorithm  string     `gorm:"type:varchar(16);not null" json:"alg"`   // RS256 or EdDSA
+	PrivateKey string     `gorm:"type:text;not null" json:"-"`            // PKCS #8 DER sealed with JWT_KEY_ENCRYPTION_KEY
+	CreatedAt  time.Time  `gorm:"not null;index" json:"created_at"`       // When the key started signing
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`      // Set on rotation; the key is dropped afterwards
+}
+
+// ErrSigningKeyFresh is returned when a rotation finds that another replica
+// already installed a new signing key.
+var ErrSigningKeyFresh = errors.New("signing key was rotated concurrently")
+
+// signingKeyLock is the advisory lock id serializing key rotations.
+const signingKeyLock = 0x6b657973 // "keys"
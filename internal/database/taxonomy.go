@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Category is a node in the hierarchical event taxonomy, e.g. music > concert.
+type Category struct {
+	Slug       string    `gorm:"type:varchar(100);primaryKey" json:"slug"`             // URL-safe identifier stored on events
+	Name       string    `gorm:"type:varchar(255);not null" json:"name"`               // Display name
+	ParentSlug *string   `gorm:"type:varchar(100);index" json:"parent_slug,omitempty"` // Parent category, nil for top-level
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// CategoryCount is a category with the number of visible events in it and
+// all of its descendants, for facet navigation.
+type CategoryCount struct {
+	Category
+	EventCount int `json:"event_count"`
+}
+
+// CreateCategoryDTO represents the data for creating a category.
+type CreateCategoryDTO struct {
+	Slug       string  `json:"slug" validate:"required"` // Lowercase letters, digits and dashes
+	Name       string  `json:"name" validate:"required"` // Display name
+	ParentSlug *string `json:"parent_slug"`              // Optional parent category
+}
+
+// TagCount is a tag with the number of visible events carrying it.
+type TagCount struct {
+	Tag        string `json:"tag"`
+	EventCount int    `json:"event_count"`
+}
+
+// Collection is a curated list of events such as "This weekend" or "Family friendly".
+type Collection struct {
+	gorm.Model   `swaggerignore:"true"`
+	CollectionID string `gorm:"type:varchar(255);unique;not null" json:"collection_id"` // Unique collection identifier
+	Slug         string `gorm:"type:varchar(100);unique;not null" json:"slug"`          // URL-safe identifier
+	Name         string `gorm:"type:varchar(255);not null" json:"name"`                 // Display name
+	Description  string `gorm:"type:text" json:"description"`                           // Optional blurb
+	UserID       string `gorm:"type:varchar(255);not null" json:"user_id"`              // Curator
+}
+
+// CollectionEvent places an event in a collection.
+type CollectionEvent struct {
+	CollectionID string    `gorm:"type:varchar(255);primaryKey" json:"collection_id"`
+	EventID      string    `gorm:"type:varchar(255);primaryKey;index" json:"event_id"`
+	Position     int       `gorm:"not null;default:0" json:"position"` // Sort order inside the collection
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// CreateCollectionDTO represents the data for creating a collection.
+type CreateCollectionDTO struct {
+	Slug        string `json:"slug" validate:"required"` // Lowercase letters, digits and dashes
+	Name        string `json:"name" validate:"required"` // Display name
+	Description string `json:"description"`              // Optional blurb
+}
+
+// AddCollectionEventDTO represents an event being added to a collection.
+type AddCollectionEventDTO struct {
+	EventID  string `json:"event_id" validate:"required"` // Event to add
+	Position int    `json:"position"`                     // Sort order inside the collection
+}
+
+// CollectionWithEvents is a collection together with its visible events.
+type CollectionWithEvents struct {
+	Collection
+	Events []Event `json:"events"`
+}
+
+var (
+	ErrCategoryNotFound   = errors.New("category not found")
+	ErrCategoryExists     = errors.New("category already exists")
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrCollectionExists   = errors.New("collection already exists")
+	ErrInvalidSlug        = errors.New("slug must be lowercase letters, digits and dashes")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug reports whether s is a well-formed slug.
+func ValidSlug(s string) bool {
+	return len(s) <= 100 && slugPattern.MatchString(s)
+}
+
+// builtInCategories are seeded on startup alongside the built-in details schemas.
+var builtInCategories = []Category{
+	{Slug: "concert", Name: "Concerts"},
+	{Slug: "conference", Name: "Conferences"},
+	{Slug: "sports", Name: "Sports"},
+}
+
+// StringList is a list of strings stored as a JSON array, used for event tags
+// and policy role lists.
+type StringList []string
+
+// NormalizeTags lowercases, trims and de-duplicates tags.
+func NormalizeTags(tags []string) StringList {
+	seen := make(map[string]bool, len(tags))
+	out := make(StringList, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// Scan implements the `sql.Scanner` interface for StringList.
+func (l *StringList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	}
+	return errors.New("invalid data type for StringList")
+}
+
+// Value implements the `driver.Valuer` interface for StringList.
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	serialized, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(serialized), nil
+}
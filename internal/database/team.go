@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// Event team roles. The event owner (Event.UserID) is not a member; it
+// implicitly holds every permission.
+const (
+	TeamRoleCoOrganizer = "co_organizer" // Helps run the event
+	TeamRoleBoxOffice   = "box_office"   // Sells and refunds tickets at the door
+	TeamRoleCheckIn     = "check_in"     // Scans tickets at the entrance
+)
+
+// EventMember grants a user a role on someone else's event. Invitations only
+// take effect once accepted.
+type EventMember struct {
+	EventID    string     `gorm:"type:varchar(255);primaryKey" json:"event_id"`
+	UserID     string     `gorm:"type:varchar(255);primaryKey;index" json:"user_id"`
+	Email      string     `gorm:"type:varchar(255);not null" json:"email"`      // Invitee's email at invitation time
+	Role       string     `gorm:"type:varchar(20);not null" json:"role"`        // See TeamRole constants
+	InvitedBy  string     `gorm:"type:varchar(255);not null" json:"invited_by"` // User who sent the invitation
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`                        // Nil while the invitation is pending
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Active reports whether the member accepted the invitation.
+func (m *EventMember) Active() bool {
+	return m.AcceptedAt != nil
+}
+
+// InviteMemberDTO represents an invitation to an event team.
+type InviteMemberDTO struct {
+	Email string `json:"email" validate:"required,email"` // Registered user to invite
+	Role  string `json:"role" validate:"required,oneof=co_organizer box_office check_in"`
+}
+
+// UpdateMemberDTO represents a change of a team member's role.
+type UpdateMemberDTO struct {
+	Role string `json:"role" validate:"required,oneof=co_organizer box_office check_in"`
+}
+
+// Event team errors.
+var (
+	ErrMemberNotFound = errors.New("team member not found")
+	ErrMemberExists   = errors.New("user is already on the event team")
+	ErrInvalidRole    = errors.New("role must be co_organizer, box_office or check_in")
+)
+
+// ValidTeamRole reports whether role is a known team role.
+func ValidTeamRole(role string) bool {
+	return role == TeamRoleCoOrganizer || role == TeamRoleBoxOffice || role == TeamRoleCheckIn
+}
+
+// draftVisibilityClause matches events that are not drafts, or drafts the
+// viewer owns or is an accepted team member of. It takes the draft status,
+// then the viewer ID twice.
+const draftVisibilityClause = `(events.status <> ? OR events.user_id = ? OR events.event_id IN (
+	SELECT event_members.event_id FROM event_members
+	WHERE event_members.user_id = ? AND event_members.accepted_at IS NOT NULL))`
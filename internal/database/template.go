@@ -0,0 +1,93 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventTemplate is a named, reusable starting point for an organizer's events.
+// It holds everything an event needs except its dates.
+type EventTemplate struct {
+	gorm.Model      `swaggerignore:"true"`
+	TemplateID      string             `gorm:"type:varchar(255);unique;not null" json:"template_id"` // Unique template identifier
+	UserID          string             `gorm:"type:varchar(255);not null;index" json:"user_id"`      // Organizer who owns the template
+	Name            string             `gorm:"type:varchar(255);not null" json:"name"`               // Template name shown to the organizer
+	EventName       string             `gorm:"type:varchar(255);not null" json:"event_name"`         // Name given to events created from the template
+	Description     string             `gorm:"type:text;not null" json:"description"`
+	Capacity        int                `gorm:"not null" json:"capacity"`
+	Category        string             `gorm:"type:varchar(100)" json:"category"`
+	Tags            StringList         `gorm:"type:jsonb;not null;default:'[]'" json:"tags"`
+	TimeZone        string             `gorm:"type:varchar(64);not null;default:'UTC'" json:"time_zone"`
+	VenueID         *string            `gorm:"type:varchar(255)" json:"venue_id,omitempty"`
+	DetailsSchemaID *string            `gorm:"type:varchar(255)" json:"details_schema_id,omitempty"`
+	EventDetails    EventDetailsStruct `gorm:"type:jsonb" json:"event_details"`
+}
+
+// CreateEventTemplateDTO represents the data for creating an event template.
+// When FromEventID is set the event fields are copied from that event.
+type CreateEventTemplateDTO struct {
+	Name            string             `json:"name" validate:"required"` // Template name
+	FromEventID     *string            `json:"from_event_id"`            // Optional event to copy the fields from
+	EventName       string             `json:"event_name"`
+	Description     string             `json:"description"`
+	Capacity        int                `json:"capacity"`
+	Category        string             `json:"category"`
+	Tags            []string           `json:"tags"`
+	TimeZone        string             `json:"time_zone"`
+	VenueID         *string            `json:"venue_id"`
+	DetailsSchemaID *string            `json:"details_schema_id"`
+	EventDetails    EventDetailsStruct `json:"event_details"`
+}
+
+// EventScheduleDTO gives the dates for an event cloned from another event or
+// created from a template. Name overrides the copied name when set.
+type EventScheduleDTO struct {
+	Name        string     `json:"name"`                          // Optional new event name
+	StartsAt    time.Time  `json:"starts_at" validate:"required"` // Start time (RFC3339)
+	EndsAt      time.Time  `json:"ends_at" validate:"required"`   // End time (RFC3339), after starts_at
+	DoorsOpenAt *time.Time `json:"doors_open_at"`                 // Optional doors-open time, not after starts_at
+}
+
+// ErrTemplateNotFound is returned when an event template does not exist.
+var ErrTemplateNotFound = errors.New("event template not found")
+
+// TemplateFromEvent copies the reusable fields of an event into a template.
+func TemplateFromEvent(event *Event) EventTemplate {
+	return EventTemplate{
+		EventName:       event.Name,
+		Description:     event.Description,
+		Capacity:        event.Capacity,
+		Category:        event.Category,
+		Tags:            append(StringList{}, event.Tags...),
+		TimeZone:        event.TimeZone,
+		VenueID:         event.VenueID,
+		DetailsSchemaID: event.DetailsSchemaID,
+		EventDetails:    event.EventDetails,
+	}
+}
+
+// NewEvent builds a draft event from the template for the given schedule.
+// The caller sets EventID and UserID.
+func (t *EventTemplate) NewEvent(schedule EventScheduleDTO) *Event {
+	name := t.EventName
+	if schedule.Name != "" {
+		name = schedule.Name
+	}
+	return &Event{
+		Name:            name,
+		Description:     t.Description,
+		Capacity:        t.Capacity,
+		Category:        t.Category,
+		Tags:            append(StringList{}, t.Tags...),
+		StartsAt:        schedule.StartsAt,
+		EndsAt:          schedule.EndsAt,
+		TimeZone:        t.TimeZone,
+		DoorsOpenAt:     schedule.DoorsOpenAt,
+		VenueID:         t.VenueID,
+		Status:          EventStatusDraft,
+		DetailsSchemaID: t.DetailsSchemaID,
+		EventDetails:    t.EventDetails,
+	}
+}
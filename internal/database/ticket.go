@@ -1,23 +1,58 @@
 package database
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Ticket represents a ticket for an event.
 type Ticket struct {
-	gorm.Model `swaggerignore:"true"`
-	Email      string `gorm:"type:varchar(255);not null"`
-	TicketID   string `gorm:"type:varchar(255);unique;not null"`
-	EventID    string `gorm:"not null" json:"event_id"`
-	UserID     string `gorm:"not null" json:"user_id"` // Ensure this is string
-	Quantity   int    `gorm:"not null" json:"quantity"`
+	gorm.Model  `swaggerignore:"true"`
+	Email       string     `gorm:"type:varchar(255);not null"`
+	TicketID    string     `gorm:"type:varchar(255);unique;not null"`
+	EventID     string     `gorm:"not null" json:"event_id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"` // Account that booked the ticket
+	Quantity    int        `gorm:"not null" json:"quantity"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'active';index" json:"status"` // active or cancelled
+	CheckedInAt *time.Time `json:"checked_in_at"`                                                  // When the holder was let in
 	// Event      Event  `gorm:"constraint:OnDelete:CASCADE;"`
 	// User       User   `gorm:"foreignKey:UserID;references:UserID"` // Explicitly reference UserID
 }
 
+// Ticket states.
+const (
+	TicketStatusActive    = "active"
+	TicketStatusCancelled = "cancelled"
+)
+
+// Errors returned when checking in or cancelling a ticket.
+var (
+	ErrTicketNotFound  = errors.New("ticket not found")
+	ErrTicketCancelled = errors.New("ticket is cancelled")
+	ErrTicketCheckedIn = errors.New("ticket is already checked in")
+)
+
 // TicketBookingReq represents the request payload for booking a ticket.
 type TicketBookingReq struct {
 	TicketID string `json:"ticket_id"`
-	Email    string `json:"email" validate:"required,email"`    // Email of the ticket holder
+	Email    string `json:"email" swaggerignore:"true"`         // Set from the booking account
+	UserID   string `json:"user_id" swaggerignore:"true"`       // Set from the booking account
 	EventID  string `json:"event_id" validate:"required"`       // ID of the event to book
 	Quantity int    `json:"quantity" validate:"required,min=1"` // Number of tickets to book
 }
+
+// TicketFilter holds the filters for listing a user's tickets.
+type TicketFilter struct {
+	UserID string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// UserTicket is a ticket with the event it was booked for.
+type UserTicket struct {
+	Ticket Ticket `json:"ticket"`
+	Event  *Event `json:"event"` // Nil if the event no longer exists
+}
@@ -1,29 +1,124 @@
 package database
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 // User represents a user in the ticket-event management system.
 type User struct {
-	UserID       string    `gorm:"primaryKey;not null" json:"user_id"`
-	FirstName    string    `gorm:"type:varchar(100);not null" json:"first_name"`
-	LastName     string    `gorm:"type:varchar(100);not null" json:"last_name"`
-	Email        string    `gorm:"type:varchar(100);unique;not null" json:"email"`
-	PasswordHash string    `gorm:"type:text;not null" json:"-"`
-	Phone        string    `gorm:"type:varchar(15)" json:"phone"`
-	CreatedAt    time.Time `gorm:"autoCreateTime"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+	UserID          string     `gorm:"primaryKey;not null" json:"user_id"`
+	FirstName       string     `gorm:"type:varchar(100);not null" json:"first_name"`
+	LastName        string     `gorm:"type:varchar(100);not null" json:"last_name"`
+	Email           string     `gorm:"type:varchar(100);unique;not null" json:"email"`
+	PasswordHash    string     `gorm:"type:text;not null" json:"-"`
+	Phone           string     `gorm:"type:varchar(15)" json:"phone"`
+	Role            string     `gorm:"type:varchar(20);not null;default:'attendee';index" json:"role"`       // attendee, organizer or admin
+	OrganizerStatus string     `gorm:"type:varchar(20);not null;default:'';index" json:"organizer_status"`   // Progress of the request to become an organizer
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`                                                    // Nil until the user follows the verification link
+	PendingEmail    string     `gorm:"type:varchar(100);not null;default:''" json:"pending_email,omitempty"` // New address waiting to be confirmed from its inbox
+	TOTPSecret      string     `gorm:"type:varchar(64);not null;default:''" json:"-"`                        // Base32 TOTP secret, set from enrollment on
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at"`                                                      // Nil until enrollment is confirmed with a code
+	TOTPLastCounter int64      `gorm:"not null;default:0" json:"-"`                                          // Last accepted time step, so codes work once
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime"`
 	// TicketsBooked []Ticket  `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"tickets_booked"`
 }
 
+// EmailVerified reports whether the user has confirmed their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MFAEnabled reports whether the user signs in with a TOTP code.
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// Platform roles.
+const (
+	RoleAttendee  = "attendee"  // Books tickets
+	RoleOrganizer = "organizer" // Also creates and runs events; granted on approval
+	RoleAdmin     = "admin"     // Manages users and may act on any event
+)
+
+// Organizer request states.
+const (
+	OrganizerStatusNone     = ""
+	OrganizerStatusPending  = "pending"
+	OrganizerStatusApproved = "approved"
+	OrganizerStatusRejected = "rejected"
+)
+
+// ValidRole reports whether role is a known platform role.
+func ValidRole(role string) bool {
+	return role == RoleAttendee || role == RoleOrganizer || role == RoleAdmin
+}
+
+// UpdateRoleDTO represents an admin changing a user's role.
+type UpdateRoleDTO struct {
+	Role string `json:"role" validate:"required,oneof=attendee organizer admin"`
+}
+
+// TransferEventDTO represents an admin handing an event to another user.
+type TransferEventDTO struct {
+	UserID string `json:"user_id" validate:"required"` // New owner
+}
+
+// UserFilter holds the filters for listing users.
+type UserFilter struct {
+	Role            string
+	OrganizerStatus string
+	Limit           int
+	Offset          int
+}
+
+// Errors returned by user lookups and role changes.
+var (
+	ErrUserNotFound        = errors.New("user not found")
+	ErrNoOrganizerRequest  = errors.New("user has no pending organizer request")
+	ErrAlreadyOrganizer    = errors.New("user is already an organizer")
+	ErrOrganizerRequestDup = errors.New("organizer request is already pending")
+	ErrEmailTaken          = errors.New("email address is already in use")
+)
+
 type SignUpDTO struct {
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-	Password  string `json:"password"`
-	Phone     string `json:"phoneNumber"`
+	Email     string `json:"email" validate:"required,email,max=100"`
+	FirstName string `json:"firstName" validate:"required,max=100"`
+	LastName  string `json:"lastName" validate:"required,max=100"`
+	Password  string `json:"password" validate:"required,password"`  // 8-72 characters with a letter and a digit
+	Phone     string `json:"phoneNumber" validate:"omitempty,phone"` // International format, e.g. +14155550123
 }
 
 type LoginDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ForgotPasswordDTO represents a request for a password reset email.
+type ForgotPasswordDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordDTO represents setting a new password with a reset token.
+type ResetPasswordDTO struct {
+	Token    string `json:"token" validate:"required"` // Token from the reset email
+	Password string `json:"password" validate:"required,password"`
+}
+
+// ChangePasswordDTO represents a signed-in user changing their password.
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
+
+// UpdateProfileDTO represents a user editing their own profile. Omitted
+// fields are left unchanged. A new email only replaces the current one once
+// it is confirmed from its inbox, and changing it needs the current password.
+type UpdateProfileDTO struct {
+	FirstName       *string `json:"first_name" validate:"omitempty,min=1,max=100"`
+	LastName        *string `json:"last_name" validate:"omitempty,min=1,max=100"`
+	Phone           *string `json:"phone" validate:"omitempty,phone"` // International format, e.g. +14155550123
+	Email           *string `json:"email" validate:"omitempty,email,max=100"`
+	CurrentPassword string  `json:"current_password" validate:"required_with=Email"` // Required with email
 }
@@ -0,0 +1,13 @@
+package database
+
+import "time"
+
+// UserIdentity links a user to their account at an external identity
+// provider, so later sign-ins find them even if their email changes there.
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UserID    string    `gorm:"type:varchar(255);not null;index" json:"-"`
+	Issuer    string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_subject" json:"-"` // Provider's issuer URL
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_subject" json:"-"` // User ID at the provider
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+}
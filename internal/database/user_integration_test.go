@@ -0,0 +1,49 @@
+//go:build integration
+
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetUserByEmailIgnoresCase(t *testing.T) {
+	s := testDB(t, &User{})
+	created := time.Now()
+	for _, u := range []User{
+		{UserID: "u-1", Email: "ada@example.com", CreatedAt: created},
+		{UserID: "u-2", Email: "Ada@Example.com", CreatedAt: created.Add(time.Hour)},
+		{UserID: "u-3", Email: "Grace@Example.com", CreatedAt: created},
+	} {
+		u.FirstName, u.LastName, u.PasswordHash = "Test", "User", "x"
+		if err := s.db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"grace@example.com", "u-3"},
+		{" GRACE@example.com ", "u-3"},
+		{"Ada@Example.com", "u-2"},
+		{"ada@example.com", "u-1"},
+		{"ADA@EXAMPLE.COM", "u-1"},
+	}
+	for _, tt := range tests {
+		user, err := s.GetUserByEmail(tt.email)
+		if err != nil {
+			t.Errorf("GetUserByEmail(%q): %v", tt.email, err)
+			continue
+		}
+		if user.UserID != tt.want {
+			t.Errorf("GetUserByEmail(%q) = %s, want %s", tt.email, user.UserID, tt.want)
+		}
+	}
+
+	if _, err := s.GetUserByEmail("nobody@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown email: err = %v, want ErrUserNotFound", err)
+	}
+}
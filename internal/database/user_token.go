@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// UserToken is a single-use token mailed to a user to prove they control
+// their email address, used to verify it, reset the password or unlock the
+// account. Only its hash is stored.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"` // SHA-256 of the mailed token
+	UserID    string     `gorm:"type:varchar(255);not null;index" json:"-"`
+	Purpose   string     `gorm:"type:varchar(30);not null" json:"-"` // See the UserTokenPurpose constants
+	ExpiresAt time.Time  `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"-"`
+}
+
+// User token purposes.
+const (
+	UserTokenPurposeVerifyEmail   = "verify_email"
+	UserTokenPurposeResetPassword = "reset_password"
+	UserTokenPurposeUnlockAccount = "unlock_account"
+	UserTokenPurposeChangeEmail   = "change_email"
+)
+
+// ErrUserTokenInvalid is returned for unknown, used or expired user tokens.
+var ErrUserTokenInvalid = errors.New("token is invalid or expired")
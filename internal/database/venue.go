@@ -0,0 +1,22 @@
+package database
+
+import "gorm.io/gorm"
+
+// Venue is a physical location where events take place.
+type Venue struct {
+	gorm.Model `swaggerignore:"true"`
+	VenueID    string  `gorm:"type:varchar(255);unique;not null" json:"venue_id"` // Unique venue identifier
+	Name       string  `gorm:"type:varchar(255);not null" json:"name"`            // Venue name
+	Address    string  `gorm:"type:text;not null" json:"address"`                 // Street address
+	Latitude   float64 `json:"latitude"`                                          // Geo latitude in degrees
+	Longitude  float64 `json:"longitude"`                                         // Geo longitude in degrees
+	UserID     string  `gorm:"type:varchar(255)" json:"user_id"`                  // User who created the venue
+}
+
+// CreateVenueDTO represents the data for creating a venue.
+type CreateVenueDTO struct {
+	Name      string  `json:"name" validate:"required,max=255"`      // Venue name
+	Address   string  `json:"address" validate:"required"`           // Street address
+	Latitude  float64 `json:"latitude" validate:"min=-90,max=90"`    // Geo latitude in degrees
+	Longitude float64 `json:"longitude" validate:"min=-180,max=180"` // Geo longitude in degrees
+}
@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminHandler represents the handler for platform administration.
+type AdminHandler struct {
+	DB database.Service
+}
+
+// NewAdminHandler initializes a new AdminHandler with the given database service.
+func NewAdminHandler(db database.Service) *AdminHandler {
+	return &AdminHandler{DB: db}
+}
+
+// ListUsers lists platform users.
+// @Summary List users
+// @Description List users, newest first, optionally filtered by role or organizer request status. Admin only.
+// @Tags admin
+// @Produce json
+// @Param role query string false "attendee, organizer or admin"
+// @Param organizer_status query string false "pending, approved or rejected"
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param offset query int false "Number of users to skip" default(0)
+// @Success 200 {array} database.User
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/users [get]
+// @Security BearerAuth
+func (h *AdminHandler) ListUsers(c *fiber.Ctx) error {
+	filter := database.UserFilter{
+		Role:            c.Query("role"),
+		OrganizerStatus: c.Query("organizer_status"),
+		Limit:           c.QueryInt("limit", 50),
+		Offset:          c.QueryInt("offset", 0),
+	}
+	if filter.Limit < 1 || filter.Limit > 100 || filter.Offset < 0 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 100 and offset must not be negative")
+	}
+
+	users, err := h.DB.ListUsers(filter)
+	if err != nil {
+		return apperr.Internal("Could not list users", err)
+	}
+
+	return c.JSON(users)
+}
+
+// ListFailedLogins lists the audit records of rejected logins.
+// @Summary List failed logins
+// @Description List rejected login attempts, newest first, optionally filtered by email or client IP. Admin only.
+// @Tags admin
+// @Produce json
+// @Param email query string false "Email the login was attempted with"
+// @Param ip query string false "Client IP"
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param offset query int false "Number of records to skip" default(0)
+// @Success 200 {array} database.FailedLogin
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/login-failures [get]
+// @Security BearerAuth
+func (h *AdminHandler) ListFailedLogins(c *fiber.Ctx) error {
+	filter := database.FailedLoginFilter{
+		Email:  c.Query("email"),
+		IP:     c.Query("ip"),
+		Limit:  c.QueryInt("limit", 50),
+		Offset: c.QueryInt("offset", 0),
+	}
+	if filter.Limit < 1 || filter.Limit > 100 || filter.Offset < 0 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 100 and offset must not be negative")
+	}
+
+	attempts, err := h.DB.ListFailedLogins(filter)
+	if err != nil {
+		return apperr.Internal("Could not list failed logins", err)
+	}
+
+	return c.JSON(attempts)
+}
+
+// SetUserRole changes a user's platform role.
+// @Summary Change a user's role
+// @Description Set a user's platform role. The change applies to tokens issued after it. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userID path string true "User ID"
+// @Param role body database.UpdateRoleDTO true "New role"
+// @Success 200 {object} database.User
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/users/{userID}/role [patch]
+// @Security BearerAuth
+func (h *AdminHandler) SetUserRole(c *fiber.Ctx) error {
+	var dto database.UpdateRoleDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+	if c.Params("userID") == subject.UserID && dto.Role != database.RoleAdmin {
+		return apperr.BadRequest("self_demotion", "Admins cannot demote themselves")
+	}
+
+	user, err := h.DB.SetUserRole(c.Params("userID"), dto.Role)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return err
+		}
+		return apperr.Internal("Could not change user role", err)
+	}
+
+	return c.JSON(user)
+}
+
+// ApproveOrganizer grants a pending organizer request.
+// @Summary Approve an organizer request
+// @Description Approve a user's pending request and make them an organizer. Admin only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} database.User
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/users/{userID}/organizer/approve [post]
+// @Security BearerAuth
+func (h *AdminHandler) ApproveOrganizer(c *fiber.Ctx) error {
+	return h.decideOrganizer(c, true)
+}
+
+// RejectOrganizer declines a pending organizer request.
+// @Summary Reject an organizer request
+// @Description Reject a user's pending organizer request. The user may request again later. Admin only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} database.User
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/users/{userID}/organizer/reject [post]
+// @Security BearerAuth
+func (h *AdminHandler) RejectOrganizer(c *fiber.Ctx) error {
+	return h.decideOrganizer(c, false)
+}
+
+func (h *AdminHandler) decideOrganizer(c *fiber.Ctx, approve bool) error {
+	user, err := h.DB.DecideOrganizerRequest(c.Params("userID"), approve)
+	if err != nil {
+		if err == database.ErrNoOrganizerRequest {
+			return err
+		}
+		return apperr.Internal("Could not decide organizer request", err)
+	}
+
+	return c.JSON(user)
+}
+
+// TransferEvent hands an event to another owner.
+// @Summary Override event ownership
+// @Description Make another organizer or admin the owner of an event. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param owner body database.TransferEventDTO true "New owner"
+// @Success 200 {object} database.Event
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/events/{id}/owner [put]
+// @Security BearerAuth
+func (h *AdminHandler) TransferEvent(c *fiber.Ctx) error {
+	var dto database.TransferEventDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	owner, err := h.DB.GetUser(dto.UserID)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not retrieve user", err)
+	}
+	if !access.RoleCan(owner.Role, access.CreateEvents) {
+		return apperr.BadRequest("owner_not_organizer", "The new owner must be an organizer")
+	}
+
+	event, err := h.DB.TransferEvent(c.Params("id"), owner.UserID)
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrEventNotFound
+		}
+		return apperr.Internal("Could not transfer event", err)
+	}
+
+	return c.JSON(event)
+}
+
+// ListDeletedEvents lists every soft-deleted event.
+// @Summary List all deleted events
+// @Description List soft-deleted events of every organizer. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/events/deleted [get]
+// @Security BearerAuth
+func (h *AdminHandler) ListDeletedEvents(c *fiber.Ctx) error {
+	events, err := h.DB.ListDeletedEvents("")
+	if err != nil {
+		return apperr.Internal("Could not list deleted events", err)
+	}
+
+	return c.JSON(events)
+}
+
+// RestoreEvent restores any soft-deleted event.
+// @Summary Restore any deleted event
+// @Description Undo the deletion of any organizer's event. The event keeps the status it had when deleted. Admin only.
+// @Tags admin
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/events/{id}/restore [post]
+// @Security BearerAuth
+func (h *AdminHandler) RestoreEvent(c *fiber.Ctx) error {
+	event, err := h.DB.RestoreEvent(c.Params("id"), "")
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return apperr.NotFound("event_not_found", "Deleted event not found")
+		}
+		return apperr.Internal("Could not restore event", err)
+	}
+	resumeCompletion(h.DB, event)
+
+	return c.JSON(event)
+}
+
+// GetSecurityPolicy returns the platform security policy.
+// @Summary Get the security policy
+// @Description Show which platform roles must enable two-factor authentication. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} database.SecurityPolicy
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/security-policy [get]
+// @Security BearerAuth
+func (h *AdminHandler) GetSecurityPolicy(c *fiber.Ctx) error {
+	policy, err := h.DB.GetSecurityPolicy()
+	if err != nil {
+		return apperr.Internal("Could not load security policy", err)
+	}
+
+	return c.JSON(policy)
+}
+
+// UpdateSecurityPolicy changes the platform security policy.
+// @Summary Change the security policy
+// @Description Set which platform roles (organizer, admin) must enable two-factor authentication. Until they do, their tokens only carry attendee rights. The change applies to tokens issued after it. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param policy body database.SecurityPolicyDTO true "Security policy"
+// @Success 200 {object} database.SecurityPolicy
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/security-policy [put]
+// @Security BearerAuth
+func (h *AdminHandler) UpdateSecurityPolicy(c *fiber.Ctx) error {
+	var dto database.SecurityPolicyDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	policy := &database.SecurityPolicy{MFARequiredRoles: database.StringList(dto.MFARequiredRoles)}
+	if policy.MFARequiredRoles == nil {
+		policy.MFARequiredRoles = database.StringList{}
+	}
+	if err := h.DB.UpdateSecurityPolicy(policy); err != nil {
+		return apperr.Internal("Could not update security policy", err)
+	}
+
+	return c.JSON(policy)
+}
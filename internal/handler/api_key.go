@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// defaultAPIKeyDays is how long a key lives when no expiry is requested.
+const defaultAPIKeyDays = 90
+
+// CreateAPIKey creates an API key for the caller.
+// @Summary Create an API key
+// @Description Create a named key for server-to-server integrations. Send it in the X-API-Key header; it acts as the caller, limited to its scopes (events:read, events:write, tickets:read, checkin:write). The key is only shown in this response. Keys expire after expires_in_days, 90 by default and at most 365.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.CreateAPIKeyDTO true "Name, scopes and lifetime"
+// @Success 201 {object} map[string]interface{} "key and api_key"
+// @Failure 400 {object} map[string]string "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/api-keys [post]
+// @Security BearerAuth
+func (h *UserHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req database.CreateAPIKeyDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	raw, prefix, hash, err := utils.NewAPIKey()
+	if err != nil {
+		return apperr.Internal("Could not generate API key", err)
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+	key := &database.APIKey{
+		KeyID:     uuid.New().String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    database.StringList(req.Scopes),
+		ExpiresAt: time.Now().Add(time.Duration(days) * 24 * time.Hour),
+	}
+	if err := h.db.CreateAPIKey(key); err != nil {
+		return apperr.Internal("Could not create API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"key": raw, "api_key": key})
+}
+
+// ListAPIKeys lists the caller's API keys.
+// @Summary List API keys
+// @Description List the caller's API keys with their scopes, expiry and when they were last used. The keys themselves are not shown.
+// @Tags Users
+// @Produce json
+// @Success 200 {array} database.APIKey
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/api-keys [get]
+// @Security BearerAuth
+func (h *UserHandler) ListAPIKeys(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	keys, err := h.db.ListAPIKeys(userID)
+	if err != nil {
+		return apperr.Internal("Could not list API keys", err)
+	}
+
+	return c.JSON(keys)
+}
+
+// RevokeAPIKey deletes one of the caller's API keys.
+// @Summary Revoke an API key
+// @Description Delete an API key. Requests using it fail immediately.
+// @Tags Users
+// @Produce json
+// @Param keyID path string true "API key ID"
+// @Success 200 {object} map[string]string "API key revoked"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/api-keys/{keyID} [delete]
+// @Security BearerAuth
+func (h *UserHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	if err := h.db.DeleteAPIKey(c.Params("keyID"), userID); err != nil {
+		if err == database.ErrAPIKeyNotFound {
+			return err
+		}
+		return apperr.Internal("Could not revoke API key", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "API key revoked"})
+}
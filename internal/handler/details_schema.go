@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/jsonschema"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// DetailsSchemaHandler represents the handler for event details schema templates.
+type DetailsSchemaHandler struct {
+	DB database.Service
+}
+
+// NewDetailsSchemaHandler initializes a new DetailsSchemaHandler with the given database service.
+func NewDetailsSchemaHandler(db database.Service) *DetailsSchemaHandler {
+	return &DetailsSchemaHandler{DB: db}
+}
+
+// detailsError is returned when event_details does not satisfy its schema.
+type detailsError struct {
+	Errors []jsonschema.ValidationError
+}
+
+func (e *detailsError) Error() string {
+	return "event_details does not match the details schema"
+}
+
+// validateEventDetails checks details against the schema picked by schemaID,
+// or the built-in schema for the category when none is picked. Events in
+// categories without a built-in schema are not validated.
+func validateEventDetails(db database.Service, userID, category string, schemaID *string, details database.EventDetailsStruct) error {
+	var (
+		schema *database.DetailsSchema
+		err    error
+	)
+	if schemaID != nil {
+		schema, err = db.GetDetailsSchema(*schemaID)
+		if err == nil && !schema.BuiltIn && schema.UserID != userID {
+			err = database.ErrDetailsSchemaNotFound
+		}
+	} else {
+		schema, err = db.GetDefaultDetailsSchema(category)
+		if errors.Is(err, database.ErrDetailsSchemaNotFound) {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	compiled, err := jsonschema.Compile(schema.Schema)
+	if err != nil {
+		return err
+	}
+
+	// Round-trip through JSON so values have the types the validator expects.
+	raw, err := json.Marshal(details.Details)
+	if err != nil {
+		return err
+	}
+	var doc interface{} = map[string]interface{}{}
+	if details.Details != nil {
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+	}
+
+	if errs := compiled.Validate(doc); len(errs) > 0 {
+		return &detailsError{Errors: errs}
+	}
+	return nil
+}
+
+// detailsErrorResponse renders the result of validateEventDetails.
+func detailsErrorResponse(c *fiber.Ctx, err error) error {
+	var de *detailsError
+	if errors.As(err, &de) {
+		return apperr.BadRequest("details_invalid", de.Error()).With("details_errors", de.Errors)
+	}
+	if errors.Is(err, database.ErrDetailsSchemaNotFound) {
+		return apperr.Invalid(err)
+	}
+	return apperr.Internal("Could not validate event details", err)
+}
+
+// CreateDetailsSchema defines a new details schema template.
+// @Summary Define an event details schema
+// @Description Define a JSON Schema template that event_details.details must satisfy for events that pick it. Supported keywords: type, properties, required, additionalProperties, enum, minimum, maximum, minLength, maxLength, pattern, format (date-time, date, email, uri), items, minItems, maxItems.
+// @Tags detail-schemas
+// @Accept json
+// @Produce json
+// @Param schema body database.CreateDetailsSchemaDTO true "Schema Data"
+// @Success 201 {object} database.DetailsSchema
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /detail-schemas [post]
+// @Security BearerAuth
+func (h *DetailsSchemaHandler) CreateDetailsSchema(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	var dto database.CreateDetailsSchemaDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(dto.Schema)
+	if err != nil {
+		return apperr.BadRequest("invalid_schema", "Invalid schema")
+	}
+	if _, err := jsonschema.Compile(raw); err != nil {
+		return apperr.BadRequest("invalid_schema", err.Error())
+	}
+
+	schema := &database.DetailsSchema{
+		SchemaID: uuid.New().String(),
+		Name:     dto.Name,
+		Category: dto.Category,
+		UserID:   userID,
+		Schema:   raw,
+	}
+
+	if err := h.DB.CreateDetailsSchema(schema); err != nil {
+		return apperr.Internal("Could not create details schema", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(schema)
+}
+
+// ListDetailsSchemas lists the details schemas available to the caller.
+// @Summary List event details schemas
+// @Description List the built-in schemas and the caller's own schemas, optionally for one category
+// @Tags detail-schemas
+// @Produce json
+// @Param category query string false "Category"
+// @Success 200 {array} database.DetailsSchema
+// @Failure 500 {object} map[string]interface{}
+// @Router /detail-schemas [get]
+// @Security BearerAuth
+func (h *DetailsSchemaHandler) ListDetailsSchemas(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	schemas, err := h.DB.ListDetailsSchemas(c.Query("category"), userID)
+	if err != nil {
+		return apperr.Internal("Could not list details schemas", err)
+	}
+
+	return c.JSON(schemas)
+}
+
+// GetDetailsSchema retrieves a details schema by ID.
+// @Summary Get an event details schema
+// @Description Retrieve a built-in schema or one of the caller's own schemas
+// @Tags detail-schemas
+// @Produce json
+// @Param id path string true "Schema ID"
+// @Success 200 {object} database.DetailsSchema
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /detail-schemas/{id} [get]
+// @Security BearerAuth
+func (h *DetailsSchemaHandler) GetDetailsSchema(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	schema, err := h.DB.GetDetailsSchema(c.Params("id"))
+	if err == nil && !schema.BuiltIn && schema.UserID != userID {
+		err = database.ErrDetailsSchemaNotFound
+	}
+	if err != nil {
+		if err == database.ErrDetailsSchemaNotFound {
+			return database.ErrDetailsSchemaNotFound
+		}
+		return apperr.Internal("Could not retrieve details schema", err)
+	}
+
+	return c.JSON(schema)
+}
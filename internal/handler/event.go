@@ -1,10 +1,22 @@
 package handler
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
 	"ticketing/internal/database"
+	"ticketing/internal/jobs"
+	"ticketing/internal/middleware"
+	"ticketing/internal/scheduler"
 	"ticketing/internal/utils"
+	"ticketing/internal/validation"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -18,9 +30,32 @@ func NewEventHandler(db database.Service) *EventHandler {
 	return &EventHandler{DB: db}
 }
 
+// userIDFromRequest returns the user ID from the request's API key or
+// bearer token.
+func userIDFromRequest(c *fiber.Ctx) (string, error) {
+	if s, ok := c.Locals(middleware.SubjectKey).(access.Subject); ok {
+		return s.UserID, nil
+	}
+	tokenString := strings.Replace(c.Get("Authorization"), "Bearer ", "", 1)
+	return utils.ExtractUserID(tokenString)
+}
+
+// subjectFromRequest returns the user ID and role from the request's API key
+// or bearer token.
+func subjectFromRequest(c *fiber.Ctx) (access.Subject, error) {
+	if s, ok := c.Locals(middleware.SubjectKey).(access.Subject); ok {
+		return s, nil
+	}
+	claims, err := utils.ParseToken(strings.Replace(c.Get("Authorization"), "Bearer ", "", 1))
+	if err != nil {
+		return access.Subject{}, err
+	}
+	return access.SubjectFromClaims(claims)
+}
+
 // createEvent creates a new event.
 // @Summary Create a new event
-// @Description Create a new event with name, description, and capacity
+// @Description Create a new event with name, description, capacity, start/end times in an IANA time zone and an optional venue. event_details must satisfy the picked details schema, or the built-in schema for the category.
 // @Tags events
 // @Accept json
 // @Produce json
@@ -33,46 +68,63 @@ func NewEventHandler(db database.Service) *EventHandler {
 func (h *EventHandler) CreateEvent(c *fiber.Ctx) error {
 	var dto database.CreateEventDTO
 
-	if err := c.BodyParser(&dto); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request payload"})
+	if err := bindJSON(c, &dto); err != nil {
+		return err
 	}
 
-	if dto.Name == "" || dto.Description == "" || dto.Capacity <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Name, Description, and Capacity are required"})
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
 	}
 
-	tokenString := c.Get("Authorization")
-	if tokenString == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization token required"})
+	event := &database.Event{
+		EventID:         uuid.New().String(),
+		Name:            dto.Name,
+		Description:     dto.Description,
+		Capacity:        dto.Capacity,
+		UserID:          userID,
+		Category:        dto.Category,
+		Tags:            database.NormalizeTags(dto.Tags),
+		StartsAt:        dto.StartsAt,
+		EndsAt:          dto.EndsAt,
+		TimeZone:        dto.TimeZone,
+		DoorsOpenAt:     dto.DoorsOpenAt,
+		VenueID:         dto.VenueID,
+		Status:          database.EventStatusDraft,
+		DetailsSchemaID: dto.DetailsSchemaID,
+		EventDetails:    dto.EventDetails,
 	}
 
-	tokenString = strings.Replace(tokenString, "Bearer ", "", 1)
+	return createDraftEvent(c, h.DB, event)
+}
 
-	userID, err := utils.ExtractUserID(tokenString)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+// createDraftEvent validates a new draft event, saves it and writes the
+// response. It is shared by event creation, cloning and templates.
+func createDraftEvent(c *fiber.Ctx, db database.Service, event *database.Event) error {
+	if err := validateSchedule(db, event); err != nil {
+		return apperr.Invalid(err)
 	}
-
-	event := &database.Event{
-		EventID:      uuid.New().String(),
-		Name:         dto.Name,
-		Description:  dto.Description,
-		Capacity:     dto.Capacity,
-		UserID:       userID,
-		EventDetails: dto.EventDetails,
+	if err := validateCategory(db, event.Category); err != nil {
+		return categoryErrorResponse(c, err)
+	}
+	if err := validateEventDetails(db, event.UserID, event.Category, event.DetailsSchemaID, event.EventDetails); err != nil {
+		return detailsErrorResponse(c, err)
+	}
+	if !event.StartsAt.After(time.Now()) {
+		return database.ErrStartsInPast
 	}
 
-	if err := h.DB.CreateEvent(event); err != nil {
-		log.Printf("Error creating event: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create event"})
+	if err := db.CreateEvent(event); err != nil {
+		return apperr.Internal("Could not create event", err)
 	}
 
+	event.Localize()
 	return c.Status(fiber.StatusCreated).JSON(event)
 }
 
 // getEvent retrieves an event by ID.
 // @Summary Get an event by ID
-// @Description Retrieve an event's details by its unique event ID
+// @Description Retrieve an event's details by its unique event ID. Draft events are only visible to their owner and event team.
 // @Tags events
 // @Accept json
 // @Produce json
@@ -85,106 +137,531 @@ func (h *EventHandler) CreateEvent(c *fiber.Ctx) error {
 func (h *EventHandler) GetEvent(c *fiber.Ctx) error {
 	eventID := c.Params("id")
 
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
 	event, err := h.DB.GetEvent(eventID)
 	if err != nil {
 		if err == database.ErrEventNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
+			return database.ErrEventNotFound
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not retrieve event"})
+		return apperr.Internal("Could not retrieve event", err)
 	}
 
+	// Drafts are hidden from everyone but their owner and team
+	if visible, err := access.CanView(h.DB, event, subject); err != nil {
+		return apperr.Internal("Could not check permissions", err)
+	} else if !visible {
+		return database.ErrEventNotFound
+	}
+
+	c.Set(fiber.HeaderETag, event.ETag())
 	return c.JSON(event)
 }
 
-// updateEvent updates an existing event.
+// listEvents lists events with search, filters and cursor pagination.
+// @Summary List events
+// @Description Browse events with full-text search, filters, sorting and cursor pagination. Drafts are only listed for their owner and event team. Any query parameter of the form detail.<key>=<value> filters on a key inside event_details; dotted keys such as detail.venue.section address nested objects.
+// @Tags events
+// @Produce json
+// @Param q query string false "Full-text search over name and description"
+// @Param user_id query string false "Organizer user ID"
+// @Param from query string false "Only events starting on or after this time (RFC3339)"
+// @Param to query string false "Only events starting before this time (RFC3339)"
+// @Param status query string false "Lifecycle state: draft, published, on_sale, cancelled, completed"
+// @Param category query string false "Category slug; events in its subcategories match too"
+// @Param tag query string false "Tag; repeat or comma-separate to require several tags"
+// @Param collection query string false "Collection slug"
+// @Param min_available query int false "Minimum remaining capacity"
+// @Param sort query string false "Sort key: created_at, starts_at, name, capacity, remaining; prefix with - for descending" default(-created_at)
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (max 100)" default(20)
+// @Success 200 {object} database.EventPage
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events [get]
+// @Security BearerAuth
+func (h *EventHandler) ListEvents(c *fiber.Ctx) error {
+	viewerID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	filter := database.EventFilter{
+		Search:     c.Query("q"),
+		UserID:     c.Query("user_id"),
+		ViewerID:   viewerID,
+		Status:     c.Query("status"),
+		Category:   c.Query("category"),
+		Collection: c.Query("collection"),
+		Sort:       c.Query("sort"),
+		Cursor:     c.Query("cursor"),
+		Limit:      c.QueryInt("limit", 20),
+		Details:    map[string]string{},
+	}
+
+	if filter.Limit < 1 || filter.Limit > 100 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 100")
+	}
+
+	for _, bound := range []struct {
+		param string
+		dst   **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := c.Query(bound.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return apperr.BadRequest("invalid_query", bound.param+" must be an RFC3339 timestamp")
+			}
+			*bound.dst = &t
+		}
+	}
+
+	if v := c.Query("min_available"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return apperr.BadRequest("invalid_query", "min_available must be an integer")
+		}
+		filter.MinAvailable = &n
+	}
+
+	for _, raw := range c.Context().QueryArgs().PeekMulti("tag") {
+		filter.Tags = append(filter.Tags, strings.Split(string(raw), ",")...)
+	}
+
+	for key, value := range c.Queries() {
+		if detailKey, ok := strings.CutPrefix(key, "detail."); ok && detailKey != "" {
+			filter.Details[detailKey] = value
+		}
+	}
+
+	page, err := h.DB.ListEvents(filter)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) || errors.Is(err, database.ErrInvalidSort) {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not list events", err)
+	}
+
+	return c.JSON(page)
+}
+
+// updateEvent replaces an existing event's editable fields.
 // @Summary Update an event
-// @Description Update an event's name, description, and capacity by event ID
+// @Description Replace an event's name, description, capacity, schedule and event details by event ID. Capacity cannot drop below tickets already sold and events that have already ended cannot be edited. Send If-Match with the event's ETag to guard against concurrent edits.
 // @Tags events
 // @Accept json
 // @Produce json
 // @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag of the version being replaced"
 // @Param event body database.CreateEventDTO true "Updated Event Data"
 // @Success 200 {object} database.Event
 // @Failure 400 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Event has already ended or capacity is below tickets sold"
+// @Failure 412 {object} map[string]interface{} "Event was modified concurrently"
 // @Failure 500 {object} map[string]interface{}
 // @Router /events/{id} [put]
 // @Security BearerAuth
 func (h *EventHandler) UpdateEvent(c *fiber.Ctx) error {
-	eventID := c.Params("id")
+	var dto database.CreateEventDTO
+	if err := c.BodyParser(&dto); err != nil {
+		return errInvalidPayload
+	}
 
-	tokenString := c.Get("Authorization")
-	if tokenString == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization token required"})
+	return h.editEvent(c, func(*database.Event) (database.CreateEventDTO, error) {
+		return dto, nil
+	})
+}
+
+// patchEvent partially updates an event using JSON Merge Patch.
+// @Summary Partially update an event
+// @Description Apply an RFC 7386 JSON Merge Patch to an event's editable fields (name, description, capacity, category, starts_at, ends_at, time_zone, doors_open_at, venue_id, event_details). Fields left out are unchanged and null removes optional values or event_details keys. Send If-Match with the event's ETag to guard against concurrent edits.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "Merge patch"
+// @Success 200 {object} database.Event
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Event has already ended or capacity is below tickets sold"
+// @Failure 412 {object} map[string]interface{} "Event was modified concurrently"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id} [patch]
+// @Security BearerAuth
+func (h *EventHandler) PatchEvent(c *fiber.Ctx) error {
+	patch := c.Body()
+	if !json.Valid(patch) {
+		return apperr.BadRequest("invalid_patch", "Invalid merge patch")
 	}
 
-	tokenString = strings.Replace(tokenString, "Bearer ", "", 1)
+	return h.editEvent(c, func(event *database.Event) (database.CreateEventDTO, error) {
+		current, err := json.Marshal(event.Editable())
+		if err != nil {
+			return database.CreateEventDTO{}, err
+		}
 
-	userID, err := utils.ExtractUserID(tokenString)
-	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+		merged, err := utils.MergePatch(current, patch)
+		if err != nil {
+			return database.CreateEventDTO{}, err
+		}
+
+		var dto database.CreateEventDTO
+		dec := json.NewDecoder(bytes.NewReader(merged))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&dto); err != nil {
+			return database.CreateEventDTO{}, err
+		}
+		return dto, nil
+	})
+}
+
+// editEvent loads the event in the path, checks edit permission and If-Match,
+// builds the new field values with edit, validates and saves them.
+func (h *EventHandler) editEvent(c *fiber.Ctx, edit func(*database.Event) (database.CreateEventDTO, error)) error {
+	event, userID, err := authorizeEvent(c, h.DB, access.EditEvent)
+	if err != nil || event == nil {
+		return err
 	}
 
-	var dto database.CreateEventDTO
-	if err := c.BodyParser(&dto); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request payload"})
+	if match := c.Get(fiber.HeaderIfMatch); match != "" && match != "*" && match != event.ETag() {
+		return database.ErrVersionConflict
 	}
 
-	event, err := h.DB.GetEvent(eventID)
+	if event.HasEnded(time.Now()) {
+		return database.ErrEventInPast
+	}
+
+	if event.IsTerminal() {
+		return apperr.Conflict("event_closed", "Cancelled or completed events cannot be edited")
+	}
+
+	dto, err := edit(event)
 	if err != nil {
-		if err == database.ErrEventNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
+		return apperr.BadRequest(apperr.CodeInvalidRequest, "Invalid request payload: "+err.Error())
+	}
+
+	if err := validation.Struct(&dto); err != nil {
+		return err
+	}
+
+	if dto.Capacity < event.Capacity {
+		sold, err := h.DB.GetTotalTicketsSold(event.EventID)
+		if err != nil {
+			return apperr.Internal("Could not check tickets sold", err)
+		}
+		if dto.Capacity < sold {
+			return apperr.From(database.ErrCapacityBelowSold).With("sold", sold)
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not retrieve event"})
 	}
 
-	if event.UserID != userID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Your are not allowed to edit the datafor this event."})
+	if dto.Category != event.Category {
+		if err := validateCategory(h.DB, dto.Category); err != nil {
+			return categoryErrorResponse(c, err)
+		}
 	}
 
-	event.Name = dto.Name
-	event.Description = dto.Description
-	event.Capacity = dto.Capacity
+	endsAt := event.EndsAt
+	event.ApplyEditable(dto)
+
+	if err := validateSchedule(h.DB, event); err != nil {
+		return apperr.Invalid(err)
+	}
+	if err := validateEventDetails(h.DB, userID, event.Category, event.DetailsSchemaID, event.EventDetails); err != nil {
+		return detailsErrorResponse(c, err)
+	}
 
 	if err := h.DB.UpdateEvent(event); err != nil {
-		log.Printf("Error updating event: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update event"})
+		if err == database.ErrVersionConflict {
+			return database.ErrVersionConflict
+		}
+		return apperr.Internal("Could not update event", err)
 	}
+	rescheduleCompletion(h.DB, event, endsAt)
 
+	event.Localize()
+	c.Set(fiber.HeaderETag, event.ETag())
 	return c.JSON(event)
 }
 
+// PublishEvent makes a draft event visible to everyone.
+// @Summary Publish an event
+// @Description Move a draft event to published. Published events are visible but not yet bookable.
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Transition not allowed"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/publish [post]
+// @Security BearerAuth
+func (h *EventHandler) PublishEvent(c *fiber.Ctx) error {
+	return h.transition(c, database.EventStatusPublished)
+}
+
+// UnpublishEvent moves a published event back to draft.
+// @Summary Unpublish an event
+// @Description Move a published event back to draft, hiding it from everyone but its owner
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Transition not allowed"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/unpublish [post]
+// @Security BearerAuth
+func (h *EventHandler) UnpublishEvent(c *fiber.Ctx) error {
+	return h.transition(c, database.EventStatusDraft)
+}
+
+// OpenSales puts a published event on sale.
+// @Summary Open ticket sales
+// @Description Move a published event to on_sale so tickets can be booked
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Transition not allowed"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/open-sales [post]
+// @Security BearerAuth
+func (h *EventHandler) OpenSales(c *fiber.Ctx) error {
+	return h.transition(c, database.EventStatusOnSale)
+}
+
+// CancelEvent cancels an event and all of its tickets.
+// @Summary Cancel an event
+// @Description Cancel an event, mark all of its tickets as cancelled and notify ticket holders by email
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Transition not allowed"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/cancel [post]
+// @Security BearerAuth
+func (h *EventHandler) CancelEvent(c *fiber.Ctx) error {
+	return h.transition(c, database.EventStatusCancelled)
+}
+
+// transition moves the event in the path to the given state on behalf of its team.
+func (h *EventHandler) transition(c *fiber.Ctx, to string) error {
+	// Cancelling cancels every ticket, so it is reserved for the owner
+	perm := access.EditEvent
+	if to == database.EventStatusCancelled {
+		perm = access.ManageEvent
+	}
+
+	event, _, err := authorizeEvent(c, h.DB, perm)
+	if err != nil || event == nil {
+		return err
+	}
+
+	if !event.CanTransition(to) {
+		return apperr.Conflict("invalid_transition", fmt.Sprintf("Cannot move event from %s to %s", event.Status, to))
+	}
+
+	if to == database.EventStatusCancelled {
+		err = h.DB.CancelEvent(event.EventID, event.Status)
+	} else {
+		err = h.DB.SetEventStatus(event.EventID, event.Status, to)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidTransition) {
+			return apperr.Conflict("invalid_transition", "Event status changed concurrently, try again")
+		}
+		return apperr.Internal("Could not change event status", err)
+	}
+
+	payload := jobs.EventPayload{EventID: event.EventID}
+	switch {
+	case to == database.EventStatusCancelled:
+		if _, err := scheduler.Enqueue(h.DB, jobs.EventNotifyCancelled, payload, time.Now()); err != nil {
+			log.Printf("Could not schedule cancellation emails for event %s: %v", event.EventID, err)
+		}
+	case event.Status == database.EventStatusDraft && to == database.EventStatusPublished:
+		if err := jobs.ScheduleCompletion(h.DB, event); err != nil {
+			log.Printf("Could not schedule completion for event %s: %v", event.EventID, err)
+		}
+	}
+
+	event.Status = to
+	return c.JSON(event)
+}
+
+// rescheduleCompletion queues completion again when a live event now ends earlier.
+func rescheduleCompletion(db database.Service, event *database.Event, previousEnd time.Time) {
+	if event.Status == database.EventStatusDraft || event.IsTerminal() || !event.EndsAt.Before(previousEnd) {
+		return
+	}
+	if err := jobs.ScheduleCompletion(db, event); err != nil {
+		log.Printf("Could not reschedule completion for event %s: %v", event.EventID, err)
+	}
+}
+
+// resumeCompletion queues completion for a restored event whose job found it deleted.
+func resumeCompletion(db database.Service, event *database.Event) {
+	if event.Status == database.EventStatusDraft || event.IsTerminal() {
+		return
+	}
+	if err := jobs.ScheduleCompletion(db, event); err != nil {
+		log.Printf("Could not schedule completion for event %s: %v", event.EventID, err)
+	}
+}
+
+// validateSchedule checks the event's times and time zone and that its venue exists.
+func validateSchedule(db database.Service, event *database.Event) error {
+	if err := event.ValidateSchedule(); err != nil {
+		return err
+	}
+	if event.VenueID != nil {
+		venue, err := db.GetVenue(*event.VenueID)
+		if err != nil {
+			if errors.Is(err, database.ErrVenueNotFound) {
+				return err
+			}
+			log.Printf("Error loading venue: %v", err)
+			return errors.New("could not verify venue")
+		}
+		event.Venue = venue
+	}
+	return nil
+}
+
 // deleteEvent deletes an event by ID.
 // @Summary Delete an event
-// @Description Delete an event by event ID
+// @Description Soft-delete an event by event ID. Events with active tickets are refused unless force=true, which cancels the event and its tickets and notifies holders. Deleted events can be restored.
 // @Tags events
 // @Param id path string true "Event ID"
+// @Param force query bool false "Cancel active tickets and delete anyway"
 // @Success 204 {object} nil
 // @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Event has active tickets"
 // @Failure 500 {object} map[string]interface{}
 // @Router /events/{id} [delete]
 // @Security BearerAuth
 func (h *EventHandler) DeleteEvent(c *fiber.Ctx) error {
-	eventID := c.Params("id")
+	event, _, err := authorizeEvent(c, h.DB, access.ManageEvent)
+	if err != nil || event == nil {
+		return err
+	}
+	eventID := event.EventID
 
-	tokenString := c.Get("Authorization")
-	if tokenString == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization token required"})
+	cancelled, err := h.DB.DeleteEvent(eventID, event.UserID, c.QueryBool("force"))
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrEventNotFound
+		}
+		if err == database.ErrEventHasTickets {
+			return apperr.Conflict("event_has_tickets", "Event has active tickets, retry with force=true to cancel them")
+		}
+		return apperr.Internal("Could not delete event", err)
+	}
+
+	if cancelled > 0 {
+		payload := jobs.EventPayload{EventID: eventID}
+		if _, err := scheduler.Enqueue(h.DB, jobs.EventNotifyCancelled, payload, time.Now()); err != nil {
+			log.Printf("Could not schedule cancellation emails for event %s: %v", eventID, err)
+		}
 	}
 
-	tokenString = strings.Replace(tokenString, "Bearer ", "", 1)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListDeletedEvents lists the caller's soft-deleted events.
+// @Summary List deleted events
+// @Description List the caller's soft-deleted events that can still be restored
+// @Tags events
+// @Produce json
+// @Success 200 {array} database.Event
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/deleted [get]
+// @Security BearerAuth
+func (h *EventHandler) ListDeletedEvents(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
 
-	userID, err := utils.ExtractUserID(tokenString)
+	events, err := h.DB.ListDeletedEvents(userID)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+		return apperr.Internal("Could not list deleted events", err)
 	}
 
-	if err := h.DB.DeleteEvent(eventID, userID); err != nil {
+	return c.JSON(events)
+}
+
+// RestoreEvent restores a soft-deleted event.
+// @Summary Restore a deleted event
+// @Description Undo the deletion of an event. The event keeps the status it had when deleted; events and tickets cancelled by a forced delete stay cancelled.
+// @Tags events
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.Event
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/restore [post]
+// @Security BearerAuth
+func (h *EventHandler) RestoreEvent(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	event, err := h.DB.RestoreEvent(c.Params("id"), userID)
+	if err != nil {
 		if err == database.ErrEventNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
+			return apperr.NotFound("event_not_found", "Deleted event not found")
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete event"})
+		return apperr.Internal("Could not restore event", err)
 	}
+	resumeCompletion(h.DB, event)
 
-	return c.SendStatus(fiber.StatusNoContent)
+	return c.JSON(event)
+}
+
+// CloneEvent copies an event into a new draft with new dates.
+// @Summary Clone an event
+// @Description Copy an event's name, description, capacity, category, tags, venue and event_details into a new draft scheduled at the given dates. The source event may be in any state, including past events.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param schedule body database.EventScheduleDTO true "Dates for the new event"
+// @Success 201 {object} database.Event
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/clone [post]
+// @Security BearerAuth
+func (h *EventHandler) CloneEvent(c *fiber.Ctx) error {
+	var dto database.EventScheduleDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	source, userID, err := authorizeEvent(c, h.DB, access.EditEvent)
+	if err != nil || source == nil {
+		return err
+	}
+
+	template := database.TemplateFromEvent(source)
+	event := template.NewEvent(dto)
+	event.EventID = uuid.New().String()
+	event.UserID = userID
+
+	return createDraftEvent(c, h.DB, event)
 }
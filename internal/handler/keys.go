@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"ticketing/internal/apperr"
+	"ticketing/internal/keys"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeysHandler represents the handler for the token signing keys.
+type KeysHandler struct {
+	Keys *keys.Manager
+}
+
+// NewKeysHandler initializes a new KeysHandler with the given key manager.
+func NewKeysHandler(m *keys.Manager) *KeysHandler {
+	return &KeysHandler{Keys: m}
+}
+
+// JWKS publishes the public keys access tokens are signed with.
+// @Summary JSON Web Key Set
+// @Description Public keys that verify access tokens, selected by the token's kid header. Rotated-out keys stay listed until the tokens they signed have expired.
+// @Tags keys
+// @Produce json
+// @Success 200 {object} keys.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *KeysHandler) JWKS(c *fiber.Ctx) error {
+	// Short enough for verifiers to pick up a new key soon after rotation
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.Keys.JWKS())
+}
+
+// RotateKeys replaces the signing key immediately.
+// @Summary Rotate the signing key
+// @Description Generate a new signing key. Tokens signed with the previous key stay valid until they expire. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 201 {object} keys.JWK
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /admin/keys/rotate [post]
+// @Security BearerAuth
+func (h *KeysHandler) RotateKeys(c *fiber.Ctx) error {
+	key, err := h.Keys.Rotate(time.Now().UTC())
+	if err != nil {
+		return apperr.Internal("Could not rotate signing key", err)
+	}
+
+	for _, jwk := range h.Keys.JWKS().Keys {
+		if jwk.Kid == key.ID {
+			return c.Status(fiber.StatusCreated).JSON(jwk)
+		}
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"kid": key.ID, "alg": key.Algorithm})
+}
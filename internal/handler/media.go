@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/media"
+	"ticketing/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// MediaHandler represents the handler for event images.
+type MediaHandler struct {
+	DB    database.Service
+	Store storage.Storage
+}
+
+// NewMediaHandler initializes a new MediaHandler with the given database service and media storage.
+func NewMediaHandler(db database.Service, store storage.Storage) *MediaHandler {
+	return &MediaHandler{DB: db, Store: store}
+}
+
+// UploadEventMedia uploads a cover or gallery image for an event.
+// @Summary Upload an event image
+// @Description Upload a JPEG, PNG or GIF image of at most 10 MB as the event's cover or as a gallery image. A new cover replaces the previous one. A thumbnail at most 400 pixels wide is generated.
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param file formData file true "Image file"
+// @Param kind formData string false "cover or gallery" default(gallery)
+// @Param position formData int false "Sort order inside the gallery"
+// @Success 201 {object} database.EventMedia
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/media [post]
+// @Security BearerAuth
+func (h *MediaHandler) UploadEventMedia(c *fiber.Ctx) error {
+	event, _, err := authorizeEvent(c, h.DB, access.EditEvent)
+	if err != nil || event == nil {
+		return err
+	}
+
+	kind := c.FormValue("kind", database.MediaKindGallery)
+	if !database.ValidMediaKind(kind) {
+		return database.ErrInvalidMediaKind
+	}
+	position, err := strconv.Atoi(c.FormValue("position", "0"))
+	if err != nil {
+		return apperr.BadRequest("invalid_position", "position must be an integer")
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return apperr.BadRequest("file_required", "file is required")
+	}
+	if header.Size > media.MaxUploadSize {
+		return media.ErrTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return apperr.BadRequest("file_unreadable", "Could not read file")
+	}
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	file.Close()
+	if err != nil {
+		return apperr.BadRequest("file_unreadable", "Could not read file")
+	}
+
+	img, err := media.Process(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			return err
+		case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrTooManyPixels), errors.Is(err, media.ErrCorrupt):
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not process image", err)
+	}
+
+	mediaID := uuid.New().String()
+	item := &database.EventMedia{
+		MediaID:      mediaID,
+		EventID:      event.EventID,
+		UserID:       event.UserID,
+		Kind:         kind,
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		Key:          "events/" + event.EventID + "/" + mediaID + img.Ext,
+		ThumbnailKey: "events/" + event.EventID + "/" + mediaID + "_thumb" + img.ThumbnailExt,
+		Position:     position,
+	}
+
+	ctx := c.UserContext()
+	if err := h.Store.Put(ctx, item.Key, bytes.NewReader(data), img.ContentType); err != nil {
+		return apperr.Internal("Could not store image", err)
+	}
+	if err := h.Store.Put(ctx, item.ThumbnailKey, bytes.NewReader(img.Thumbnail), img.ThumbnailContentType); err != nil {
+		log.Printf("Error storing thumbnail: %v", err)
+		h.removeFiles(item)
+		return apperr.Internal("Could not store image", err)
+	}
+
+	replaced, err := h.DB.CreateMedia(item)
+	if err != nil {
+		log.Printf("Error saving media: %v", err)
+		h.removeFiles(item)
+		return apperr.Internal("Could not save image", err)
+	}
+	for i := range replaced {
+		h.removeFiles(&replaced[i])
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(item)
+}
+
+// ListEventMedia lists an event's images.
+// @Summary List event images
+// @Description List an event's cover and gallery images, cover first. Files are served from /media/{mediaID}.
+// @Tags media
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.EventMedia
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/media [get]
+// @Security BearerAuth
+func (h *MediaHandler) ListEventMedia(c *fiber.Ctx) error {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	event, err := h.DB.GetEvent(c.Params("id"))
+	if err == nil {
+		var visible bool
+		if visible, err = access.CanView(h.DB, event, subject); err == nil && !visible {
+			err = database.ErrEventNotFound
+		}
+	}
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrEventNotFound
+		}
+		return apperr.Internal("Could not retrieve event", err)
+	}
+
+	items, err := h.DB.ListEventMedia(event.EventID)
+	if err != nil {
+		return apperr.Internal("Could not list images", err)
+	}
+
+	return c.JSON(items)
+}
+
+// DeleteEventMedia deletes an event image.
+// @Summary Delete an event image
+// @Description Delete one of an event's images and its thumbnail
+// @Tags media
+// @Param id path string true "Event ID"
+// @Param mediaID path string true "Media ID"
+// @Success 204 {object} nil
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/media/{mediaID} [delete]
+// @Security BearerAuth
+func (h *MediaHandler) DeleteEventMedia(c *fiber.Ctx) error {
+	event, _, err := authorizeEvent(c, h.DB, access.EditEvent)
+	if err != nil || event == nil {
+		return err
+	}
+
+	item, err := h.DB.GetMedia(c.Params("mediaID"))
+	if err == nil && item.EventID != event.EventID {
+		err = database.ErrMediaNotFound
+	}
+	if err == nil {
+		err = h.DB.DeleteMedia(item.MediaID)
+	}
+	if err != nil {
+		if err == database.ErrMediaNotFound {
+			return database.ErrMediaNotFound
+		}
+		return apperr.Internal("Could not delete image", err)
+	}
+
+	h.removeFiles(item)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ServeMedia serves an uploaded image or its thumbnail.
+// @Summary Serve an event image
+// @Description Serve an uploaded image. Files never change under a media ID, so responses are cacheable indefinitely. Images of draft events are only served to the owner, the event team and admins, with a bearer token, and are not cached by shared caches. Images of deleted events are not served.
+// @Tags media
+// @Produce image/jpeg,image/png,image/gif
+// @Param mediaID path string true "Media ID"
+// @Param variant query string false "original or thumbnail" default(original)
+// @Success 200 {file} file
+// @Success 304 {object} nil
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /media/{mediaID} [get]
+// @Security BearerAuth
+func (h *MediaHandler) ServeMedia(c *fiber.Ctx) error {
+	item, err := h.DB.GetMedia(c.Params("mediaID"))
+	if err != nil {
+		if err == database.ErrMediaNotFound {
+			return database.ErrMediaNotFound
+		}
+		return apperr.Internal("Could not retrieve image", err)
+	}
+
+	// Images of deleted events are gone, and those of drafts are only served
+	// to people who may see the draft. Both look like a missing image.
+	event, err := h.DB.GetEvent(item.EventID)
+	if err == nil && event.Status == database.EventStatusDraft {
+		subject, _ := subjectFromRequest(c)
+		var visible bool
+		if visible, err = access.CanView(h.DB, event, subject); err == nil && !visible {
+			err = database.ErrEventNotFound
+		}
+	}
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrMediaNotFound
+		}
+		return apperr.Internal("Could not retrieve image", err)
+	}
+
+	variant := c.Query("variant", "original")
+	key := item.Key
+	switch variant {
+	case "original":
+	case "thumbnail":
+		key = item.ThumbnailKey
+	default:
+		return apperr.BadRequest("invalid_variant", "variant must be original or thumbnail")
+	}
+
+	etag := `"` + item.MediaID + "-" + variant + `"`
+	if event.Status == database.EventStatusDraft {
+		c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	} else {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	}
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	body, info, err := h.Store.Open(c.UserContext(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return database.ErrMediaNotFound
+		}
+		return apperr.Internal("Could not retrieve image", err)
+	}
+
+	c.Set(fiber.HeaderContentType, info.ContentType)
+	c.Set(fiber.HeaderLastModified, info.LastModified.UTC().Format(http.TimeFormat))
+	return c.SendStream(body, int(info.Size))
+}
+
+// removeFiles deletes an image's files from storage, logging failures.
+func (h *MediaHandler) removeFiles(item *database.EventMedia) {
+	for _, key := range []string{item.Key, item.ThumbnailKey} {
+		if err := h.Store.Delete(context.Background(), key); err != nil {
+			log.Printf("Error removing %s from media storage: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"log"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/totp"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// errInvalidMFACode answers a wrong second factor at login.
+var errInvalidMFACode = apperr.New(fiber.StatusUnauthorized, "invalid_mfa_code", "Incorrect two-factor code")
+
+// LoginMFA completes a login with a TOTP or recovery code.
+// @Summary Complete a two-factor login
+// @Description Exchange the mfa_token returned by /users/login and a code from the authenticator app, or an unused recovery code, for access and refresh tokens. A challenge accepts 5 codes and expires after 5 minutes.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.MFALoginDTO true "Challenge token and code"
+// @Success 200 {object} map[string]interface{} "Access and refresh tokens"
+// @Failure 400 {object} map[string]string "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid code or challenge"
+// @Failure 429 {object} map[string]string "Too many failed attempts or account locked"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/login/mfa [post]
+func (h *UserHandler) LoginMFA(c *fiber.Ctx) error {
+	var req database.MFALoginDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := utils.MFAChallengeUser(req.MFAToken)
+	if err != nil {
+		if err == utils.ErrMFAChallengeInvalid {
+			return err
+		}
+		return apperr.Internal("Could not check two-factor login", err)
+	}
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return utils.ErrMFAChallengeInvalid
+		}
+		return apperr.Internal("Could not retrieve user", err)
+	}
+	if err := h.reserveLogin(c, user.Email); err != nil {
+		return err
+	}
+
+	if err := h.checkSecondFactor(user, req.Code); err != nil {
+		if err != database.ErrMFACodeInvalid {
+			return apperr.Internal("Could not check two-factor code", err)
+		}
+		if err := h.loginFailed(c, user.Email, user, database.FailedLoginReasonWrongMFACode); err != nil {
+			return err
+		}
+		return errInvalidMFACode
+	}
+
+	// Two requests racing with the same challenge get one session
+	ended, err := utils.EndMFAChallenge(req.MFAToken)
+	if err != nil {
+		return apperr.Internal("Could not complete two-factor login", err)
+	}
+	if !ended {
+		return utils.ErrMFAChallengeInvalid
+	}
+	if err := utils.ClearLoginFailures(user.Email, c.IP()); err != nil {
+		return apperr.Internal("Could not complete two-factor login", err)
+	}
+
+	return h.issueTokens(c, user, uuid.New().String())
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code
+// and uses it up. It returns database.ErrMFACodeInvalid otherwise.
+func (h *UserHandler) checkSecondFactor(user *database.User, code string) error {
+	if !user.MFAEnabled() {
+		return database.ErrMFACodeInvalid
+	}
+	now := time.Now()
+	if counter, ok := totp.Validate(user.TOTPSecret, code, now); ok {
+		return h.db.UseTOTPCounter(user.UserID, counter)
+	}
+	return h.db.UseRecoveryCode(user.UserID, utils.HashRecoveryCode(code), now)
+}
+
+// verifySecondFactor checks a code for an account action reserved with
+// reserveLogin, counting a wrong code as a failed login like LoginMFA does.
+func (h *UserHandler) verifySecondFactor(c *fiber.Ctx, user *database.User, code string) error {
+	if err := h.checkSecondFactor(user, code); err != nil {
+		if err != database.ErrMFACodeInvalid {
+			return apperr.Internal("Could not check two-factor code", err)
+		}
+		if err := h.loginFailed(c, user.Email, user, database.FailedLoginReasonWrongMFACode); err != nil {
+			return err
+		}
+		return database.ErrMFACodeInvalid
+	}
+	if err := utils.ClearLoginFailures(user.Email, c.IP()); err != nil {
+		log.Printf("Could not clear failed logins of %s: %v", user.UserID, err)
+	}
+	return nil
+}
+
+// currentUser loads the user the request's bearer token belongs to.
+func (h *UserHandler) currentUser(c *fiber.Ctx) (*database.User, error) {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return nil, errInvalidToken
+		}
+		return nil, apperr.Internal("Could not retrieve user", err)
+	}
+	return user, nil
+}
+
+// EnrollTOTP starts two-factor enrollment.
+// @Summary Start two-factor enrollment
+// @Description Create a TOTP secret for the caller. Add it to an authenticator app, usually by showing provisioning_uri as a QR code, then confirm with a code. Enrolling again before confirming replaces the secret.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} map[string]string "secret and provisioning_uri"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 409 {object} map[string]string "Already enabled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/mfa/totp [post]
+// @Security BearerAuth
+func (h *UserHandler) EnrollTOTP(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.MFAEnabled() {
+		return database.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return apperr.Internal("Could not generate secret", err)
+	}
+	if err := h.db.StartMFAEnrollment(user.UserID, secret); err != nil {
+		if err == database.ErrMFAAlreadyEnabled {
+			return err
+		}
+		return apperr.Internal("Could not start enrollment", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, utils.MFAIssuer(), user.Email),
+	})
+}
+
+// ConfirmTOTP finishes two-factor enrollment.
+// @Summary Confirm two-factor enrollment
+// @Description Turn on two-factor authentication with a code from the authenticator app. The response holds recovery codes, shown only once, and a new token pair that no longer needs enrollment.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.MFACodeDTO true "Code from the authenticator app"
+// @Success 200 {object} map[string]interface{} "Recovery codes and new tokens"
+// @Failure 400 {object} map[string]string "Invalid code"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 409 {object} map[string]string "Already enabled or not enrolled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/mfa/totp/confirm [post]
+// @Security BearerAuth
+func (h *UserHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	var req database.MFACodeDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if user.MFAEnabled() {
+		return database.ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return database.ErrMFANotEnrolled
+	}
+	counter, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return database.ErrMFACodeInvalid
+	}
+
+	codes, hashes, err := utils.NewRecoveryCodes()
+	if err != nil {
+		return apperr.Internal("Could not generate recovery codes", err)
+	}
+	now := time.Now()
+	if err := h.db.EnableMFA(user.UserID, counter, hashes, now); err != nil {
+		if err == database.ErrMFANotEnrolled {
+			return err
+		}
+		return apperr.Internal("Could not enable two-factor authentication", err)
+	}
+	user.TOTPEnabledAt = &now
+
+	tokens, err := h.newTokenPair(user, uuid.New().String())
+	if err != nil {
+		return err
+	}
+	tokens["recovery_codes"] = codes
+	return c.JSON(tokens)
+}
+
+// DisableTOTP turns two-factor authentication off.
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication. Requires the password and a current code or recovery code. Refused while the security policy requires 2FA for the caller's role.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.DisableMFADTO true "Password and code"
+// @Success 200 {object} map[string]string "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]string "Invalid code"
+// @Failure 401 {object} map[string]string "Incorrect password"
+// @Failure 403 {object} map[string]string "Required by policy"
+// @Failure 409 {object} map[string]string "Not enabled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Failure 429 {object} map[string]string "Too many failed attempts or account locked"
+// @Router /users/mfa/totp [delete]
+// @Security BearerAuth
+func (h *UserHandler) DisableTOTP(c *fiber.Ctx) error {
+	var req database.DisableMFADTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return database.ErrMFANotEnabled
+	}
+	policy, err := h.db.GetSecurityPolicy()
+	if err != nil {
+		return apperr.Internal("Could not load security policy", err)
+	}
+	if policy.RequiresMFA(user.Role) {
+		return apperr.Forbidden("mfa_required", "Two-factor authentication is required for your role")
+	}
+
+	// A stolen access token must not allow guessing the password or codes
+	// faster than the login does
+	if err := h.reserveLogin(c, user.Email); err != nil {
+		return err
+	}
+	if !utils.ComparePassword(user.PasswordHash, req.Password) {
+		if err := h.loginFailed(c, user.Email, user, database.FailedLoginReasonWrongPassword); err != nil {
+			return err
+		}
+		return apperr.Unauthorized("Incorrect password!")
+	}
+	if err := h.verifySecondFactor(c, user, req.Code); err != nil {
+		return err
+	}
+
+	if err := h.db.DisableMFA(user.UserID); err != nil {
+		if err == database.ErrMFANotEnabled {
+			return err
+		}
+		return apperr.Internal("Could not disable two-factor authentication", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes.
+// @Summary Regenerate recovery codes
+// @Description Replace every recovery code with a new set, shown only once. Requires a current code or an unused recovery code.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.MFACodeDTO true "Code from the authenticator app"
+// @Success 200 {object} map[string]interface{} "New recovery codes"
+// @Failure 400 {object} map[string]string "Invalid code"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 409 {object} map[string]string "Not enabled"
+// @Failure 500 {object} map[string]string "Server error"
+// @Failure 429 {object} map[string]string "Too many failed attempts or account locked"
+// @Router /users/mfa/recovery-codes [post]
+// @Security BearerAuth
+func (h *UserHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req database.MFACodeDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return database.ErrMFANotEnabled
+	}
+	if err := h.reserveLogin(c, user.Email); err != nil {
+		return err
+	}
+	if err := h.verifySecondFactor(c, user, req.Code); err != nil {
+		return err
+	}
+
+	codes, hashes, err := utils.NewRecoveryCodes()
+	if err != nil {
+		return apperr.Internal("Could not generate recovery codes", err)
+	}
+	if err := h.db.ReplaceRecoveryCodes(user.UserID, hashes); err != nil {
+		return apperr.Internal("Could not store recovery codes", err)
+	}
+
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
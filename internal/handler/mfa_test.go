@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"testing"
+	"ticketing/internal/database"
+	"ticketing/internal/totp"
+	"time"
+)
+
+// mfaDB keeps the last accepted TOTP step with the rules of UseTOTPCounter.
+type mfaDB struct {
+	database.Service
+	lastCounter int64
+}
+
+func (m *mfaDB) UseTOTPCounter(userID string, counter int64) error {
+	if counter <= m.lastCounter {
+		return database.ErrMFACodeInvalid
+	}
+	m.lastCounter = counter
+	return nil
+}
+
+func (m *mfaDB) UseRecoveryCode(userID, hash string, now time.Time) error {
+	return database.ErrMFACodeInvalid
+}
+
+func TestSecondFactorRejectsReplay(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := time.Now()
+	user := &database.User{UserID: "u-1", TOTPSecret: secret, TOTPEnabledAt: &enabled}
+	db := &mfaDB{}
+	h := NewUserHandler(db)
+
+	current := totp.Counter(time.Now())
+	code := func(counter int64) string {
+		c, err := totp.Code(secret, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// A code one step ahead, from a fast authenticator clock, is accepted
+	if err := h.checkSecondFactor(user, code(current+1)); err != nil {
+		t.Fatalf("next-step code rejected: %v", err)
+	}
+	if err := h.checkSecondFactor(user, code(current+1)); err != database.ErrMFACodeInvalid {
+		t.Errorf("replayed code: err = %v, want ErrMFACodeInvalid", err)
+	}
+	// Still inside the skew window, but older than the code already used
+	if err := h.checkSecondFactor(user, code(current)); err != database.ErrMFACodeInvalid {
+		t.Errorf("earlier code after a later one: err = %v, want ErrMFACodeInvalid", err)
+	}
+	if db.lastCounter != current+1 {
+		t.Errorf("last counter = %d, want %d", db.lastCounter, current+1)
+	}
+
+	user.TOTPEnabledAt = nil
+	if err := h.checkSecondFactor(user, code(current+1)); err != database.ErrMFACodeInvalid {
+		t.Errorf("code accepted without 2FA enabled: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/oidc"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// oidcLoginTTL is how long the user has to sign in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// OIDCHandler signs users in through an external OpenID Connect provider.
+type OIDCHandler struct {
+	*UserHandler
+	Provider oidc.IdentityProvider
+}
+
+// NewOIDCHandler initializes a new OIDCHandler with the given database
+// service and identity provider.
+func NewOIDCHandler(db database.Service, provider oidc.IdentityProvider) *OIDCHandler {
+	return &OIDCHandler{UserHandler: NewUserHandler(db), Provider: provider}
+}
+
+// oidcLogin is what the callback needs to finish a login, stored under the
+// hash of its state parameter.
+type oidcLogin struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func oidcLoginKey(state string) string {
+	return "oidc:login:" + utils.HashToken(state)
+}
+
+// Login sends the browser to the identity provider.
+// @Summary Sign in with SSO
+// @Description Redirect to the configured OpenID Connect provider. After signing in there, the provider redirects to /auth/oidc/callback.
+// @Tags Users
+// @Success 302 "Redirect to the identity provider"
+// @Failure 500 {object} map[string]string "Server error"
+// @Failure 502 {object} map[string]string "Provider unavailable"
+// @Router /auth/oidc/login [get]
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	state, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		return apperr.Internal("Could not start SSO login", err)
+	}
+	nonce, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		return apperr.Internal("Could not start SSO login", err)
+	}
+	verifier, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		return apperr.Internal("Could not start SSO login", err)
+	}
+
+	login, _ := json.Marshal(oidcLogin{Nonce: nonce, Verifier: verifier})
+	if err := utils.Rdb.Set(context.Background(), oidcLoginKey(state), login, oidcLoginTTL).Err(); err != nil {
+		return apperr.Internal("Could not start SSO login", err)
+	}
+
+	target, err := h.Provider.AuthCodeURL(c.UserContext(), state, nonce, verifier)
+	if err != nil {
+		return apperr.New(fiber.StatusBadGateway, "sso_unavailable", "Could not reach the identity provider")
+	}
+	return c.Redirect(target, fiber.StatusFound)
+}
+
+// Callback finishes an SSO login.
+// @Summary Finish SSO login
+// @Description The identity provider redirects here. The account with the provider's verified email is signed in, linked to the provider on first use, or created. Accounts with 2FA get an mfa_token for /users/login/mfa instead of tokens.
+// @Tags Users
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} map[string]interface{} "Access and refresh tokens, or mfa_required with an mfa_token"
+// @Failure 400 {object} map[string]string "Missing or unknown state"
+// @Failure 401 {object} map[string]string "Sign-in refused by the provider"
+// @Failure 403 {object} map[string]string "Email not verified by the provider"
+// @Failure 502 {object} map[string]string "Provider unavailable"
+// @Router /auth/oidc/callback [get]
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	if reason := c.Query("error"); reason != "" {
+		return apperr.New(fiber.StatusUnauthorized, "sso_denied", "Sign-in was refused by the identity provider: "+reason)
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		return apperr.BadRequest("sso_state_invalid", "code and state are required")
+	}
+
+	login, err := h.takeLogin(state)
+	if err != nil {
+		return err
+	}
+
+	identity, err := h.Provider.Exchange(c.UserContext(), code, login.Nonce, login.Verifier)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			return apperr.New(fiber.StatusUnauthorized, "sso_failed", err.Error())
+		}
+		return apperr.New(fiber.StatusBadGateway, "sso_unavailable", "Could not reach the identity provider")
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return apperr.Forbidden("sso_email_unverified", "The identity provider did not confirm an email address")
+	}
+
+	user, err := h.db.ResolveIdentity(
+		&database.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject},
+		h.newSSOUser(identity),
+	)
+	if err != nil {
+		return apperr.Internal("Could not sign in", err)
+	}
+
+	if user.MFAEnabled() {
+		return h.mfaChallenge(c, user)
+	}
+	return h.issueTokens(c, user, uuid.New().String())
+}
+
+// takeLogin loads and forgets the login a state parameter belongs to, so
+// each callback URL works once.
+func (h *OIDCHandler) takeLogin(state string) (*oidcLogin, error) {
+	ctx := context.Background()
+	raw, err := utils.Rdb.Get(ctx, oidcLoginKey(state)).Bytes()
+	if err == redis.Nil {
+		return nil, apperr.BadRequest("sso_state_invalid", "Login expired or was already used, sign in again")
+	}
+	if err != nil {
+		return nil, apperr.Internal("Could not finish SSO login", err)
+	}
+	n, err := utils.Rdb.Del(ctx, oidcLoginKey(state)).Result()
+	if err != nil {
+		return nil, apperr.Internal("Could not finish SSO login", err)
+	}
+	if n == 0 {
+		return nil, apperr.BadRequest("sso_state_invalid", "Login expired or was already used, sign in again")
+	}
+
+	var login oidcLogin
+	if err := json.Unmarshal(raw, &login); err != nil {
+		return nil, apperr.Internal("Could not finish SSO login", err)
+	}
+	return &login, nil
+}
+
+// newSSOUser is the account created for a first-time SSO user. Its random
+// password cannot be guessed; a password reset sets a real one.
+func (h *OIDCHandler) newSSOUser(identity *oidc.Identity) *database.User {
+	now := time.Now()
+	return &database.User{
+		UserID:          uuid.New().String(),
+		Email:           identity.Email,
+		PasswordHash:    utils.GeneratePassword(uuid.New().String()),
+		FirstName:       identity.GivenName,
+		LastName:        identity.FamilyName,
+		Role:            database.RoleAttendee,
+		EmailVerifiedAt: &now,
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetProfile returns the caller's account.
+// @Summary Get my profile
+// @Description Return the caller's account, including role, verification status and any email change waiting for confirmation.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} database.User
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/me [get]
+// @Security BearerAuth
+func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
+
+// UpdateProfile edits the caller's account.
+// @Summary Update my profile
+// @Description Change the caller's name or phone number. A new email needs current_password; it is stored as pending_email and a confirmation link is mailed to it, and the current address stays in use until the link is followed.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.UpdateProfileDTO true "Fields to change"
+// @Success 200 {object} database.User
+// @Failure 400 {object} map[string]string "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid token or incorrect password"
+// @Failure 409 {object} map[string]string "Email already in use"
+// @Failure 429 {object} map[string]string "Too many attempts or account locked"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/me [patch]
+// @Security BearerAuth
+func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
+	var req database.UpdateProfileDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if req.Email != nil {
+		if err := h.confirmPassword(c, user, req.CurrentPassword); err != nil {
+			return err
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.FirstName != nil {
+		updates["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		updates["last_name"] = *req.LastName
+	}
+	if req.Phone != nil {
+		updates["phone"] = *req.Phone
+	}
+	if req.Email != nil && strings.EqualFold(*req.Email, user.Email) {
+		// Asking for the current address drops a pending change
+		updates["pending_email"] = ""
+	}
+	if len(updates) > 0 {
+		if user, err = h.db.UpdateProfile(user.UserID, updates); err != nil {
+			return apperr.Internal("Could not update profile", err)
+		}
+	}
+
+	if req.Email != nil && !strings.EqualFold(*req.Email, user.Email) {
+		if err := h.requestEmailChange(user, *req.Email); err != nil {
+			return err
+		}
+		user.PendingEmail = *req.Email
+	}
+
+	return c.JSON(user)
+}
+
+// confirmPassword checks the caller's current password. Attempts count
+// against the login throttle, so a stolen access token cannot be used to
+// guess it faster than the login allows.
+func (h *UserHandler) confirmPassword(c *fiber.Ctx, user *database.User, password string) error {
+	if err := h.reserveLogin(c, user.Email); err != nil {
+		return err
+	}
+	if !utils.ComparePassword(user.PasswordHash, password) {
+		if err := h.loginFailed(c, user.Email, user, database.FailedLoginReasonWrongPassword); err != nil {
+			return err
+		}
+		return apperr.Unauthorized("Incorrect password!")
+	}
+	if err := utils.ClearLoginFailures(user.Email, c.IP()); err != nil {
+		log.Printf("Could not clear failed logins of %s: %v", user.UserID, err)
+	}
+	return nil
+}
+
+// requestEmailChange mails a confirmation link to the new address and a
+// notice to the current one.
+func (h *UserHandler) requestEmailChange(user *database.User, email string) error {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return apperr.Internal("Could not request email change", err)
+	}
+	if err := h.db.RequestEmailChange(user.UserID, email, &database.UserToken{
+		TokenHash: hash,
+		UserID:    user.UserID,
+		Purpose:   database.UserTokenPurposeChangeEmail,
+		ExpiresAt: time.Now().Add(utils.EmailVerificationTTL),
+	}); err != nil {
+		if errors.Is(err, database.ErrEmailTaken) {
+			return err
+		}
+		return apperr.Internal("Could not request email change", err)
+	}
+
+	if err := utils.SendEmailChangeEmail(email, token); err != nil {
+		return apperr.Internal("Could not send confirmation email", err)
+	}
+	if err := utils.SendEmailChangeNoticeEmail(user.Email, email); err != nil {
+		log.Printf("Could not notify %s of email change: %v", user.Email, err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the address the link was sent to.
+// @Summary Confirm an email change
+// @Description Follow the link mailed to the new address. The address becomes the account's verified email and is used for future tickets and sign-ins. Every session is signed out.
+// @Tags Users
+// @Produce json
+// @Param token query string true "Token from the confirmation email"
+// @Success 200 {object} map[string]string "Email changed"
+// @Failure 400 {object} map[string]string "Invalid or expired token"
+// @Failure 409 {object} map[string]string "Email already in use"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/email/confirm [get]
+func (h *UserHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return apperr.BadRequest("token_required", "token is required")
+	}
+
+	now := time.Now()
+	user, err := h.db.ConfirmEmailChange(utils.HashToken(token), now)
+	if err != nil {
+		if err == database.ErrUserTokenInvalid {
+			return apperr.Invalid(err)
+		}
+		if err == database.ErrEmailTaken {
+			return err
+		}
+		return apperr.Internal("Could not change email", err)
+	}
+	if err := utils.RevokeUserTokens(user.UserID, now); err != nil {
+		log.Printf("Could not revoke access tokens of %s: %v", user.UserID, err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Email changed, please sign in again", "email": user.Email})
+}
+
+// ListMyTickets lists the tickets the caller booked.
+// @Summary List my tickets
+// @Description List the tickets the caller booked across all events, newest first, each with its event.
+// @Tags Users
+// @Produce json
+// @Param status query string false "active or cancelled"
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param offset query int false "Number of tickets to skip" default(0)
+// @Success 200 {array} database.UserTicket
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/me/tickets [get]
+// @Security BearerAuth
+func (h *UserHandler) ListMyTickets(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	filter := database.TicketFilter{
+		UserID: userID,
+		Status: c.Query("status"),
+		Limit:  c.QueryInt("limit", 50),
+		Offset: c.QueryInt("offset", 0),
+	}
+	if filter.Limit < 1 || filter.Limit > 100 || filter.Offset < 0 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 100 and offset must not be negative")
+	}
+	if filter.Status != "" && filter.Status != database.TicketStatusActive && filter.Status != database.TicketStatusCancelled {
+		return apperr.BadRequest("invalid_query", "status must be active or cancelled")
+	}
+
+	tickets, err := h.db.ListUserTickets(filter)
+	if err != nil {
+		return apperr.Internal("Could not list tickets", err)
+	}
+
+	return c.JSON(tickets)
+}
+
+// ListMyEvents lists the events the caller organizes.
+// @Summary List my events
+// @Description List the events the caller owns, drafts included, with the sorting and cursor pagination of GET /events.
+// @Tags Users
+// @Produce json
+// @Param status query string false "Lifecycle state: draft, published, on_sale, cancelled, completed"
+// @Param sort query string false "Sort key: created_at, starts_at, name, capacity, remaining; prefix with - for descending" default(-created_at)
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size (max 100)" default(20)
+// @Success 200 {object} database.EventPage
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/me/events [get]
+// @Security BearerAuth
+func (h *UserHandler) ListMyEvents(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	filter := database.EventFilter{
+		UserID:   userID,
+		ViewerID: userID,
+		Status:   c.Query("status"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+		Limit:    c.QueryInt("limit", 20),
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 100")
+	}
+
+	page, err := h.db.ListEvents(filter)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidCursor) || errors.Is(err, database.ErrInvalidSort) {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not list events", err)
+	}
+
+	return c.JSON(page)
+}
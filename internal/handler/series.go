@@ -0,0 +1,404 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/recurrence"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// SeriesHandler represents the handler for recurring event series.
+type SeriesHandler struct {
+	DB database.Service
+}
+
+// NewSeriesHandler initializes a new SeriesHandler with the given database service.
+func NewSeriesHandler(db database.Service) *SeriesHandler {
+	return &SeriesHandler{DB: db}
+}
+
+// CreateSeries creates a recurring event series and materializes its occurrences.
+// @Summary Create an event series
+// @Description Create a recurring series from an RRULE (FREQ=DAILY|WEEKLY|MONTHLY with INTERVAL, BYDAY, COUNT, UNTIL), extra dates and exclusions. Each occurrence becomes its own draft event with its own capacity and bookings.
+// @Tags series
+// @Accept json
+// @Produce json
+// @Param series body database.CreateSeriesDTO true "Series Data"
+// @Success 201 {object} database.SeriesWithEvents
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /series [post]
+// @Security BearerAuth
+func (h *SeriesHandler) CreateSeries(c *fiber.Ctx) error {
+	var dto database.CreateSeriesDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	tokenString := strings.Replace(c.Get("Authorization"), "Bearer ", "", 1)
+	userID, err := utils.ExtractUserID(tokenString)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	// Validate the first occurrence; the rest share its duration and time zone.
+	first := database.Event{StartsAt: dto.StartsAt, EndsAt: dto.EndsAt, TimeZone: dto.TimeZone, VenueID: dto.VenueID}
+	if err := first.ValidateSchedule(); err != nil {
+		return apperr.Invalid(err)
+	}
+	if dto.VenueID != nil {
+		if _, err := h.DB.GetVenue(*dto.VenueID); err != nil {
+			if errors.Is(err, database.ErrVenueNotFound) {
+				return apperr.Invalid(err)
+			}
+			return apperr.Internal("Could not verify venue", err)
+		}
+	}
+
+	if err := validateCategory(h.DB, dto.Category); err != nil {
+		return categoryErrorResponse(c, err)
+	}
+	if err := validateEventDetails(h.DB, userID, dto.Category, dto.DetailsSchemaID, dto.EventDetails); err != nil {
+		return detailsErrorResponse(c, err)
+	}
+
+	var rule *recurrence.Rule
+	if dto.RRule != "" {
+		if rule, err = recurrence.Parse(dto.RRule); err != nil {
+			return apperr.Invalid(err)
+		}
+	}
+
+	loc, _ := time.LoadLocation(first.TimeZone)
+	duration := dto.EndsAt.Sub(dto.StartsAt)
+	now := time.Now()
+
+	series := &database.EventSeries{
+		SeriesID:        uuid.New().String(),
+		UserID:          userID,
+		Name:            dto.Name,
+		Description:     dto.Description,
+		Capacity:        dto.Capacity,
+		Category:        dto.Category,
+		TimeZone:        first.TimeZone,
+		VenueID:         dto.VenueID,
+		StartsAt:        dto.StartsAt,
+		DurationMinutes: int(duration / time.Minute),
+		RRule:           dto.RRule,
+		RDates:          dto.RDates,
+		ExDates:         dto.ExDates,
+		DetailsSchemaID: dto.DetailsSchemaID,
+		EventDetails:    dto.EventDetails,
+	}
+
+	var occurrences []database.Event
+	for _, start := range recurrence.Expand(rule, dto.StartsAt, loc, dto.RDates, dto.ExDates) {
+		if !start.After(now) {
+			continue
+		}
+		occurrences = append(occurrences, database.Event{
+			EventID:         uuid.New().String(),
+			Name:            dto.Name,
+			Description:     dto.Description,
+			Capacity:        dto.Capacity,
+			UserID:          userID,
+			Category:        dto.Category,
+			StartsAt:        start,
+			EndsAt:          start.Add(duration),
+			TimeZone:        first.TimeZone,
+			VenueID:         dto.VenueID,
+			SeriesID:        &series.SeriesID,
+			Status:          database.EventStatusDraft,
+			DetailsSchemaID: dto.DetailsSchemaID,
+			EventDetails:    dto.EventDetails,
+		})
+	}
+	if len(occurrences) == 0 {
+		return database.ErrNoOccurrences
+	}
+
+	if err := h.DB.CreateSeries(series, occurrences); err != nil {
+		return apperr.Internal("Could not create series", err)
+	}
+
+	for i := range occurrences {
+		occurrences[i].Localize()
+	}
+	return c.Status(fiber.StatusCreated).JSON(database.SeriesWithEvents{EventSeries: *series, Occurrences: occurrences})
+}
+
+// GetSeries retrieves a series and its occurrences.
+// @Summary Get an event series
+// @Description Retrieve a series and its materialized occurrences ordered by start time. Draft occurrences are only listed for the owner.
+// @Tags series
+// @Produce json
+// @Param id path string true "Series ID"
+// @Success 200 {object} database.SeriesWithEvents
+// @Failure 404 {object} map[string]interface{} "Series not found"
+// @Failure 500 {object} map[string]interface{} "Server error"
+// @Router /series/{id} [get]
+// @Security BearerAuth
+func (h *SeriesHandler) GetSeries(c *fiber.Ctx) error {
+	series, err := h.DB.GetSeries(c.Params("id"))
+	if err != nil {
+		if err == database.ErrSeriesNotFound {
+			return database.ErrSeriesNotFound
+		}
+		return apperr.Internal("Could not retrieve series", err)
+	}
+
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	occurrences, err := h.DB.ListSeriesEvents(series.SeriesID)
+	if err != nil {
+		return apperr.Internal("Could not retrieve occurrences", err)
+	}
+
+	visible := make([]database.Event, 0, len(occurrences))
+	for _, event := range occurrences {
+		if event.VisibleTo(userID) {
+			visible = append(visible, event)
+		}
+	}
+
+	return c.JSON(database.SeriesWithEvents{EventSeries: *series, Occurrences: visible})
+}
+
+// EditOccurrences applies a partial edit to one occurrence, to it and every
+// following occurrence, or to the whole series.
+// @Summary Edit series occurrences
+// @Description Apply a partial edit to an occurrence. scope=this edits only that occurrence, scope=following edits it and every later occurrence, scope=all edits every upcoming occurrence and the series defaults. Occurrences that have already ended or were cancelled are left untouched. The caller needs the edit_event permission on every occurrence in scope, as owner, co-organizer or admin.
+// @Tags series
+// @Accept json
+// @Produce json
+// @Param id path string true "Series ID"
+// @Param eventID path string true "Occurrence event ID"
+// @Param scope query string false "this, following or all" default(this)
+// @Param edit body database.SeriesEditDTO true "Fields to change"
+// @Success 200 {object} database.SeriesWithEvents
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /series/{id}/occurrences/{eventID} [patch]
+// @Security BearerAuth
+func (h *SeriesHandler) EditOccurrences(c *fiber.Ctx) error {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	scope := c.Query("scope", database.EditScopeThis)
+	if scope != database.EditScopeThis && scope != database.EditScopeFollowing && scope != database.EditScopeAll {
+		return apperr.BadRequest("invalid_scope", "scope must be this, following or all")
+	}
+
+	var dto database.SeriesEditDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	var startHour, startMinute int
+	if dto.StartTime != nil {
+		t, err := time.Parse("15:04", *dto.StartTime)
+		if err != nil {
+			return apperr.BadRequest("invalid_start_time", "start_time must be HH:MM")
+		}
+		startHour, startMinute = t.Hour(), t.Minute()
+	}
+	if dto.VenueID != nil {
+		if _, err := h.DB.GetVenue(*dto.VenueID); err != nil {
+			if errors.Is(err, database.ErrVenueNotFound) {
+				return apperr.Invalid(err)
+			}
+			return apperr.Internal("Could not verify venue", err)
+		}
+	}
+
+	series, err := h.DB.GetSeries(c.Params("id"))
+	if err != nil {
+		if err == database.ErrSeriesNotFound {
+			return database.ErrSeriesNotFound
+		}
+		return apperr.Internal("Could not retrieve series", err)
+	}
+
+	occurrences, err := h.DB.ListSeriesEvents(series.SeriesID)
+	if err != nil {
+		return apperr.Internal("Could not retrieve occurrences", err)
+	}
+
+	anchor := -1
+	for i := range occurrences {
+		if occurrences[i].EventID == c.Params("eventID") {
+			anchor = i
+			break
+		}
+	}
+	if anchor == -1 {
+		return database.ErrNotSeriesMember
+	}
+	allowed, err := access.Can(h.DB, &occurrences[anchor], subject, access.EditEvent)
+	if err != nil {
+		return apperr.Internal("Could not check permissions", err)
+	}
+	if !allowed {
+		if visible, _ := access.CanView(h.DB, &occurrences[anchor], subject); !visible {
+			return database.ErrSeriesNotFound
+		}
+		return apperr.Forbidden("permission_required", fmt.Sprintf("You need the %s permission for this event", access.EditEvent))
+	}
+	if occurrences[anchor].HasEnded(time.Now()) {
+		return database.ErrEventInPast
+	}
+
+	var targets []database.Event
+	switch scope {
+	case database.EditScopeThis:
+		targets = occurrences[anchor : anchor+1]
+	case database.EditScopeFollowing:
+		targets = occurrences[anchor:]
+	case database.EditScopeAll:
+		targets = occurrences
+	}
+
+	// Team members are invited per occurrence, so a wider scope needs the
+	// permission on every occurrence it touches.
+	for i := range targets {
+		allowed, err := access.Can(h.DB, &targets[i], subject, access.EditEvent)
+		if err != nil {
+			return apperr.Internal("Could not check permissions", err)
+		}
+		if !allowed {
+			return apperr.Forbidden("permission_required", fmt.Sprintf("You need the %s permission for every occurrence in scope", access.EditEvent)).
+				With("event_id", targets[i].EventID)
+		}
+	}
+
+	if dto.EventDetails != nil {
+		// Private details schemas belong to the series owner
+		if err := validateEventDetails(h.DB, series.UserID, series.Category, series.DetailsSchemaID, *dto.EventDetails); err != nil {
+			return detailsErrorResponse(c, err)
+		}
+	}
+
+	now := time.Now()
+	var edited []database.Event
+	endsAt := make(map[string]time.Time, len(targets))
+	for _, event := range targets {
+		if event.HasEnded(now) || event.IsTerminal() {
+			continue
+		}
+
+		if dto.Name != nil {
+			event.Name = *dto.Name
+		}
+		if dto.Description != nil {
+			event.Description = *dto.Description
+		}
+		if dto.VenueID != nil {
+			event.VenueID = dto.VenueID
+		}
+		if dto.EventDetails != nil {
+			event.EventDetails = *dto.EventDetails
+		}
+		if dto.Capacity != nil {
+			sold, err := h.DB.GetTotalTicketsSold(event.EventID)
+			if err != nil {
+				return apperr.Internal("Could not check tickets sold", err)
+			}
+			if *dto.Capacity < sold {
+				return apperr.From(database.ErrCapacityBelowSold).With("event_id", event.EventID).With("sold", sold)
+			}
+			event.Capacity = *dto.Capacity
+		}
+
+		endsAt[event.EventID] = event.EndsAt
+		duration := event.EndsAt.Sub(event.StartsAt)
+		if dto.DurationMinutes != nil {
+			duration = time.Duration(*dto.DurationMinutes) * time.Minute
+		}
+		if dto.StartTime != nil {
+			// Keep the occurrence's date and move its wall-clock start in the event's zone.
+			y, m, d := event.StartsAt.Date()
+			start := time.Date(y, m, d, startHour, startMinute, 0, 0, event.StartsAt.Location())
+			if event.DoorsOpenAt != nil {
+				doors := start.Add(event.DoorsOpenAt.Sub(event.StartsAt))
+				event.DoorsOpenAt = &doors
+			}
+			event.StartsAt = start
+		}
+		event.EndsAt = event.StartsAt.Add(duration)
+
+		if err := event.ValidateSchedule(); err != nil {
+			return apperr.Invalid(err).With("event_id", event.EventID)
+		}
+		edited = append(edited, event)
+	}
+
+	var seriesUpdate *database.EventSeries
+	if scope == database.EditScopeAll {
+		if dto.Name != nil {
+			series.Name = *dto.Name
+		}
+		if dto.Description != nil {
+			series.Description = *dto.Description
+		}
+		if dto.Capacity != nil {
+			series.Capacity = *dto.Capacity
+		}
+		if dto.VenueID != nil {
+			series.VenueID = dto.VenueID
+		}
+		if dto.DurationMinutes != nil {
+			series.DurationMinutes = *dto.DurationMinutes
+		}
+		if dto.EventDetails != nil {
+			series.EventDetails = *dto.EventDetails
+		}
+		if dto.StartTime != nil {
+			// Later expansions start from DTSTART, and EXDATE/RDATE entries
+			// must keep matching the moved occurrences
+			loc, err := time.LoadLocation(series.TimeZone)
+			if err != nil {
+				return apperr.Internal("Could not load series time zone", err)
+			}
+			series.StartsAt = atClock(series.StartsAt, loc, startHour, startMinute)
+			for i := range series.RDates {
+				series.RDates[i] = atClock(series.RDates[i], loc, startHour, startMinute)
+			}
+			for i := range series.ExDates {
+				series.ExDates[i] = atClock(series.ExDates[i], loc, startHour, startMinute)
+			}
+		}
+		seriesUpdate = series
+	}
+
+	if err := h.DB.UpdateSeriesEvents(seriesUpdate, edited); err != nil {
+		return apperr.Internal("Could not update occurrences", err)
+	}
+
+	for i := range edited {
+		rescheduleCompletion(h.DB, &edited[i], endsAt[edited[i].EventID])
+		edited[i].Localize()
+	}
+	return c.JSON(database.SeriesWithEvents{EventSeries: *series, Occurrences: edited})
+}
+
+// atClock moves t to hour:minute on the same date in loc.
+func atClock(t time.Time, loc *time.Location, hour, minute int) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, hour, minute, 0, 0, loc)
+}
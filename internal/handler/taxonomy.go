@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"errors"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TaxonomyHandler represents the handler for categories, tags and collections.
+type TaxonomyHandler struct {
+	DB database.Service
+}
+
+// NewTaxonomyHandler initializes a new TaxonomyHandler with the given database service.
+func NewTaxonomyHandler(db database.Service) *TaxonomyHandler {
+	return &TaxonomyHandler{DB: db}
+}
+
+// validateCategory checks that a non-empty category slug exists.
+func validateCategory(db database.Service, slug string) error {
+	if slug == "" {
+		return nil
+	}
+	_, err := db.GetCategory(slug)
+	return err
+}
+
+// categoryErrorResponse renders the result of validateCategory.
+func categoryErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, database.ErrCategoryNotFound) {
+		return apperr.Invalid(err)
+	}
+	return apperr.Internal("Could not verify category", err)
+}
+
+// CreateCategory creates a category, optionally under a parent.
+// @Summary Create a category
+// @Description Create a category in the hierarchical taxonomy. Events in a child category also match filters on its ancestors.
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param category body database.CreateCategoryDTO true "Category Data"
+// @Success 201 {object} database.Category
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /categories [post]
+// @Security BearerAuth
+func (h *TaxonomyHandler) CreateCategory(c *fiber.Ctx) error {
+	var dto database.CreateCategoryDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+	if !database.ValidSlug(dto.Slug) {
+		return database.ErrInvalidSlug
+	}
+	if dto.ParentSlug != nil {
+		if err := validateCategory(h.DB, *dto.ParentSlug); err != nil {
+			return categoryErrorResponse(c, err)
+		}
+	}
+
+	category := &database.Category{Slug: dto.Slug, Name: dto.Name, ParentSlug: dto.ParentSlug}
+	if err := h.DB.CreateCategory(category); err != nil {
+		if err == database.ErrCategoryExists {
+			return err
+		}
+		return apperr.Internal("Could not create category", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(category)
+}
+
+// ListCategories lists every category with event counts for facet navigation.
+// @Summary List categories
+// @Description List every category with the number of published or on-sale events in it and its descendants
+// @Tags taxonomy
+// @Produce json
+// @Success 200 {array} database.CategoryCount
+// @Failure 500 {object} map[string]interface{}
+// @Router /categories [get]
+// @Security BearerAuth
+func (h *TaxonomyHandler) ListCategories(c *fiber.Ctx) error {
+	counts, err := h.DB.ListCategoryCounts()
+	if err != nil {
+		return apperr.Internal("Could not list categories", err)
+	}
+
+	return c.JSON(counts)
+}
+
+// ListTags lists the most used tags.
+// @Summary List tags
+// @Description List the most used tags on published or on-sale events with their counts
+// @Tags taxonomy
+// @Produce json
+// @Param limit query int false "Number of tags (max 200)" default(50)
+// @Success 200 {array} database.TagCount
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /tags [get]
+// @Security BearerAuth
+func (h *TaxonomyHandler) ListTags(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		return apperr.BadRequest("invalid_pagination", "limit must be between 1 and 200")
+	}
+
+	counts, err := h.DB.ListTagCounts(limit)
+	if err != nil {
+		return apperr.Internal("Could not list tags", err)
+	}
+
+	return c.JSON(counts)
+}
+
+// CreateCollection creates a curated collection.
+// @Summary Create a collection
+// @Description Create a curated collection of events such as "This weekend" or "Family friendly"
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param collection body database.CreateCollectionDTO true "Collection Data"
+// @Success 201 {object} database.Collection
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /collections [post]
+// @Security BearerAuth
+func (h *TaxonomyHandler) CreateCollection(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	var dto database.CreateCollectionDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+	if !database.ValidSlug(dto.Slug) {
+		return database.ErrInvalidSlug
+	}
+
+	collection := &database.Collection{
+		CollectionID: uuid.New().String(),
+		Slug:         dto.Slug,
+		Name:         dto.Name,
+		Description:  dto.Description,
+		UserID:       userID,
+	}
+
+	if err := h.DB.CreateCollection(collection); err != nil {
+		if err == database.ErrCollectionExists {
+			return err
+		}
+		return apperr.Internal("Could not create collection", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collection)
+}
+
+// ListCollections lists every collection.
+// @Summary List collections
+// @Description List every curated collection
+// @Tags taxonomy
+// @Produce json
+// @Success 200 {array} database.Collection
+// @Failure 500 {object} map[string]interface{}
+// @Router /collections [get]
+// @Security BearerAuth
+func (h *TaxonomyHandler) ListCollections(c *fiber.Ctx) error {
+	collections, err := h.DB.ListCollections()
+	if err != nil {
+		return apperr.Internal("Could not list collections", err)
+	}
+
+	return c.JSON(collections)
+}
+
+// GetCollection retrieves a collection and its events.
+// @Summary Get a collection
+// @Description Retrieve a collection and its events in curated order
+// @Tags taxonomy
+// @Produce json
+// @Param slug path string true "Collection slug"
+// @Success 200 {object} database.CollectionWithEvents
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /collections/{slug} [get]
+// @Security BearerAuth
+func (h *TaxonomyHandler) GetCollection(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	collection, err := h.DB.GetCollection(c.Params("slug"))
+	if err != nil {
+		if err == database.ErrCollectionNotFound {
+			return database.ErrCollectionNotFound
+		}
+		return apperr.Internal("Could not retrieve collection", err)
+	}
+
+	events, err := h.DB.ListCollectionEvents(collection.CollectionID, userID)
+	if err != nil {
+		return apperr.Internal("Could not retrieve collection events", err)
+	}
+
+	return c.JSON(database.CollectionWithEvents{Collection: *collection, Events: events})
+}
+
+// AddCollectionEvent adds an event to a collection.
+// @Summary Add an event to a collection
+// @Description Add an event to a collection the caller curates, or move it to a new position. Admins may edit any collection.
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param slug path string true "Collection slug"
+// @Param entry body database.AddCollectionEventDTO true "Event to add"
+// @Success 204 {object} nil
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /collections/{slug}/events [post]
+// @Security BearerAuth
+func (h *TaxonomyHandler) AddCollectionEvent(c *fiber.Ctx) error {
+	var dto database.AddCollectionEventDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	collection, err := h.curatedCollection(c)
+	if err != nil || collection == nil {
+		return err
+	}
+
+	if _, err := h.DB.GetEvent(dto.EventID); err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrEventNotFound
+		}
+		return apperr.Internal("Could not retrieve event", err)
+	}
+
+	entry := &database.CollectionEvent{CollectionID: collection.CollectionID, EventID: dto.EventID, Position: dto.Position}
+	if err := h.DB.AddEventToCollection(entry); err != nil {
+		return apperr.Internal("Could not add event to collection", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RemoveCollectionEvent removes an event from a collection.
+// @Summary Remove an event from a collection
+// @Description Remove an event from a collection the caller curates. Admins may edit any collection.
+// @Tags taxonomy
+// @Param slug path string true "Collection slug"
+// @Param eventID path string true "Event ID"
+// @Success 204 {object} nil
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /collections/{slug}/events/{eventID} [delete]
+// @Security BearerAuth
+func (h *TaxonomyHandler) RemoveCollectionEvent(c *fiber.Ctx) error {
+	collection, err := h.curatedCollection(c)
+	if err != nil || collection == nil {
+		return err
+	}
+
+	if err := h.DB.RemoveEventFromCollection(collection.CollectionID, c.Params("eventID")); err != nil {
+		if err == database.ErrEventNotFound {
+			return apperr.NotFound("event_not_in_collection", "Event is not in this collection")
+		}
+		return apperr.Internal("Could not remove event from collection", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// curatedCollection loads the collection in the path and checks that the
+// caller curates it or may manage the taxonomy. It writes the error response
+// itself and returns a nil collection when the request should stop.
+func (h *TaxonomyHandler) curatedCollection(c *fiber.Ctx) (*database.Collection, error) {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	collection, err := h.DB.GetCollection(c.Params("slug"))
+	if err != nil {
+		if err == database.ErrCollectionNotFound {
+			return nil, database.ErrCollectionNotFound
+		}
+		return nil, apperr.Internal("Could not retrieve collection", err)
+	}
+
+	if collection.UserID != subject.UserID && !access.RoleCan(subject.Role, access.ManageCategories) {
+		return nil, apperr.Forbidden(apperr.CodeForbidden, "You are not allowed to curate this collection")
+	}
+
+	return collection, nil
+}
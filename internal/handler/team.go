@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TeamHandler represents the handler for event teams.
+type TeamHandler struct {
+	DB database.Service
+}
+
+// NewTeamHandler initializes a new TeamHandler with the given database service.
+func NewTeamHandler(db database.Service) *TeamHandler {
+	return &TeamHandler{DB: db}
+}
+
+// authorizeEvent loads the event in the path and checks that the caller
+// holds perm on it. It writes the error response itself and returns a nil
+// event when the request should stop.
+func authorizeEvent(c *fiber.Ctx, db database.Service, perm access.Permission) (*database.Event, string, error) {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return nil, "", errInvalidToken
+	}
+
+	event, err := db.GetEvent(c.Params("id"))
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return nil, "", database.ErrEventNotFound
+		}
+		return nil, "", apperr.Internal("Could not retrieve event", err)
+	}
+
+	allowed, err := access.Can(db, event, subject, perm)
+	if err != nil {
+		return nil, "", apperr.Internal("Could not check permissions", err)
+	}
+	if !allowed {
+		// Hide drafts from users who cannot see them at all
+		if visible, _ := access.CanView(db, event, subject); !visible {
+			return nil, "", database.ErrEventNotFound
+		}
+		return nil, "", apperr.Forbidden("permission_required", fmt.Sprintf("You need the %s permission for this event", perm))
+	}
+
+	return event, subject.UserID, nil
+}
+
+// ListTeam lists an event's team.
+// @Summary List the event team
+// @Description List the owner's invited team members and pending invitations. Visible to the owner and team members.
+// @Tags team
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.EventMember
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/team [get]
+// @Security BearerAuth
+func (h *TeamHandler) ListTeam(c *fiber.Ctx) error {
+	event, err := h.teamEvent(c)
+	if err != nil || event == nil {
+		return err
+	}
+
+	members, err := h.DB.ListEventMembers(event.EventID)
+	if err != nil {
+		return apperr.Internal("Could not list team", err)
+	}
+
+	return c.JSON(members)
+}
+
+// InviteMember invites a registered user to the event team.
+// @Summary Invite a team member
+// @Description Invite a registered user as co_organizer (edit event, view attendees, issue refunds, scan tickets), box_office (view attendees, issue refunds) or check_in (view attendees, scan tickets). The role applies once the invitee accepts.
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param invitation body database.InviteMemberDTO true "Invitation"
+// @Success 201 {object} database.EventMember
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/team [post]
+// @Security BearerAuth
+func (h *TeamHandler) InviteMember(c *fiber.Ctx) error {
+	var dto database.InviteMemberDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	event, userID, err := authorizeEvent(c, h.DB, access.ManageEvent)
+	if err != nil || event == nil {
+		return err
+	}
+
+	invitee, err := h.DB.GetUserByEmail(dto.Email)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return apperr.NotFound("user_not_found", "No user is registered with this email")
+		}
+		return apperr.Internal("Could not retrieve user", err)
+	}
+	if invitee.UserID == event.UserID {
+		return apperr.BadRequest("owner_invited", "The owner cannot be invited to their own event")
+	}
+
+	member := &database.EventMember{
+		EventID:   event.EventID,
+		UserID:    invitee.UserID,
+		Email:     invitee.Email,
+		Role:      dto.Role,
+		InvitedBy: userID,
+	}
+	if err := h.DB.AddEventMember(member); err != nil {
+		if err == database.ErrMemberExists {
+			return err
+		}
+		return apperr.Internal("Could not invite team member", err)
+	}
+
+	if err := utils.SendTeamInviteEmail(invitee.Email, event.Name, dto.Role); err != nil {
+		log.Printf("Could not send team invitation to %s: %v", invitee.Email, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(member)
+}
+
+// AcceptInvitation accepts the caller's pending invitation to an event team.
+// @Summary Accept a team invitation
+// @Description Accept the caller's pending invitation to the event team
+// @Tags team
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {object} database.EventMember
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/team/accept [post]
+// @Security BearerAuth
+func (h *TeamHandler) AcceptInvitation(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	member, err := h.DB.AcceptEventMember(c.Params("id"), userID)
+	if err != nil {
+		if err == database.ErrMemberNotFound {
+			return apperr.NotFound("invitation_not_found", "No pending invitation for this event")
+		}
+		return apperr.Internal("Could not accept invitation", err)
+	}
+
+	return c.JSON(member)
+}
+
+// UpdateMember changes a team member's role.
+// @Summary Change a team member's role
+// @Description Change the role of a team member or pending invitee
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param userID path string true "Member user ID"
+// @Param member body database.UpdateMemberDTO true "New role"
+// @Success 200 {object} database.EventMember
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/team/{userID} [patch]
+// @Security BearerAuth
+func (h *TeamHandler) UpdateMember(c *fiber.Ctx) error {
+	var dto database.UpdateMemberDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	event, _, err := authorizeEvent(c, h.DB, access.ManageEvent)
+	if err != nil || event == nil {
+		return err
+	}
+
+	member, err := h.DB.UpdateEventMemberRole(event.EventID, c.Params("userID"), dto.Role)
+	if err != nil {
+		if err == database.ErrMemberNotFound {
+			return err
+		}
+		return apperr.Internal("Could not update team member", err)
+	}
+
+	return c.JSON(member)
+}
+
+// RemoveMember removes a user from the event team.
+// @Summary Remove a team member
+// @Description Remove a team member or withdraw an invitation. Members may also remove themselves to leave the team or decline an invitation.
+// @Tags team
+// @Param id path string true "Event ID"
+// @Param userID path string true "Member user ID"
+// @Success 204 {object} nil
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/team/{userID} [delete]
+// @Security BearerAuth
+func (h *TeamHandler) RemoveMember(c *fiber.Ctx) error {
+	memberID := c.Params("userID")
+
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	eventID := c.Params("id")
+	if memberID != userID {
+		event, _, err := authorizeEvent(c, h.DB, access.ManageEvent)
+		if err != nil || event == nil {
+			return err
+		}
+		eventID = event.EventID
+	}
+
+	if err := h.DB.RemoveEventMember(eventID, memberID); err != nil {
+		if err == database.ErrMemberNotFound {
+			return err
+		}
+		return apperr.Internal("Could not remove team member", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListAttendees lists the tickets booked for an event.
+// @Summary List event attendees
+// @Description List the tickets booked for an event. Requires the view_attendees permission.
+// @Tags team
+// @Produce json
+// @Param id path string true "Event ID"
+// @Success 200 {array} database.Ticket
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/attendees [get]
+// @Security BearerAuth
+func (h *TeamHandler) ListAttendees(c *fiber.Ctx) error {
+	event, _, err := authorizeEvent(c, h.DB, access.ViewAttendees)
+	if err != nil || event == nil {
+		return err
+	}
+
+	tickets, err := h.DB.ListEventTickets(event.EventID)
+	if err != nil {
+		return apperr.Internal("Could not list attendees", err)
+	}
+
+	return c.JSON(tickets)
+}
+
+// CheckInTicket lets a ticket holder in.
+// @Summary Check in a ticket
+// @Description Mark a ticket of the event as used. Requires the scan_tickets permission; API keys need the checkin:write scope. A ticket is only let in once: checking it in again gets 409 ticket_checked_in with the time of the first check-in.
+// @Tags team
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param ticketID path string true "Ticket ID"
+// @Success 200 {object} database.Ticket
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Ticket cancelled or already checked in"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/tickets/{ticketID}/checkin [post]
+// @Security BearerAuth
+func (h *TeamHandler) CheckInTicket(c *fiber.Ctx) error {
+	event, _, err := authorizeEvent(c, h.DB, access.ScanTickets)
+	if err != nil || event == nil {
+		return err
+	}
+
+	ticket, err := h.DB.CheckInTicket(event.EventID, c.Params("ticketID"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrTicketCheckedIn):
+			return apperr.From(err).With("checked_in_at", ticket.CheckedInAt)
+		case errors.Is(err, database.ErrTicketNotFound), errors.Is(err, database.ErrTicketCancelled):
+			return err
+		}
+		return apperr.Internal("Could not check in ticket", err)
+	}
+
+	return c.JSON(ticket)
+}
+
+// CancelTicket cancels a ticket for its holder.
+// @Summary Cancel a ticket
+// @Description Cancel an active ticket of the event, which puts its seats back on sale. Requires the issue_refunds permission.
+// @Tags team
+// @Produce json
+// @Param id path string true "Event ID"
+// @Param ticketID path string true "Ticket ID"
+// @Success 200 {object} database.Ticket
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{} "Ticket already cancelled"
+// @Failure 500 {object} map[string]interface{}
+// @Router /events/{id}/tickets/{ticketID}/cancel [post]
+// @Security BearerAuth
+func (h *TeamHandler) CancelTicket(c *fiber.Ctx) error {
+	event, _, err := authorizeEvent(c, h.DB, access.IssueRefunds)
+	if err != nil || event == nil {
+		return err
+	}
+
+	ticket, err := h.DB.CancelTicket(event.EventID, c.Params("ticketID"))
+	if err != nil {
+		if errors.Is(err, database.ErrTicketNotFound) || errors.Is(err, database.ErrTicketCancelled) {
+			return err
+		}
+		return apperr.Internal("Could not cancel ticket", err)
+	}
+
+	return c.JSON(ticket)
+}
+
+// teamEvent loads the event in the path if the caller is its owner or an
+// accepted team member. It writes the error response itself and returns a
+// nil event when the request should stop.
+func (h *TeamHandler) teamEvent(c *fiber.Ctx) (*database.Event, error) {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	event, err := h.DB.GetEvent(c.Params("id"))
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return nil, database.ErrEventNotFound
+		}
+		return nil, apperr.Internal("Could not retrieve event", err)
+	}
+
+	perms, err := access.Permissions(h.DB, event, subject)
+	if err != nil {
+		return nil, apperr.Internal("Could not check permissions", err)
+	}
+	if len(perms) == 0 {
+		return nil, apperr.Forbidden("not_team_member", "You are not on this event's team")
+	}
+
+	return event, nil
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// TemplateHandler represents the handler for organizers' event templates.
+type TemplateHandler struct {
+	DB database.Service
+}
+
+// NewTemplateHandler initializes a new TemplateHandler with the given database service.
+func NewTemplateHandler(db database.Service) *TemplateHandler {
+	return &TemplateHandler{DB: db}
+}
+
+// CreateTemplate saves a named event template.
+// @Summary Create an event template
+// @Description Save a reusable event template. Either give the event fields directly or set from_event_id to copy them from one of your events.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param template body database.CreateEventTemplateDTO true "Template Data"
+// @Success 201 {object} database.EventTemplate
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /templates [post]
+// @Security BearerAuth
+func (h *TemplateHandler) CreateTemplate(c *fiber.Ctx) error {
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	var dto database.CreateEventTemplateDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	var template database.EventTemplate
+	if dto.FromEventID != nil {
+		event, err := h.DB.GetEvent(*dto.FromEventID)
+		if err != nil {
+			if err == database.ErrEventNotFound {
+				return database.ErrEventNotFound
+			}
+			return apperr.Internal("Could not retrieve event", err)
+		}
+		if allowed, err := access.Can(h.DB, event, subject, access.EditEvent); err != nil {
+			return apperr.Internal("Could not check permissions", err)
+		} else if !allowed {
+			return apperr.Forbidden(apperr.CodeForbidden, "You are not allowed to copy this event")
+		}
+		template = database.TemplateFromEvent(event)
+	} else {
+		if dto.EventName == "" || dto.Description == "" || dto.Capacity <= 0 {
+			return apperr.BadRequest(apperr.CodeValidationFailed, "EventName, Description, and Capacity are required")
+		}
+		if dto.TimeZone == "" {
+			dto.TimeZone = "UTC"
+		}
+		if err := validateCategory(h.DB, dto.Category); err != nil {
+			return categoryErrorResponse(c, err)
+		}
+		template = database.EventTemplate{
+			EventName:       dto.EventName,
+			Description:     dto.Description,
+			Capacity:        dto.Capacity,
+			Category:        dto.Category,
+			Tags:            database.NormalizeTags(dto.Tags),
+			TimeZone:        dto.TimeZone,
+			VenueID:         dto.VenueID,
+			DetailsSchemaID: dto.DetailsSchemaID,
+			EventDetails:    dto.EventDetails,
+		}
+	}
+
+	template.TemplateID = uuid.New().String()
+	template.UserID = subject.UserID
+	template.Name = dto.Name
+
+	if err := h.DB.CreateTemplate(&template); err != nil {
+		return apperr.Internal("Could not create event template", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// ListTemplates lists the caller's event templates.
+// @Summary List event templates
+// @Description List the caller's event templates
+// @Tags templates
+// @Produce json
+// @Success 200 {array} database.EventTemplate
+// @Failure 500 {object} map[string]interface{}
+// @Router /templates [get]
+// @Security BearerAuth
+func (h *TemplateHandler) ListTemplates(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	templates, err := h.DB.ListTemplates(userID)
+	if err != nil {
+		return apperr.Internal("Could not list event templates", err)
+	}
+
+	return c.JSON(templates)
+}
+
+// GetTemplate retrieves one of the caller's event templates.
+// @Summary Get an event template
+// @Description Retrieve one of the caller's event templates
+// @Tags templates
+// @Produce json
+// @Param id path string true "Template ID"
+// @Success 200 {object} database.EventTemplate
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /templates/{id} [get]
+// @Security BearerAuth
+func (h *TemplateHandler) GetTemplate(c *fiber.Ctx) error {
+	template, err := h.ownTemplate(c)
+	if err != nil || template == nil {
+		return err
+	}
+
+	return c.JSON(template)
+}
+
+// DeleteTemplate deletes one of the caller's event templates.
+// @Summary Delete an event template
+// @Description Delete one of the caller's event templates. Events created from it are not affected.
+// @Tags templates
+// @Param id path string true "Template ID"
+// @Success 204 {object} nil
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /templates/{id} [delete]
+// @Security BearerAuth
+func (h *TemplateHandler) DeleteTemplate(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	if err := h.DB.DeleteTemplate(c.Params("id"), userID); err != nil {
+		if err == database.ErrTemplateNotFound {
+			return database.ErrTemplateNotFound
+		}
+		return apperr.Internal("Could not delete event template", err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateEventFromTemplate creates a draft event from a template.
+// @Summary Create an event from a template
+// @Description Create a new draft event from one of the caller's templates, scheduled at the given dates
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID"
+// @Param schedule body database.EventScheduleDTO true "Dates for the new event"
+// @Success 201 {object} database.Event
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /templates/{id}/events [post]
+// @Security BearerAuth
+func (h *TemplateHandler) CreateEventFromTemplate(c *fiber.Ctx) error {
+	var dto database.EventScheduleDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	template, err := h.ownTemplate(c)
+	if err != nil || template == nil {
+		return err
+	}
+
+	event := template.NewEvent(dto)
+	event.EventID = uuid.New().String()
+	event.UserID = template.UserID
+
+	return createDraftEvent(c, h.DB, event)
+}
+
+// ownTemplate loads the template in the path if the caller owns it. It
+// writes the error response itself and returns a nil template when the
+// request should stop.
+func (h *TemplateHandler) ownTemplate(c *fiber.Ctx) (*database.EventTemplate, error) {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	template, err := h.DB.GetTemplate(c.Params("id"))
+	if err == nil && template.UserID != userID {
+		err = database.ErrTemplateNotFound
+	}
+	if err != nil {
+		if err == database.ErrTemplateNotFound {
+			return nil, database.ErrTemplateNotFound
+		}
+		return nil, apperr.Internal("Could not retrieve event template", err)
+	}
+
+	return template, nil
+}
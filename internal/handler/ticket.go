@@ -1,7 +1,9 @@
 package handler
 
 import (
-	"log"
+	"errors"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
 	"ticketing/internal/database"
 	"ticketing/internal/queue"
 
@@ -37,7 +39,7 @@ func (h *TicketHandler) GetQueueLength(c *fiber.Ctx) error {
 
 	queueLength, err := h.queue.GetQueueLength(eventID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch queue length"})
+		return apperr.Internal("Could not fetch queue length", err)
 	}
 
 	return c.JSON(fiber.Map{"event_id": eventID, "queue_length": queueLength})
@@ -45,28 +47,47 @@ func (h *TicketHandler) GetQueueLength(c *fiber.Ctx) error {
 
 // GetTicketDetails fetches a ticket and the associated event information
 // @Summary Get ticket details
-// @Description Fetches details of a specific ticket along with the associated event information
+// @Description Fetches details of a specific ticket along with the associated event information. Only the ticket holder and event team members with scan_tickets can see it.
 // @Tags Tickets
 // @Accept  json
 // @Produce  json
 // @Param ticketID path string true "Ticket ID"
 // @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
-// @Router /ticket/{ticketID} [get]
+// @Router /tickets/{ticketID} [get]
+// @Security BearerAuth
 func (h *TicketHandler) GetTicketDetails(c *fiber.Ctx) error {
-	ticketID := c.Params("ticketID")
+	subject, err := subjectFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
 
 	// Fetch ticket details
-	ticket, err := h.db.GetTicket(ticketID)
+	ticket, err := h.db.GetTicket(c.Params("ticketID"))
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Ticket not found"})
+		if errors.Is(err, database.ErrTicketNotFound) {
+			return database.ErrTicketNotFound
+		}
+		return apperr.Internal("Could not retrieve ticket", err)
 	}
 
 	// Fetch associated event details
 	event, err := h.db.GetEvent(ticket.EventID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Event details not found"})
+		return apperr.Internal("Event details not found", err)
+	}
+
+	if ticket.UserID == "" || ticket.UserID != subject.UserID {
+		allowed, err := access.Can(h.db, event, subject, access.ScanTickets)
+		if err != nil {
+			return apperr.Internal("Could not check permissions", err)
+		}
+		// Other people's tickets are not disclosed, not even their existence
+		if !allowed {
+			return database.ErrTicketNotFound
+		}
 	}
 
 	return c.JSON(fiber.Map{"ticket": ticket, "event": event})
@@ -81,22 +102,49 @@ func (h *TicketHandler) GetTicketDetails(c *fiber.Ctx) error {
 // @Param request body database.TicketBookingReq true "Ticket booking request payload"
 // @Success 202 {object} map[string]interface{}
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{} "Email not verified"
+// @Failure 404 {object} map[string]interface{} "Event not found"
+// @Failure 409 {object} map[string]interface{} "Event is not on sale"
 // @Failure 500 {object} map[string]interface{}
 // @Router /ticket/book [post]
+// @Security BearerAuth
 func (h *TicketHandler) AddTicketToQueue(c *fiber.Ctx) error {
 	var req database.TicketBookingReq
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		return errInvalidToken
+	}
+	// Tickets are issued to the verified address of the account
+	req.Email = user.Email
+	req.UserID = user.UserID
+
+	event, err := h.db.GetEvent(req.EventID)
+	if err != nil {
+		if err == database.ErrEventNotFound {
+			return database.ErrEventNotFound
+		}
+		return apperr.Internal("Could not retrieve event", err)
+	}
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request payload"})
+	if event.Status != database.EventStatusOnSale {
+		return database.ErrEventNotOnSale
 	}
 
 	// Generate a unique TicketID
 	req.TicketID = uuid.New().String()
 
-	// Publish the request to RabbitMQ
-	if err := h.queue.PublishTicketRequest(req); err != nil {
-		log.Printf("Failed to enqueue ticket request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not enqueue ticket request"})
+	// Publish the request to RabbitMQ, correlated with the HTTP request ID
+	correlationID, _ := c.Locals("requestid").(string)
+	if err := h.queue.PublishTicketRequest(req, correlationID); err != nil {
+		return apperr.Internal("Could not enqueue ticket request", err)
 	}
 
 	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Ticket booking request added to queue", "ticket_id": req.TicketID})
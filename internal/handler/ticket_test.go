@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/middleware"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ticketDB holds one ticket for an event with a check-in team member.
+type ticketDB struct {
+	database.Service
+}
+
+func (ticketDB) GetTicket(ticketID string) (*database.Ticket, error) {
+	if ticketID != "t-1" {
+		return nil, database.ErrTicketNotFound
+	}
+	return &database.Ticket{TicketID: "t-1", EventID: "e-1", UserID: "holder", Email: "holder@example.com"}, nil
+}
+
+func (ticketDB) GetEvent(eventID string) (*database.Event, error) {
+	return &database.Event{EventID: eventID, UserID: "owner"}, nil
+}
+
+func (ticketDB) GetEventMember(eventID, userID string) (*database.EventMember, error) {
+	accepted := time.Now()
+	switch userID {
+	case "scanner":
+		return &database.EventMember{EventID: eventID, UserID: userID, Role: database.TeamRoleCheckIn, AcceptedAt: &accepted}, nil
+	case "invited":
+		return &database.EventMember{EventID: eventID, UserID: userID, Role: database.TeamRoleCheckIn}, nil
+	}
+	return nil, database.ErrMemberNotFound
+}
+
+func TestGetTicketDetailsAccess(t *testing.T) {
+	h := &TicketHandler{db: ticketDB{}}
+
+	tests := []struct {
+		userID   string
+		ticketID string
+		status   int
+	}{
+		{"holder", "t-1", fiber.StatusOK},
+		{"owner", "t-1", fiber.StatusOK},
+		{"scanner", "t-1", fiber.StatusOK},
+		{"invited", "t-1", fiber.StatusNotFound},
+		{"stranger", "t-1", fiber.StatusNotFound},
+		{"holder", "t-2", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userID+"/"+tt.ticketID, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: apperr.Handler})
+			app.Get("/tickets/:ticketID", func(c *fiber.Ctx) error {
+				c.Locals(middleware.SubjectKey, access.Subject{UserID: tt.userID, Role: database.RoleAttendee})
+				return c.Next()
+			}, h.GetTicketDetails)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/tickets/"+tt.ticketID, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -1,14 +1,31 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"ticketing/internal/apperr"
 	"ticketing/internal/database"
 	"ticketing/internal/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
+// verificationResendInterval is the minimum time between verification or
+// password reset emails to the same user.
+const verificationResendInterval = time.Minute
+
+// errInvalidCredentials is the answer to every failed login.
+var errInvalidCredentials = apperr.New(fiber.StatusUnauthorized, "invalid_credentials", "Incorrect email or password")
+
+// dummyPasswordHash is compared against when the email is unknown so that
+// failed logins take the same time either way.
+var dummyPasswordHash = utils.GeneratePassword(uuid.New().String())
+
 // UserHandler represents the handler for user-related operations.
 type UserHandler struct {
 	db database.Service
@@ -31,12 +48,9 @@ func NewUserHandler(db database.Service) *UserHandler {
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /users/register [post]
 func (h *UserHandler) RegisterUser(c *fiber.Ctx) error {
-	print("entered code")
 	var req database.SignUpDTO
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": err.Error(),
-		})
+	if err := bindJSON(c, &req); err != nil {
+		return err
 	}
 
 	// Hash password and create user
@@ -47,60 +61,606 @@ func (h *UserHandler) RegisterUser(c *fiber.Ctx) error {
 		FirstName:    req.FirstName,
 		LastName:     req.LastName,
 		Phone:        req.Phone,
+		Role:         database.RoleAttendee,
 	}
 
 	if err := h.db.CreateUser(&user); err != nil {
 		log.Printf("Error registering user: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Could not register, try again later!",
-		})
+		return apperr.BadRequest("registration_failed", "Could not register, try again later!")
+	}
+
+	// The account exists either way; a lost email can be resent after login
+	if err := h.sendVerification(&user); err != nil {
+		log.Printf("Could not send verification email to %s: %v", user.Email, err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(user)
 }
 
+// sendVerification stores a new email verification token for the user and
+// mails them the link.
+func (h *UserHandler) sendVerification(user *database.User) error {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := h.db.CreateUserToken(&database.UserToken{
+		TokenHash: hash,
+		UserID:    user.UserID,
+		Purpose:   database.UserTokenPurposeVerifyEmail,
+		ExpiresAt: time.Now().Add(utils.EmailVerificationTTL),
+	}); err != nil {
+		return err
+	}
+	return utils.SendVerificationEmail(user.Email, token)
+}
+
+// VerifyEmail confirms the email address of the user the token was sent to.
+// @Summary Verify email address
+// @Description Follow the link from the verification email. Booking tickets and creating events require a verified email; refresh the access token afterwards to pick up the change.
+// @Tags Users
+// @Produce json
+// @Param token query string true "Token from the verification email"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} map[string]string "Invalid or expired token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/verify [get]
+func (h *UserHandler) VerifyEmail(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return apperr.BadRequest("token_required", "token is required")
+	}
+
+	user, err := h.db.VerifyEmail(utils.HashToken(token), time.Now())
+	if err != nil {
+		if err == database.ErrUserTokenInvalid {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not verify email", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Email verified", "email": user.Email})
+}
+
+// ResendVerification mails a new verification link to the caller.
+// @Summary Resend verification email
+// @Description Send a new verification link to the caller's email address. Limited to one email per minute.
+// @Tags Users
+// @Produce json
+// @Success 202 {object} map[string]string "Verification email sent"
+// @Failure 409 {object} map[string]string "Email already verified"
+// @Failure 429 {object} map[string]string "Sent too recently"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/verify/resend [post]
+// @Security BearerAuth
+func (h *UserHandler) ResendVerification(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return err
+		}
+		return apperr.Internal("Could not retrieve user", err)
+	}
+	if user.EmailVerified() {
+		return apperr.Conflict("email_already_verified", "Email is already verified")
+	}
+
+	allowed, err := utils.Rdb.SetNX(c.UserContext(), "verify:resend:"+user.UserID, 1, verificationResendInterval).Result()
+	if err != nil {
+		return apperr.Internal("Could not send verification email", err)
+	}
+	if !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(verificationResendInterval.Seconds())))
+		return apperr.New(fiber.StatusTooManyRequests, apperr.CodeRateLimited, "A verification email was sent recently, try again later")
+	}
+
+	if err := h.sendVerification(user); err != nil {
+		log.Printf("Could not send verification email to %s: %v", user.Email, err)
+		return apperr.Internal("Could not send verification email", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Verification email sent"})
+}
+
 // LoginUser godoc
 // @Summary User login
-// @Description This endpoint allows users to log in with their email and password.
+// @Description This endpoint allows users to log in with their email and password. Repeated failures delay further attempts for the account and the client IP, and 10 failures within 15 minutes lock the account for 30 minutes and email the owner an unlock link.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param body body database.LoginDTO true "User login details"
-// @Success 200 {object} map[string]string "Login successful with token"
+// @Success 200 {object} map[string]interface{} "Login successful with access and refresh tokens, or mfa_required with an mfa_token for /users/login/mfa"
 // @Failure 400 {object} map[string]string "Validation failed"
-// @Failure 401 {object} map[string]string "Incorrect password"
+// @Failure 401 {object} map[string]string "Incorrect email or password"
+// @Failure 429 {object} map[string]string "Too many failed attempts or account locked"
 // @Failure 500 {object} map[string]string "Error generating token"
 // @Router /users/login [post]
 func (h *UserHandler) LoginUser(c *fiber.Ctx) error {
 	var req database.LoginDTO
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": err.Error(),
-		})
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.reserveLogin(c, req.Email); err != nil {
+		return err
 	}
 
-	// Retrieve user by email
+	// Unknown emails and wrong passwords get the same answer, and both
+	// cost a bcrypt comparison, so the response does not reveal which
+	// emails are registered.
 	user, err := h.db.GetUserByEmail(req.Email)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": "User not found!",
-		})
+		if !errors.Is(err, database.ErrUserNotFound) {
+			return apperr.Internal("Could not retrieve user", err)
+		}
+		utils.ComparePassword(dummyPasswordHash, req.Password)
+		if err := h.loginFailed(c, req.Email, nil, database.FailedLoginReasonUnknownEmail); err != nil {
+			return err
+		}
+		return errInvalidCredentials
 	}
-
-	// Compare hashed password
 	if !utils.ComparePassword(user.PasswordHash, req.Password) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Incorrect password!",
-		})
+		if err := h.loginFailed(c, req.Email, user, database.FailedLoginReasonWrongPassword); err != nil {
+			return err
+		}
+		return errInvalidCredentials
+	}
+
+	// Failures are only cleared once the second factor is in too
+	if user.MFAEnabled() {
+		if err := utils.ReleaseLoginAttempt(c.IP()); err != nil {
+			log.Printf("Could not release login attempt of %s: %v", user.UserID, err)
+		}
+		return h.mfaChallenge(c, user)
+	}
+
+	if err := utils.ClearLoginFailures(req.Email, c.IP()); err != nil {
+		log.Printf("Could not clear failed logins of %s: %v", user.UserID, err)
+	}
+
+	// Each login starts a new refresh token family
+	return h.issueTokens(c, user, uuid.New().String())
+}
+
+// mfaChallenge answers a login whose second factor is still missing with a
+// token for /users/login/mfa.
+func (h *UserHandler) mfaChallenge(c *fiber.Ctx, user *database.User) error {
+	token, err := utils.NewMFAChallenge(user.UserID)
+	if err != nil {
+		return apperr.Internal("Could not start two-factor login", err)
+	}
+	return c.JSON(fiber.Map{
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_in":   int(utils.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// reserveLogin refuses logins for an email or IP with too many recent
+// failures, and otherwise counts the attempt before any credential is
+// checked. Throttling is keyed by email, so unknown emails are delayed and
+// locked exactly like registered ones.
+func (h *UserHandler) reserveLogin(c *fiber.Ctx, email string) error {
+	wait, err := utils.ReserveLogin(email, c.IP())
+	if err == nil {
+		return nil
 	}
+	if err != utils.ErrAccountLocked && err != utils.ErrLoginThrottled {
+		return apperr.Internal("Could not check login attempts", err)
+	}
+	reason := database.FailedLoginReasonThrottled
+	if err == utils.ErrAccountLocked {
+		reason = database.FailedLoginReasonLocked
+	}
+	h.recordFailedLogin(c, email, nil, reason)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+	return err
+}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.UserID)
+// loginFailed records a failed login reserved with reserveLogin and locks
+// the account once it has failed too often. user is nil for unknown emails.
+func (h *UserHandler) loginFailed(c *fiber.Ctx, email string, user *database.User, reason string) error {
+	var userID *string
+	if user != nil {
+		userID = &user.UserID
+	}
+	h.recordFailedLogin(c, email, userID, reason)
+
+	locked, err := utils.RecordLoginFailure(email)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to generate token!",
-		})
+		return apperr.Internal("Could not record failed login", err)
+	}
+	if locked && user != nil {
+		// Mail in the background so the response time does not reveal the account
+		go h.sendUnlock(*user)
+	}
+	return nil
+}
+
+// recordFailedLogin stores an audit record of a failed login. A failure to
+// store it does not change the response.
+func (h *UserHandler) recordFailedLogin(c *fiber.Ctx, email string, userID *string, reason string) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	if err := h.db.RecordFailedLogin(&database.FailedLogin{
+		Email:     email,
+		UserID:    userID,
+		IP:        c.IP(),
+		UserAgent: userAgent,
+		Reason:    reason,
+	}); err != nil {
+		log.Printf("Could not record failed login for %s: %v", email, err)
+	}
+}
+
+// sendUnlock mails a link that lifts the lock of the user's account.
+func (h *UserHandler) sendUnlock(user database.User) {
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		log.Printf("Could not generate unlock token: %v", err)
+		return
+	}
+	if err := h.db.CreateUserToken(&database.UserToken{
+		TokenHash: hash,
+		UserID:    user.UserID,
+		Purpose:   database.UserTokenPurposeUnlockAccount,
+		ExpiresAt: time.Now().Add(utils.AccountLockDuration),
+	}); err != nil {
+		log.Printf("Could not store unlock token: %v", err)
+		return
+	}
+	if err := utils.SendAccountLockedEmail(user.Email, token); err != nil {
+		log.Printf("Could not send account locked email to %s: %v", user.Email, err)
+	}
+}
+
+// UnlockAccount lifts a login lock using the token from the lock email.
+// @Summary Unlock account
+// @Description Follow the link from the email sent when an account is locked after repeated failed logins. The token works once.
+// @Tags Users
+// @Produce json
+// @Param token query string true "Token from the account locked email"
+// @Success 200 {object} map[string]string "Account unlocked"
+// @Failure 400 {object} map[string]string "Invalid or expired token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/unlock [get]
+func (h *UserHandler) UnlockAccount(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return apperr.BadRequest("token_required", "token is required")
+	}
+
+	user, err := h.db.UnlockAccount(utils.HashToken(token), time.Now())
+	if err != nil {
+		if err == database.ErrUserTokenInvalid {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not unlock account", err)
+	}
+	if err := utils.UnlockLogin(user.Email); err != nil {
+		return apperr.Internal("Could not unlock account", err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Account unlocked"})
+}
+
+// issueTokens responds with a new access token and a refresh token in the
+// given family.
+func (h *UserHandler) issueTokens(c *fiber.Ctx, user *database.User, familyID string) error {
+	tokens, err := h.newTokenPair(user, familyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(tokens)
+}
+
+// newTokenPair creates an access token and a refresh token in the given
+// family and returns them as a tokenResponse.
+func (h *UserHandler) newTokenPair(user *database.User, familyID string) (fiber.Map, error) {
+	token, err := h.accessToken(user)
+	if err != nil {
+		return nil, apperr.Internal("Failed to generate token!", err)
+	}
+
+	refresh, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, apperr.Internal("Failed to generate token!", err)
+	}
+	if err := h.db.CreateRefreshToken(&database.RefreshToken{
+		TokenHash: hash,
+		FamilyID:  familyID,
+		UserID:    user.UserID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+	}); err != nil {
+		return nil, apperr.Internal("Failed to generate token!", err)
+	}
+
+	return tokenResponse(token, refresh), nil
+}
+
+// accessToken signs an access token for the user, marking it if the
+// security policy requires 2FA the user has not enabled yet.
+func (h *UserHandler) accessToken(user *database.User) (string, error) {
+	policy, err := h.db.GetSecurityPolicy()
+	if err != nil {
+		return "", err
+	}
+	return utils.GenerateToken(user, policy.RequiresMFA(user.Role) && !user.MFAEnabled())
+}
+
+// tokenResponse is the body returned by login and refresh. "token" is kept
+// for clients written before refresh tokens existed.
+func tokenResponse(access, refresh string) fiber.Map {
+	return fiber.Map{
+		"token":         access,
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new token pair.
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token works once; presenting a used one revokes every token from the same login.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.RefreshDTO true "Refresh token"
+// @Success 200 {object} map[string]interface{} "New token pair"
+// @Failure 400 {object} map[string]string "Validation failed"
+// @Failure 401 {object} map[string]string "Invalid, expired or reused refresh token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/refresh [post]
+func (h *UserHandler) RefreshToken(c *fiber.Ctx) error {
+	var req database.RefreshDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	refresh, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return apperr.Internal("Failed to generate token!", err)
+	}
+	next := &database.RefreshToken{TokenHash: hash, ExpiresAt: now.Add(utils.RefreshTokenTTL)}
+
+	rotated, err := h.db.RotateRefreshToken(utils.HashToken(req.RefreshToken), next, now)
+	if err != nil {
+		if err == database.ErrRefreshTokenInvalid || err == database.ErrRefreshTokenReused {
+			return err
+		}
+		return apperr.Internal("Failed to refresh token!", err)
+	}
+
+	// Read the user again so role changes take effect on refresh
+	user, err := h.db.GetUser(rotated.UserID)
+	if err != nil {
+		return database.ErrRefreshTokenInvalid
+	}
+
+	token, err := h.accessToken(user)
+	if err != nil {
+		return apperr.Internal("Failed to generate token!", err)
+	}
+
+	return c.JSON(tokenResponse(token, refresh))
+}
+
+// LogoutUser revokes the caller's access token and, if given, the refresh
+// token family of the session.
+// @Summary Log out
+// @Description Revoke the presented access token immediately and, when refresh_token is given, every refresh token from the same login.
+// @Tags Users
+// @Accept json
+// @Param body body database.RefreshDTO false "Refresh token of the session"
+// @Success 204 "Logged out"
+// @Failure 401 {object} map[string]string "Invalid token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/logout [post]
+// @Security BearerAuth
+func (h *UserHandler) LogoutUser(c *fiber.Ctx) error {
+	claims, err := utils.ParseToken(strings.Replace(c.Get("Authorization"), "Bearer ", "", 1))
+	if err != nil {
+		return errInvalidToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if err := utils.RevokeToken(jti, time.Unix(int64(exp), 0)); err != nil {
+		return apperr.Internal("Could not log out", err)
+	}
+
+	var req database.RefreshDTO
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return errInvalidPayload
+		}
+	}
+	if req.RefreshToken != "" {
+		err := h.db.RevokeRefreshToken(utils.HashToken(req.RefreshToken), time.Now())
+		if err != nil && err != database.ErrRefreshTokenInvalid {
+			return apperr.Internal("Could not log out", err)
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RequestOrganizer asks for the organizer role.
+// @Summary Request the organizer role
+// @Description Ask an admin to make the caller an organizer, which allows creating events. The new role applies to tokens issued after approval.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} database.User
+// @Failure 409 {object} map[string]string "Already an organizer or request pending"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/organizer-request [post]
+// @Security BearerAuth
+func (h *UserHandler) RequestOrganizer(c *fiber.Ctx) error {
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	user, err := h.db.RequestOrganizer(userID)
+	if err != nil {
+		switch err {
+		case database.ErrAlreadyOrganizer, database.ErrOrganizerRequestDup:
+			return err
+		case database.ErrUserNotFound:
+			return err
+		}
+		return apperr.Internal("Could not request organizer role", err)
+	}
+
+	return c.JSON(user)
+}
+
+// ForgotPassword mails a password reset link if the email belongs to an
+// account.
+// @Summary Request a password reset
+// @Description Email a single-use password reset link valid for one hour. The response is the same whether or not the email is registered.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.ForgotPasswordDTO true "Account email"
+// @Success 202 {object} map[string]string "Reset email sent if the account exists"
+// @Failure 400 {object} map[string]string "Validation failed"
+// @Router /users/password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req database.ForgotPasswordDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	// Answer before looking the account up so timing does not reveal it
+	go h.sendPasswordReset(req.Email)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If an account exists for this email, a reset link has been sent",
+	})
+}
+
+// sendPasswordReset mails a reset link to the account with the given email,
+// at most once per verificationResendInterval.
+func (h *UserHandler) sendPasswordReset(email string) {
+	user, err := h.db.GetUserByEmail(email)
+	if err != nil {
+		return
+	}
+
+	allowed, err := utils.Rdb.SetNX(context.Background(), "password:forgot:"+user.UserID, 1, verificationResendInterval).Result()
+	if err != nil || !allowed {
+		return
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		log.Printf("Could not generate password reset token: %v", err)
+		return
+	}
+	if err := h.db.CreateUserToken(&database.UserToken{
+		TokenHash: hash,
+		UserID:    user.UserID,
+		Purpose:   database.UserTokenPurposeResetPassword,
+		ExpiresAt: time.Now().Add(utils.PasswordResetTTL),
+	}); err != nil {
+		log.Printf("Could not store password reset token: %v", err)
+		return
+	}
+	if err := utils.SendPasswordResetEmail(user.Email, token); err != nil {
+		log.Printf("Could not send password reset email to %s: %v", user.Email, err)
+	}
+}
+
+// ResetPassword sets a new password using a token from the reset email.
+// @Summary Reset password
+// @Description Set a new password with the token from the reset email. The token works once, and every session of the account is signed out.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.ResetPasswordDTO true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password reset"
+// @Failure 400 {object} map[string]string "Invalid or expired token, or invalid password"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/password/reset [post]
+func (h *UserHandler) ResetPassword(c *fiber.Ctx) error {
+	var req database.ResetPasswordDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user, err := h.db.ResetPassword(utils.HashToken(req.Token), utils.GeneratePassword(req.Password), now)
+	if err != nil {
+		if err == database.ErrUserTokenInvalid {
+			return apperr.Invalid(err)
+		}
+		return apperr.Internal("Could not reset password", err)
+	}
+
+	if err := utils.RevokeUserTokens(user.UserID, now); err != nil {
+		log.Printf("Could not revoke access tokens of %s: %v", user.UserID, err)
+	}
+	// Proving control of the email also lifts a login lock
+	if err := utils.UnlockLogin(user.Email); err != nil {
+		log.Printf("Could not unlock logins of %s: %v", user.UserID, err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Password reset, sign in with your new password"})
+}
+
+// ChangePassword changes the caller's password.
+// @Summary Change password
+// @Description Change the caller's password. The current password is required and is checked under the login throttle: wrong guesses count towards the account lock. Every other session is signed out and a new token pair is returned.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param body body database.ChangePasswordDTO true "Current and new password"
+// @Success 200 {object} map[string]interface{} "New access and refresh tokens"
+// @Failure 400 {object} map[string]string "Invalid password"
+// @Failure 401 {object} map[string]string "Incorrect current password"
+// @Failure 429 {object} map[string]string "Too many attempts or account locked"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /users/password/change [post]
+// @Security BearerAuth
+func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
+	var req database.ChangePasswordDTO
+	if err := bindJSON(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := userIDFromRequest(c)
+	if err != nil {
+		return errInvalidToken
+	}
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		if err == database.ErrUserNotFound {
+			return errInvalidToken
+		}
+		return apperr.Internal("Could not retrieve user", err)
+	}
+	if err := h.confirmPassword(c, user, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := h.db.ChangePassword(user.UserID, utils.GeneratePassword(req.NewPassword), now); err != nil {
+		return apperr.Internal("Could not change password", err)
+	}
+	if err := utils.RevokeUserTokens(user.UserID, now); err != nil {
+		log.Printf("Could not revoke access tokens of %s: %v", user.UserID, err)
 	}
 
-	return c.JSON(fiber.Map{"token": token})
+	// Keep the caller signed in with a fresh session
+	return h.issueTokens(c, user, uuid.New().String())
 }
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"ticketing/internal/apperr"
+	"ticketing/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Errors shared by many handlers.
+var (
+	errInvalidToken   = apperr.Unauthorized("Invalid or expired token")
+	errInvalidPayload = apperr.BadRequest(apperr.CodeInvalidRequest, "Invalid request payload")
+)
+
+// bindJSON parses the request body into dto and checks its validate tags.
+// Failures are returned as errors the ErrorHandler renders; a validation
+// failure lists every failing field:
+//
+//	{"code": "validation_failed", "fields": [{"field": "quantity", "rule": "min", "param": "1", "message": "quantity must be at least 1"}], ...}
+func bindJSON(c *fiber.Ctx, dto interface{}) error {
+	if err := c.BodyParser(dto); err != nil {
+		return errInvalidPayload
+	}
+	return validation.Struct(dto)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"strings"
+	"ticketing/internal/apperr"
+	"ticketing/internal/database"
+	"ticketing/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// VenueHandler represents the handler for venue-related operations.
+type VenueHandler struct {
+	DB database.Service
+}
+
+// NewVenueHandler initializes a new VenueHandler with the given database service.
+func NewVenueHandler(db database.Service) *VenueHandler {
+	return &VenueHandler{DB: db}
+}
+
+// CreateVenue creates a new venue.
+// @Summary Create a venue
+// @Description Create a venue with a name, address and geo coordinates that events can reference
+// @Tags venues
+// @Accept json
+// @Produce json
+// @Param venue body database.CreateVenueDTO true "Venue Data"
+// @Success 201 {object} database.Venue
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /venues [post]
+// @Security BearerAuth
+func (h *VenueHandler) CreateVenue(c *fiber.Ctx) error {
+	var dto database.CreateVenueDTO
+	if err := bindJSON(c, &dto); err != nil {
+		return err
+	}
+
+	tokenString := strings.Replace(c.Get("Authorization"), "Bearer ", "", 1)
+	userID, err := utils.ExtractUserID(tokenString)
+	if err != nil {
+		return errInvalidToken
+	}
+
+	venue := &database.Venue{
+		VenueID:   uuid.New().String(),
+		Name:      dto.Name,
+		Address:   dto.Address,
+		Latitude:  dto.Latitude,
+		Longitude: dto.Longitude,
+		UserID:    userID,
+	}
+
+	if err := h.DB.CreateVenue(venue); err != nil {
+		return apperr.Internal("Could not create venue", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(venue)
+}
+
+// GetVenue retrieves a venue by ID.
+// @Summary Get a venue by ID
+// @Description Retrieve a venue's details by its unique venue ID
+// @Tags venues
+// @Produce json
+// @Param id path string true "Venue ID"
+// @Success 200 {object} database.Venue
+// @Failure 404 {object} map[string]interface{} "Venue not found"
+// @Failure 500 {object} map[string]interface{} "Server error"
+// @Router /venues/{id} [get]
+// @Security BearerAuth
+func (h *VenueHandler) GetVenue(c *fiber.Ctx) error {
+	venue, err := h.DB.GetVenue(c.Params("id"))
+	if err != nil {
+		if err == database.ErrVenueNotFound {
+			return database.ErrVenueNotFound
+		}
+		return apperr.Internal("Could not retrieve venue", err)
+	}
+
+	return c.JSON(venue)
+}
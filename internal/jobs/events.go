@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"ticketing/internal/database"
+	"ticketing/internal/scheduler"
+	"ticketing/internal/utils"
+	"time"
+)
+
+// Job names handled by the worker.
+const (
+	EventComplete        = "event.complete"
+	EventNotifyCancelled = "event.notify_cancelled"
+)
+
+// EventPayload identifies the event a job acts on.
+type EventPayload struct {
+	EventID string `json:"event_id"`
+}
+
+// Register binds every job handler to the scheduler.
+func Register(s *scheduler.Scheduler, db database.Service) {
+	s.Register(EventComplete, completeEvent(db))
+	s.Register(EventNotifyCancelled, notifyCancelled(db))
+}
+
+func decodeEventPayload(job *database.Job) (EventPayload, error) {
+	var p EventPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		return p, err
+	}
+	if p.EventID == "" {
+		return p, errors.New("event_id missing from payload")
+	}
+	return p, nil
+}
+
+// ScheduleCompletion enqueues the job that completes event at its end time.
+func ScheduleCompletion(db database.Service, event *database.Event) error {
+	_, err := scheduler.Enqueue(db, EventComplete, EventPayload{EventID: event.EventID}, event.EndsAt)
+	return err
+}
+
+// completeEvent marks an event completed once it has ended. It runs at the
+// event's end time and is a no-op if the event was cancelled or completed in
+// the meantime. If the event was rescheduled to end later, the job enqueues
+// itself again for the new end time.
+func completeEvent(db database.Service) scheduler.Handler {
+	return func(ctx context.Context, job *database.Job) error {
+		p, err := decodeEventPayload(job)
+		if err != nil {
+			return err
+		}
+
+		event, err := db.GetEvent(p.EventID)
+		if err != nil {
+			if errors.Is(err, database.ErrEventNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if !event.CanTransition(database.EventStatusCompleted) {
+			return nil
+		}
+		if !event.HasEnded(time.Now()) {
+			return ScheduleCompletion(db, event)
+		}
+
+		err = db.SetEventStatus(event.EventID, event.Status, database.EventStatusCompleted)
+		if errors.Is(err, database.ErrInvalidTransition) {
+			return nil
+		}
+		return err
+	}
+}
+
+// notifyCancelled emails every holder of a cancelled ticket for the event.
+func notifyCancelled(db database.Service) scheduler.Handler {
+	return func(ctx context.Context, job *database.Job) error {
+		p, err := decodeEventPayload(job)
+		if err != nil {
+			return err
+		}
+
+		// Force-deleted events are cancelled before they are soft-deleted.
+		event, err := db.GetEvent(p.EventID)
+		if errors.Is(err, database.ErrEventNotFound) {
+			event, err = db.GetDeletedEvent(p.EventID)
+		}
+		if err != nil {
+			return err
+		}
+
+		tickets, err := db.ListEventTickets(event.EventID)
+		if err != nil {
+			return err
+		}
+
+		notified := make(map[string]bool)
+		var failed int
+		for _, t := range tickets {
+			if t.Status != database.TicketStatusCancelled || notified[t.Email] {
+				continue
+			}
+			notified[t.Email] = true
+			if err := utils.SendEventCancelledEmail(t.Email, event.Name); err != nil {
+				log.Printf("Could not notify %s about cancelled event %s: %v", t.Email, event.EventID, err)
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d cancellation emails failed", failed)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,276 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema used for event details templates: type, properties, required,
+// additionalProperties, enum, numeric and length bounds, pattern, format
+// and array items.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a compiled JSON Schema node.
+type Schema struct {
+	Type                 TypeList           `json:"type,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// TypeList holds the "type" keyword, which may be a single name or a list.
+type TypeList []string
+
+// UnmarshalJSON accepts both "string" and ["string", "null"].
+func (t *TypeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = TypeList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+var knownFormats = map[string]bool{
+	"": true, "date-time": true, "date": true, "email": true, "uri": true,
+}
+
+// ValidationError describes one way a document fails its schema.
+type ValidationError struct {
+	Path    string `json:"path"`    // JSON pointer-like path to the offending value
+	Message string `json:"message"` // Human-readable reason
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Compile parses and checks a schema document.
+func Compile(raw []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.prepare("#"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) prepare(path string) error {
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("invalid schema at %s: unknown type %q", path, t)
+		}
+	}
+	if !knownFormats[s.Format] {
+		return fmt.Errorf("invalid schema at %s: unsupported format %q", path, s.Format)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema at %s: bad pattern: %w", path, err)
+		}
+		s.pattern = re
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("invalid schema at %s/properties/%s: empty schema", path, name)
+		}
+		if err := prop.prepare(path + "/properties/" + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.prepare(path + "/items"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a decoded JSON value and returns every violation found.
+func (s *Schema) Validate(value interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate("", value, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		*errs = append(*errs, ValidationError{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		fail("must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		fail("must be one of %s", formatEnum(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("property %q is not allowed", k)
+				}
+				continue
+			}
+			prop.validate(path+"/"+k, v[k], errs)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s/%d", path, i), item, errs)
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %s", s.Pattern)
+		}
+		if msg := checkFormat(s.Format, v); msg != "" {
+			fail("%s", msg)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	for _, t := range s.Type {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == float64(int64(v))) {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkFormat(format, v string) string {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return "must be an RFC3339 date-time"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case "email":
+		if _, err := mail.ParseAddress(v); err != nil {
+			return "must be an email address"
+		}
+	case "uri":
+		if u, err := url.Parse(v); err != nil || u.Scheme == "" {
+			return "must be an absolute URI"
+		}
+	}
+	return ""
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	got, _ := json.Marshal(value)
+	for _, e := range enum {
+		want, _ := json.Marshal(e)
+		if string(got) == string(want) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	parts := make([]string, 0, len(enum))
+	for _, e := range enum {
+		b, _ := json.Marshal(e)
+		parts = append(parts, string(b))
+	}
+	return strings.Join(parts, ", ")
+}
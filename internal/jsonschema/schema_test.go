@@ -0,0 +1,180 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const concertSchema = `{
+	"type": "object",
+	"required": ["headliner", "genre"],
+	"additionalProperties": false,
+	"properties": {
+		"headliner": {"type": "string", "minLength": 2, "maxLength": 20},
+		"genre": {"enum": ["rock", "jazz", 1]},
+		"age_limit": {"type": "integer", "minimum": 0, "maximum": 21},
+		"price": {"type": ["number", "null"], "minimum": 0.5},
+		"code": {"type": "string", "pattern": "^[A-Z]{3}-\\d+$"},
+		"doors": {"type": "string", "format": "date-time"},
+		"day": {"type": "string", "format": "date"},
+		"contact": {"type": "string", "format": "email"},
+		"site": {"type": "string", "format": "uri"},
+		"support": {
+			"type": "array",
+			"minItems": 1,
+			"maxItems": 2,
+			"items": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string"},
+					"slot": {"type": "object", "properties": {"minutes": {"type": "integer", "minimum": 10}}}
+				}
+			}
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(concertSchema))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []ValidationError
+	}{
+		{
+			name: "valid",
+			doc: `{"headliner": "Nova", "genre": "jazz", "age_limit": 18, "price": null, "code": "ABC-12",
+				"doors": "2030-05-01T19:00:00+02:00", "day": "2030-05-01", "contact": "box@example.com",
+				"site": "https://example.com", "support": [{"name": "Echo", "slot": {"minutes": 30}}]}`,
+		},
+		{
+			name: "numeric enum value",
+			doc:  `{"headliner": "Nova", "genre": 1}`,
+		},
+		{
+			name: "root type",
+			doc:  `["Nova"]`,
+			want: []ValidationError{{"/", "must be of type object"}},
+		},
+		{
+			name: "required and additional properties",
+			doc:  `{"headliner": "Nova", "stage": "B"}`,
+			want: []ValidationError{
+				{"/", `missing required property "genre"`},
+				{"/", `property "stage" is not allowed`},
+			},
+		},
+		{
+			name: "property types",
+			doc:  `{"headliner": 7, "genre": "rock", "age_limit": 4.5, "price": "free"}`,
+			want: []ValidationError{
+				{"/age_limit", "must be of type integer"},
+				{"/headliner", "must be of type string"},
+				{"/price", "must be of type number or null"},
+			},
+		},
+		{
+			name: "enum",
+			doc:  `{"headliner": "Nova", "genre": "Rock"}`,
+			want: []ValidationError{{"/genre", `must be one of "rock", "jazz", 1`}},
+		},
+		{
+			name: "numeric bounds",
+			doc:  `{"headliner": "Nova", "genre": "rock", "age_limit": 22, "price": 0.25}`,
+			want: []ValidationError{
+				{"/age_limit", "must be <= 21"},
+				{"/price", "must be >= 0.5"},
+			},
+		},
+		{
+			name: "length counts characters",
+			doc:  `{"headliner": "É", "genre": "rock"}`,
+			want: []ValidationError{{"/headliner", "must be at least 2 characters"}},
+		},
+		{
+			name: "max length",
+			doc:  `{"headliner": "` + strings.Repeat("ü", 21) + `", "genre": "rock"}`,
+			want: []ValidationError{{"/headliner", "must be at most 20 characters"}},
+		},
+		{
+			name: "pattern",
+			doc:  `{"headliner": "Nova", "genre": "rock", "code": "abc-12"}`,
+			want: []ValidationError{{"/code", `must match pattern ^[A-Z]{3}-\d+$`}},
+		},
+		{
+			name: "formats",
+			doc: `{"headliner": "Nova", "genre": "rock", "doors": "2030-05-01 19:00", "day": "01/05/2030",
+				"contact": "not an address", "site": "/relative"}`,
+			want: []ValidationError{
+				{"/contact", "must be an email address"},
+				{"/day", "must be a date (YYYY-MM-DD)"},
+				{"/doors", "must be an RFC3339 date-time"},
+				{"/site", "must be an absolute URI"},
+			},
+		},
+		{
+			name: "array bounds",
+			doc:  `{"headliner": "Nova", "genre": "rock", "support": []}`,
+			want: []ValidationError{{"/support", "must have at least 1 items"}},
+		},
+		{
+			name: "too many items",
+			doc:  `{"headliner": "Nova", "genre": "rock", "support": [{"name": "A"}, {"name": "B"}, {"name": "C"}]}`,
+			want: []ValidationError{{"/support", "must have at most 2 items"}},
+		},
+		{
+			name: "nested objects in arrays",
+			doc:  `{"headliner": "Nova", "genre": "rock", "support": [{"name": "A", "slot": {"minutes": 5}}, {"slot": "late"}]}`,
+			want: []ValidationError{
+				{"/support/0/slot/minutes", "must be >= 10"},
+				{"/support/1", `missing required property "name"`},
+				{"/support/1/slot", "must be of type object"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("bad test document: %v", err)
+			}
+			got := schema.Validate(doc)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not json", `{"type":`, "invalid schema"},
+		{"bad type keyword", `{"type": 3}`, "type must be a string or an array of strings"},
+		{"unknown type", `{"type": "date"}`, `unknown type "date"`},
+		{"unsupported format", `{"type": "string", "format": "hostname"}`, `unsupported format "hostname"`},
+		{"bad pattern", `{"type": "string", "pattern": "("}`, "bad pattern"},
+		{"nested", `{"properties": {"tags": {"items": {"type": "text"}}}}`, "#/properties/tags/items"},
+		{"empty property", `{"properties": {"name": null}}`, "empty schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"ticketing/internal/database"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Supported signing algorithms.
+const (
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// rsaBits is the size of generated RSA keys.
+const rsaBits = 2048
+
+// reloadCooldown limits how often an unknown kid triggers a reload, so
+// tokens with made-up kids cannot hammer the database.
+const reloadCooldown = 10 * time.Second
+
+var (
+	ErrUnknownKey           = errors.New("token was signed with an unknown key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoSigningKey         = errors.New("no active signing key")
+)
+
+// Key is a private signing key with its metadata.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+	ExpiresAt *time.Time // Nil while the key signs new tokens
+}
+
+// Method returns the JWT signing method of the key.
+func (k *Key) Method() jwt.SigningMethod {
+	if k.Algorithm == EdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Public returns the public half of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// Generate creates a new key for alg. Its ID is derived from the public key
+// so it is stable and unique across replicas.
+func Generate(alg string, now time.Time) (*Key, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+	switch alg {
+	case RS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	case EdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &Key{
+		ID:        base64.RawURLEncoding.EncodeToString(sum[:16]),
+		Algorithm: alg,
+		Private:   signer,
+		CreatedAt: now,
+	}, nil
+}
+
+func encode(k *Key, s *sealer) (*database.SigningKey, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.seal(k.ID, der)
+	if err != nil {
+		return nil, err
+	}
+	return &database.SigningKey{
+		KeyID:      k.ID,
+		Algorithm:  k.Algorithm,
+		PrivateKey: sealed,
+		CreatedAt:  k.CreatedAt,
+	}, nil
+}
+
+// decode opens a stored key. Keys written before encryption are plaintext
+// PEM; they are still accepted so that the caller can seal them.
+func decode(row database.SigningKey, s *sealer) (*Key, error) {
+	var der []byte
+	if isSealed(row.PrivateKey) {
+		var err error
+		if der, err = s.open(row.KeyID, row.PrivateKey); err != nil {
+			return nil, err
+		}
+	} else {
+		block, _ := pem.Decode([]byte(row.PrivateKey))
+		if block == nil {
+			return nil, errors.New("private key is not PEM encoded")
+		}
+		der = block.Bytes
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if row.Algorithm != RS256 {
+			return nil, fmt.Errorf("RSA key stored as %s", row.Algorithm)
+		}
+		signer = k
+	case ed25519.PrivateKey:
+		if row.Algorithm != EdDSA {
+			return nil, fmt.Errorf("Ed25519 key stored as %s", row.Algorithm)
+		}
+		signer = k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, parsed)
+	}
+
+	return &Key{
+		ID:        row.KeyID,
+		Algorithm: row.Algorithm,
+		Private:   signer,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}, nil
+}
+
+// Config controls key generation and rotation.
+type Config struct {
+	Algorithm     string        // Algorithm of newly generated keys
+	RotateEvery   time.Duration // Age at which the signing key is replaced
+	Retain        time.Duration // How long a rotated-out key keeps verifying tokens
+	Reload        time.Duration // How often keys created by other replicas are picked up
+	EncryptionKey []byte        // AES-256 key sealing private keys in the database
+}
+
+// ConfigFromEnv reads JWT_SIGNING_ALG (RS256 or EdDSA, default RS256),
+// JWT_KEY_ROTATION (a Go duration, default 720h) and the required
+// JWT_KEY_ENCRYPTION_KEY (32 bytes, base64 encoded). retain should cover the
+// lifetime of the tokens the keys sign.
+func ConfigFromEnv(retain time.Duration) (Config, error) {
+	cfg := Config{
+		Algorithm:   RS256,
+		RotateEvery: 30 * 24 * time.Hour,
+		Retain:      retain,
+		Reload:      time.Minute,
+	}
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		if alg != RS256 && alg != EdDSA {
+			return cfg, fmt.Errorf("%w: JWT_SIGNING_ALG=%q", ErrUnsupportedAlgorithm, alg)
+		}
+		cfg.Algorithm = alg
+	}
+	if v := os.Getenv("JWT_KEY_ROTATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid JWT_KEY_ROTATION %q", v)
+		}
+		cfg.RotateEvery = d
+	}
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("JWT_KEY_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return cfg, ErrEncryptionKey
+	}
+	cfg.EncryptionKey = key
+	return cfg, nil
+}
+
+// Manager keeps the set of signing keys in sync with the database. Several
+// replicas may share the same keys; each periodically reloads them, and a
+// token signed with a key it has not seen yet triggers an early reload.
+type Manager struct {
+	db     database.Service
+	cfg    Config
+	sealer *sealer
+
+	mu       sync.RWMutex
+	keys     []*Key // Newest first
+	loadedAt time.Time
+}
+
+// NewManager loads the stored keys, creating the first signing key if none
+// is active.
+func NewManager(db database.Service, cfg Config) (*Manager, error) {
+	s, err := newSealer(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{db: db, cfg: cfg, sealer: s}
+	now := time.Now().UTC()
+	if err := m.reload(now); err != nil {
+		return nil, err
+	}
+	if m.due(now) {
+		if _, err := m.rotate(now, now.Add(-cfg.RotateEvery)); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Manager) reload(now time.Time) error {
+	rows, err := m.db.ListSigningKeys(now)
+	if err != nil {
+		return fmt.Errorf("could not load signing keys: %w", err)
+	}
+
+	keys := make([]*Key, 0, len(rows))
+	for _, row := range rows {
+		k, err := decode(row, m.sealer)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", row.KeyID, err)
+			continue
+		}
+		if !isSealed(row.PrivateKey) {
+			m.seal(k)
+		}
+		keys = append(keys, k)
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.loadedAt = now
+	m.mu.Unlock()
+	return nil
+}
+
+// seal encrypts a key stored as plaintext before encryption was introduced.
+func (m *Manager) seal(k *Key) {
+	row, err := encode(k, m.sealer)
+	if err == nil {
+		err = m.db.UpdateSigningKey(k.ID, row.PrivateKey)
+	}
+	if err != nil {
+		log.Printf("Could not encrypt signing key %s: %v", k.ID, err)
+	}
+}
+
+// due reports whether the signing key is missing or old enough to be
+// replaced. A changed JWT_SIGNING_ALG takes effect at the next rotation.
+func (m *Manager) due(now time.Time) bool {
+	k, err := m.Current()
+	if err != nil {
+		return true
+	}
+	return now.Sub(k.CreatedAt) >= m.cfg.RotateEvery
+}
+
+// Current returns the key that signs new tokens.
+func (m *Manager) Current() (*Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.ExpiresAt == nil {
+			return k, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Lookup returns the key with the given ID, reloading from the database if
+// another replica may have created it since the last load.
+func (m *Manager) Lookup(kid string) (*Key, error) {
+	if k := m.find(kid); k != nil {
+		return k, nil
+	}
+
+	now := time.Now().UTC()
+	m.mu.RLock()
+	stale := now.Sub(m.loadedAt) >= reloadCooldown
+	m.mu.RUnlock()
+	if stale {
+		if err := m.reload(now); err != nil {
+			return nil, err
+		}
+		if k := m.find(kid); k != nil {
+			return k, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (m *Manager) find(kid string) *Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.ID == kid && (k.ExpiresAt == nil || k.ExpiresAt.After(now)) {
+			return k
+		}
+	}
+	return nil
+}
+
+// Rotate generates a new signing key and retires the current ones. Retired
+// keys keep verifying tokens for cfg.Retain.
+func (m *Manager) Rotate(now time.Time) (*Key, error) {
+	return m.rotate(now, now)
+}
+
+// rotate replaces the signing key unless another replica already installed
+// one created at or after replaceBefore, in which case that key is loaded and
+// returned instead.
+func (m *Manager) rotate(now, replaceBefore time.Time) (*Key, error) {
+	k, err := Generate(m.cfg.Algorithm, now)
+	if err != nil {
+		return nil, err
+	}
+	row, err := encode(k, m.sealer)
+	if err != nil {
+		return nil, err
+	}
+	err = m.db.RotateSigningKey(row, now.Add(m.cfg.Retain), replaceBefore)
+	if err != nil && !errors.Is(err, database.ErrSigningKeyFresh) {
+		return nil, fmt.Errorf("could not store signing key: %w", err)
+	}
+	if err == nil {
+		log.Printf("Rotated JWT signing key, new kid %s (%s)", k.ID, k.Algorithm)
+	}
+
+	if err := m.reload(now); err != nil {
+		return nil, err
+	}
+	return m.Current()
+}
+
+// Run reloads the keys every cfg.Reload and rotates the signing key once it
+// is due, until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Reload)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().UTC()
+		if err := m.reload(now); err != nil {
+			log.Printf("Signing key reload failed: %v", err)
+			continue
+		}
+		if m.due(now) {
+			if _, err := m.rotate(now, now.Add(-m.cfg.RotateEvery)); err != nil {
+				log.Printf("Signing key rotation failed: %v", err)
+			}
+		}
+	}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA public exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of every key that still verifies tokens.
+func (m *Manager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// sealedPrefix marks private keys encrypted with AES-256-GCM. Rows without it
+// hold the plaintext PEM written before keys were encrypted.
+const sealedPrefix = "v1:"
+
+var ErrEncryptionKey = errors.New("JWT_KEY_ENCRYPTION_KEY must be 32 bytes, base64 encoded")
+
+// sealer encrypts private keys at rest. The key ID is bound as additional
+// data, so a sealed key cannot be swapped onto another row.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != 32 {
+		return nil, ErrEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func (s *sealer) seal(kid string, der []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, der, []byte(kid))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *sealer) open(kid, stored string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil {
+		return nil, err
+	}
+	n := s.aead.NonceSize()
+	if len(raw) < n {
+		return nil, errors.New("sealed private key is truncated")
+	}
+	der, err := s.aead.Open(nil, raw[:n], raw[n:], []byte(kid))
+	if err != nil {
+		return nil, errors.New("private key cannot be decrypted with JWT_KEY_ENCRYPTION_KEY")
+	}
+	return der, nil
+}
+
+func isSealed(stored string) bool {
+	return strings.HasPrefix(stored, sealedPrefix)
+}
@@ -0,0 +1,146 @@
+// Package media validates uploaded images and renders their thumbnails
+// using only the standard library image codecs.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// Upload limits.
+const (
+	MaxUploadSize  = 10 << 20   // Largest accepted file in bytes
+	MaxPixels      = 40_000_000 // Largest accepted image area, guards against decompression bombs
+	ThumbnailWidth = 400        // Thumbnails are scaled down to at most this width
+)
+
+// Validation errors.
+var (
+	ErrTooLarge        = errors.New("image must be at most 10 MB")
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+	ErrCorrupt         = errors.New("image could not be decoded")
+)
+
+// formats maps the sniffed content type to its file extension.
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is a validated upload together with its thumbnail.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExt         string
+}
+
+// Process checks an uploaded file's size, type and dimensions and renders a
+// thumbnail. The type is sniffed from the content, not the file name.
+func Process(data []byte) (*Image, error) {
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	img := &Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}
+
+	thumb := Resize(src, ThumbnailWidth)
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		img.ThumbnailContentType, img.ThumbnailExt = "image/jpeg", ".jpg"
+	} else {
+		// PNG keeps the transparency of PNG and GIF sources.
+		err = png.Encode(&buf, thumb)
+		img.ThumbnailContentType, img.ThumbnailExt = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Thumbnail = buf.Bytes()
+
+	return img, nil
+}
+
+// Resize scales src down to maxWidth, keeping its aspect ratio. Each output
+// pixel averages the block of source pixels it covers, which avoids the
+// aliasing of nearest-neighbour sampling. Images already narrow enough are
+// copied unchanged.
+func Resize(src image.Image, maxWidth int) *image.NRGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > maxWidth {
+		dw = maxWidth
+		dh = sh * maxWidth / sw
+		if dh < 1 {
+			dh = 1
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := b.Min.Y + y*sh/dh
+		y1 := b.Min.Y + (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := b.Min.X + x*sw/dw
+			x1 := b.Min.X + (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// Averaged premultiplied channels are converted back to straight alpha.
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -1,30 +1,47 @@
 package middleware
 
 import (
-	"os"
+	"errors"
+	"ticketing/internal/apperr"
+	"ticketing/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/golang-jwt/jwt/v4"
 )
 
 // JWTProtected defines the JWT middleware configuration
 func JWTProtected() fiber.Handler {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		panic("JWT_SECRET is not set in environment variables")
-	}
-
 	return jwtware.New(jwtware.Config{
-		SigningKey:   []byte(secretKey),
-		ContextKey:   "jwt",           // Store token payload in this context key
-		ErrorHandler: jwtErrorHandler, // Custom error handler
+		KeyFunc:        utils.Keyfunc,     // Resolve the signing key from the kid header
+		ContextKey:     "jwt",             // Store token payload in this context key
+		SuccessHandler: checkAccessClaims, // Expiry, issuer, audience and revocation
+		ErrorHandler:   jwtErrorHandler,   // Custom error handler
 	})
 }
 
-// jwtErrorHandler handles JWT authentication errors
+// checkAccessClaims rejects signed tokens that are expired, minted for
+// another service or revoked on logout.
+func checkAccessClaims(c *fiber.Ctx) error {
+	token, ok := c.Locals("jwt").(*jwt.Token)
+	if !ok {
+		return jwtErrorHandler(c, errors.New("missing token"))
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return jwtErrorHandler(c, errors.New("invalid token claims"))
+	}
+	if err := utils.ValidateAccessClaims(claims); err != nil {
+		return jwtErrorHandler(c, err)
+	}
+	return c.Next()
+}
+
+// jwtErrorHandler handles JWT authentication errors. Every failure is a 401;
+// revoked tokens get their own code so clients know not to retry them.
 func jwtErrorHandler(c *fiber.Ctx, err error) error {
-	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-		"error": true,
-		"msg":   "Unauthorized: " + err.Error(),
-	})
+	if errors.Is(err, utils.ErrTokenRevoked) {
+		return apperr.From(err)
+	}
+	return apperr.Unauthorized("Unauthorized: " + err.Error())
 }
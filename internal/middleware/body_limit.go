@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"fmt"
+	"ticketing/internal/apperr"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than limit. The server's own body
+// limit has to admit the largest upload, so this holds every other route to
+// limit; requests matched by exempt are left to enforce their own limit.
+func BodyLimit(limit int, exempt func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if exempt != nil && exempt(c) {
+			return c.Next()
+		}
+		if c.Request().Header.ContentLength() > limit || len(c.Request().Body()) > limit {
+			return apperr.New(fiber.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge,
+				fmt.Sprintf("Request body must be at most %d bytes", limit))
+		}
+		return c.Next()
+	}
+}
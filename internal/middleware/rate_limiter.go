@@ -3,6 +3,8 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"ticketing/internal/apperr"
 	"ticketing/internal/utils"
 	"time"
 
@@ -19,28 +21,23 @@ func RateLimitMiddleware(limit int, window time.Duration) fiber.Handler {
 		// Increment request count
 		count, err := utils.Rdb.Incr(context.Background(), redisKey).Result()
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Internal server error",
-			})
+			return apperr.Internal("Could not check rate limit", err)
 		}
 
 		// Set TTL on the key if it's the first request
 		if count == 1 {
 			_, err := utils.Rdb.Expire(context.Background(), redisKey, window).Result()
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Internal server error",
-				})
+				return apperr.Internal("Could not check rate limit", err)
 			}
 		}
 
 		// Check if the request count exceeds the limit
 		if count > int64(limit) {
 			remainingTTL, _ := utils.Rdb.TTL(context.Background(), redisKey).Result()
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"message":    "Rate limit exceeded",
-				"retryAfter": remainingTTL.Seconds(),
-			})
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(remainingTTL.Seconds())))
+			return apperr.New(fiber.StatusTooManyRequests, apperr.CodeRateLimited, "Rate limit exceeded").
+				With("retry_after", remainingTTL.Seconds())
 		}
 
 		// Proceed to the next handler
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"ticketing/internal/access"
+	"ticketing/internal/apperr"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// subject reads the caller from the API key or the token JWTProtected
+// stored in the context.
+func subject(c *fiber.Ctx) (access.Subject, bool) {
+	if s, ok := c.Locals(SubjectKey).(access.Subject); ok {
+		return s, true
+	}
+	token, ok := c.Locals("jwt").(*jwt.Token)
+	if !ok {
+		return access.Subject{}, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return access.Subject{}, false
+	}
+	s, err := access.SubjectFromClaims(claims)
+	return s, err == nil
+}
+
+// RequireRole only lets callers with one of the given platform roles through.
+// It must run after JWTProtected.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		s, ok := subject(c)
+		if !ok {
+			return jwtErrorHandler(c, jwt.ErrTokenMalformed)
+		}
+		for _, role := range roles {
+			if s.Role == role {
+				return c.Next()
+			}
+		}
+		return forbidden(c, s)
+	}
+}
+
+// RequirePermission only lets callers whose platform role grants perm
+// through. It must run after JWTProtected.
+func RequirePermission(perm access.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		s, ok := subject(c)
+		if !ok {
+			return jwtErrorHandler(c, jwt.ErrTokenMalformed)
+		}
+		if !access.RoleCan(s.Role, perm) {
+			return forbidden(c, s)
+		}
+		return c.Next()
+	}
+}
+
+// RequireVerifiedEmail only lets callers who confirmed their email address
+// through. It must run after JWTProtected.
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		s, ok := subject(c)
+		if !ok {
+			return jwtErrorHandler(c, jwt.ErrTokenMalformed)
+		}
+		if !s.EmailVerified {
+			return apperr.Forbidden("email_not_verified", "Forbidden: verify your email address first")
+		}
+		return c.Next()
+	}
+}
+
+// forbidden answers requests the caller's role does not allow.
+func forbidden(c *fiber.Ctx, s access.Subject) error {
+	if s.MFAPending {
+		return apperr.Forbidden("mfa_required", "Forbidden: enable two-factor authentication to use your role")
+	}
+	return apperr.Forbidden("role_forbidden", "Forbidden: your role does not allow this action")
+}
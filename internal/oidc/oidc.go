@@ -0,0 +1,359 @@
+// Package oidc signs users in with an external OpenID Connect provider using
+// the authorization code flow with PKCE. Any provider that publishes a
+// discovery document works; handlers depend on the IdentityProvider
+// interface so others can be plugged in.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Identity is the user an identity provider vouched for.
+type Identity struct {
+	Issuer        string // Identifies the provider
+	Subject       string // Stable user ID at the provider
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// IdentityProvider runs the authorization code flow with an external
+// provider.
+type IdentityProvider interface {
+	// AuthCodeURL is where the browser is sent to sign in.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems an authorization code and returns the verified
+	// identity. nonce and verifier must be the values passed to AuthCodeURL.
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// Errors returned while completing a login.
+var (
+	ErrExchangeFailed = errors.New("identity provider rejected the authorization code")
+	ErrInvalidIDToken = errors.New("identity provider returned an invalid ID token")
+)
+
+// Config identifies this client to the provider.
+type Config struct {
+	Issuer       string   // Base URL the discovery document is served under
+	ClientID     string   // Client ID registered with the provider
+	ClientSecret string   // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string   // Callback registered with the provider
+	Scopes       []string // Must include openid and email
+}
+
+// ConfigFromEnv reads OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET,
+// OIDC_REDIRECT_URL and OIDC_SCOPES (space separated, default
+// "openid email profile"). It reports false when OIDC_ISSUER is unset.
+func ConfigFromEnv() (Config, bool) {
+	cfg := Config{
+		Issuer:       strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return cfg, cfg.Issuer != ""
+}
+
+// FromEnv returns the provider configured by the environment, or nil when
+// single sign-on is not configured. The discovery document is loaded right
+// away, but a provider that cannot be reached does not stop the server: the
+// failure is logged and discovery is retried on the next login.
+func FromEnv(ctx context.Context) (IdentityProvider, error) {
+	cfg, ok := ConfigFromEnv()
+	if !ok {
+		return nil, nil
+	}
+	p, err := NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.discover(ctx); err != nil {
+		log.Printf("OIDC provider unavailable, retrying on the next login: %v", err)
+	}
+	return p, nil
+}
+
+// discovery is the part of the provider's metadata this client uses.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a standards-compliant OpenID Connect provider.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *discovery // Nil until discovery succeeds
+	keys *keySet
+}
+
+// NewProvider checks the configuration. The discovery document is loaded on
+// first use.
+func NewProvider(cfg Config) (*Provider, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC client ID and redirect URL are required")
+	}
+	return &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// discover returns the provider's metadata, fetching the discovery document
+// if no earlier call succeeded.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta discovery
+	if err := getJSON(ctx, p.client, p.cfg.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("could not load OIDC discovery document: %w", err)
+	}
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+
+	p.meta = &meta
+	p.keys = &keySet{uri: meta.JWKSURI, client: p.client}
+	return p.meta, nil
+}
+
+// Challenge returns the S256 PKCE code challenge for a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL implements IdentityProvider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// tokenResponse is the provider's answer to the code exchange.
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// Exchange implements IdentityProvider.
+func (p *Provider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.cfg.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tokens tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("could not read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", ErrExchangeFailed, tokens.Error)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrInvalidIDToken)
+	}
+
+	return p.verify(ctx, meta, tokens.IDToken, nonce)
+}
+
+// signingMethods are the ID token algorithms accepted. Symmetric and "none"
+// algorithms are never accepted.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// idClaims are the ID token claims this client reads.
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Some providers send "true"
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+}
+
+// verify checks the ID token's signature against the provider's keys and
+// its issuer, audience, expiry and nonce.
+func (p *Provider) verify(ctx context.Context, meta *discovery, raw, nonce string) (*Identity, error) {
+	var claims idClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.lookup(ctx, kid, t.Method.Alg())
+	}, jwt.WithValidMethods(signingMethods))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if !claims.VerifyIssuer(meta.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidIDToken)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: no expiry", ErrInvalidIDToken)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	return &Identity{
+		Issuer:        meta.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}, nil
+}
+
+// keySet caches the provider's signing keys, refetching them when a token
+// names a key it has not seen.
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]jwk
+	fetchedAt time.Time
+}
+
+// refetchCooldown limits how often unknown kids trigger a refetch.
+const refetchCooldown = 30 * time.Second
+
+func (s *keySet) lookup(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.find(kid)
+	if !ok && time.Since(s.fetchedAt) >= refetchCooldown {
+		if err := s.fetch(ctx); err != nil {
+			return nil, err
+		}
+		k, ok = s.find(kid)
+	}
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if k.Alg != "" && k.Alg != alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.publicKey(alg)
+}
+
+// find returns the key with the given kid, or the only key if the token
+// names none.
+func (s *keySet) find(kid string) (jwk, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+func (s *keySet) fetch(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, s.client, s.uri, &set); err != nil {
+		return fmt.Errorf("could not load OIDC signing keys: %w", err)
+	}
+	s.keys = make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "" || k.Use == "sig" {
+			s.keys[k.Kid] = k
+		}
+	}
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, uri string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", uri, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
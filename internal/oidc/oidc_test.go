@@ -0,0 +1,377 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID = "ticketing"
+	testNonce    = "nonce-1"
+	testVerifier = "verifier-1"
+)
+
+// testProvider is an identity provider serving discovery, JWKS and token
+// endpoints. The token endpoint returns whatever idToken is set to.
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PrivateKey // Published in the JWKS by kid
+	idToken     string
+	discoveryUp bool
+	issuer      string // Issuer claimed by discovery, the server URL if empty
+	jwksFetches int
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	tp := &testProvider{t: t, keys: map[string]*rsa.PrivateKey{}, discoveryUp: true}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", tp.discovery)
+	mux.HandleFunc("/jwks", tp.jwks)
+	mux.HandleFunc("/token", tp.token)
+	tp.server = httptest.NewServer(mux)
+	t.Cleanup(tp.server.Close)
+	tp.addKey("k1")
+	return tp
+}
+
+func (tp *testProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	tp.mu.Lock()
+	up, issuer := tp.discoveryUp, tp.issuer
+	tp.mu.Unlock()
+	if !up {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	if issuer == "" {
+		issuer = tp.server.URL
+	}
+	json.NewEncoder(w).Encode(discovery{
+		Issuer:                issuer,
+		AuthorizationEndpoint: tp.server.URL + "/authorize",
+		TokenEndpoint:         tp.server.URL + "/token",
+		JWKSURI:               tp.server.URL + "/jwks",
+	})
+}
+
+func (tp *testProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.jwksFetches++
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	for kid, key := range tp.keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func (tp *testProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tp.t.Errorf("token request: %v", err)
+	}
+	if r.PostForm.Get("code") != "good-code" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if got := r.PostForm.Get("code_verifier"); got != testVerifier {
+		tp.t.Errorf("code_verifier = %q, want %q", got, testVerifier)
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{"id_token": tp.idToken})
+}
+
+func (tp *testProvider) addKey(kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	tp.mu.Lock()
+	tp.keys[kid] = key
+	tp.mu.Unlock()
+	return key
+}
+
+// claims returns valid ID token claims for the test client.
+func (tp *testProvider) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            tp.server.URL,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          testNonce,
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+	}
+}
+
+// issue makes the token endpoint return claims signed with the key kid.
+func (tp *testProvider) issue(kid string, claims jwt.MapClaims) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(tp.keys[kid])
+	if err != nil {
+		tp.t.Fatal(err)
+	}
+	tp.idToken = signed
+}
+
+func (tp *testProvider) client(t *testing.T) *Provider {
+	p, err := NewProvider(Config{
+		Issuer:      tp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "https://tickets.example.com/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExchange(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.client(t)
+
+	tests := []struct {
+		name  string
+		edit  func(jwt.MapClaims)
+		nonce string
+		want  string // Empty when the token is accepted
+	}{
+		{name: "valid"},
+		{name: "bad nonce", nonce: "nonce-2", want: "nonce mismatch"},
+		{name: "missing nonce", edit: func(c jwt.MapClaims) { delete(c, "nonce") }, want: "nonce mismatch"},
+		{name: "wrong audience", edit: func(c jwt.MapClaims) { c["aud"] = "other-client" }, want: "not issued for this client"},
+		{name: "audience list", edit: func(c jwt.MapClaims) { c["aud"] = []string{"other-client", testClientID} }},
+		{name: "wrong issuer", edit: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, want: "unexpected issuer"},
+		{name: "expired", edit: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, want: "expired"},
+		{name: "no expiry", edit: func(c jwt.MapClaims) { delete(c, "exp") }, want: "no expiry"},
+		{name: "no subject", edit: func(c jwt.MapClaims) { delete(c, "sub") }, want: "no subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := tp.claims()
+			if tt.edit != nil {
+				tt.edit(claims)
+			}
+			tp.issue("k1", claims)
+			nonce := testNonce
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			identity, err := p.Exchange(context.Background(), "good-code", nonce, testVerifier)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Exchange: %v", err)
+				}
+				if identity.Issuer != tp.server.URL || identity.Subject != "user-1" || identity.Email != "ada@example.com" ||
+					!identity.EmailVerified || identity.GivenName != "Ada" {
+					t.Errorf("identity = %+v", identity)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Exchange error = %v, want ErrInvalidIDToken mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsForgedTokens(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.client(t)
+
+	// Signed with a key the provider never published under that kid
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tp.claims())
+	token.Header["kid"] = "k1"
+	forged, err := token.SignedString(forger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Symmetric tokens are refused even when keyed with public material
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, tp.claims())
+	hmac.Header["kid"] = "k1"
+	symmetric, err := hmac.SignedString(tp.keys["k1"].N.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, tp.claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, raw := range map[string]string{"wrong key": forged, "HS256": symmetric, "none": unsigned} {
+		tp.mu.Lock()
+		tp.idToken = raw
+		tp.mu.Unlock()
+		if _, err := p.Exchange(context.Background(), "good-code", testNonce, testVerifier); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidIDToken", name, err)
+		}
+	}
+
+	if _, err := p.Exchange(context.Background(), "bad-code", testNonce, testVerifier); !errors.Is(err, ErrExchangeFailed) {
+		t.Errorf("rejected code: err = %v, want ErrExchangeFailed", err)
+	}
+}
+
+func TestExchangeEmailVerified(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.client(t)
+
+	tests := []struct {
+		name  string
+		value interface{} // Nil leaves the claim out
+		want  bool
+	}{
+		{"true", true, true},
+		{"string true", "true", true},
+		{"false", false, false},
+		{"string false", "false", false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := tp.claims()
+			if tt.value == nil {
+				delete(claims, "email_verified")
+			} else {
+				claims["email_verified"] = tt.value
+			}
+			tp.issue("k1", claims)
+
+			identity, err := p.Exchange(context.Background(), "good-code", testNonce, testVerifier)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	tp := newTestProvider(t)
+	p := tp.client(t)
+	ctx := context.Background()
+
+	tp.issue("k1", tp.claims())
+	if _, err := p.Exchange(ctx, "good-code", testNonce, testVerifier); err != nil {
+		t.Fatalf("Exchange with k1: %v", err)
+	}
+
+	// The provider rotates to k2 and drops k1
+	tp.addKey("k2")
+	tp.mu.Lock()
+	delete(tp.keys, "k1")
+	tp.mu.Unlock()
+	tp.issue("k2", tp.claims())
+
+	// A new kid right after a fetch waits out the cooldown
+	if _, err := p.Exchange(ctx, "good-code", testNonce, testVerifier); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Exchange inside the cooldown: err = %v, want ErrInvalidIDToken", err)
+	}
+	if tp.jwksFetches != 1 {
+		t.Errorf("JWKS fetched %d times inside the cooldown, want 1", tp.jwksFetches)
+	}
+
+	p.keys.mu.Lock()
+	p.keys.fetchedAt = time.Now().Add(-refetchCooldown)
+	p.keys.mu.Unlock()
+	if _, err := p.Exchange(ctx, "good-code", testNonce, testVerifier); err != nil {
+		t.Fatalf("Exchange with k2 after the cooldown: %v", err)
+	}
+	if tp.jwksFetches != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", tp.jwksFetches)
+	}
+
+	// Known kids are served from the cache
+	if _, err := p.Exchange(ctx, "good-code", testNonce, testVerifier); err != nil {
+		t.Fatalf("Exchange with cached k2: %v", err)
+	}
+	if tp.jwksFetches != 2 {
+		t.Errorf("JWKS refetched for a known kid")
+	}
+}
+
+func TestDiscoveryRetried(t *testing.T) {
+	tp := newTestProvider(t)
+	tp.discoveryUp = false
+	p := tp.client(t)
+	ctx := context.Background()
+
+	if _, err := p.AuthCodeURL(ctx, "state-1", testNonce, testVerifier); err == nil {
+		t.Fatal("AuthCodeURL succeeded without a discovery document")
+	}
+	if _, err := p.Exchange(ctx, "good-code", testNonce, testVerifier); err == nil ||
+		errors.Is(err, ErrInvalidIDToken) || errors.Is(err, ErrExchangeFailed) {
+		t.Fatalf("Exchange without a discovery document: err = %v, want an unavailable error", err)
+	}
+
+	tp.mu.Lock()
+	tp.discoveryUp = true
+	tp.mu.Unlock()
+	target, err := p.AuthCodeURL(ctx, "state-1", testNonce, testVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL after the provider recovered: %v", err)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("state") != "state-1" ||
+		q.Get("nonce") != testNonce || q.Get("code_challenge") != Challenge(testVerifier) ||
+		q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid email" {
+		t.Errorf("AuthCodeURL = %s", target)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	tp := newTestProvider(t)
+	tp.issuer = "https://evil.example.com"
+	p := tp.client(t)
+	if _, err := p.AuthCodeURL(context.Background(), "state-1", testNonce, testVerifier); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("err = %v, want an issuer mismatch", err)
+	}
+}
@@ -1,7 +1,6 @@
 package queue
 
 import (
-	"encoding/json"
 	"log"
 	"ticketing/internal/database"
 )
@@ -23,16 +22,23 @@ func (s *Service) ProcessQueue(db database.Service) {
 
 	go func() {
 		for d := range msgs {
-			var req database.TicketBookingReq
-			if err := json.Unmarshal(d.Body, &req); err != nil {
+			env, err := DecodeEnvelope(d.Body)
+			if err != nil {
 				log.Printf("Failed to unmarshal message: %v", err)
 				continue
 			}
 
+			req, err := DecodeBooking(env)
+			if err != nil {
+				log.Printf("Failed to decode message %s: %v", env.MessageID, err)
+				continue
+			}
+
 			// Process the booking request
 			ticket := &database.Ticket{
 				TicketID: req.TicketID,
 				EventID:  req.EventID,
+				UserID:   req.UserID,
 				Email:    req.Email,
 				Quantity: req.Quantity,
 			}
@@ -42,7 +48,7 @@ func (s *Service) ProcessQueue(db database.Service) {
 				continue
 			}
 
-			log.Printf("Ticket booked successfully: %+v", ticket)
+			log.Printf("Ticket booked successfully (correlation_id=%s): %+v", env.CorrelationID, ticket)
 		}
 	}()
 }
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticketing/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message types carried on the booking queue.
+const (
+	TypeTicketBooking = "ticket.booking.requested"
+)
+
+// CurrentBookingVersion is the schema version written by publishers.
+// Version 0 is the legacy bare TicketBookingReq body published before
+// envelopes existed.
+const CurrentBookingVersion = 1
+
+// Envelope wraps every payload published to RabbitMQ so that consumers can
+// route on type, decode by schema version and trace a request end to end.
+type Envelope struct {
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	MessageID     string          `json:"message_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+var (
+	ErrUnknownMessageType = errors.New("unknown message type")
+	ErrUnsupportedVersion = errors.New("unsupported message version")
+)
+
+// bookingDecoders maps a schema version to the function that turns its
+// payload into the current TicketBookingReq shape.
+var bookingDecoders = map[int]func(json.RawMessage) (database.TicketBookingReq, error){
+	0: decodeBookingV1, // legacy bodies share the v1 field layout
+	1: decodeBookingV1,
+}
+
+// NewBookingEnvelope builds a current-version envelope around a booking request.
+func NewBookingEnvelope(req database.TicketBookingReq, correlationID string) (Envelope, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Type:          TypeTicketBooking,
+		Version:       CurrentBookingVersion,
+		MessageID:     uuid.New().String(),
+		CreatedAt:     time.Now().UTC(),
+		CorrelationID: correlationID,
+		Payload:       payload,
+	}, nil
+}
+
+// DecodeEnvelope parses a raw message body. Bodies without a type are
+// treated as legacy version 0 booking requests.
+func DecodeEnvelope(body []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return Envelope{}, err
+	}
+
+	if env.Type == "" {
+		return Envelope{
+			Type:    TypeTicketBooking,
+			Version: 0,
+			Payload: json.RawMessage(body),
+		}, nil
+	}
+
+	return env, nil
+}
+
+// DecodeBooking extracts the booking request from an envelope using the
+// decoder registered for its version.
+func DecodeBooking(env Envelope) (database.TicketBookingReq, error) {
+	if env.Type != TypeTicketBooking {
+		return database.TicketBookingReq{}, fmt.Errorf("%w: %q", ErrUnknownMessageType, env.Type)
+	}
+
+	decode, ok := bookingDecoders[env.Version]
+	if !ok {
+		return database.TicketBookingReq{}, fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, env.Type, env.Version)
+	}
+
+	return decode(env.Payload)
+}
+
+func decodeBookingV1(payload json.RawMessage) (database.TicketBookingReq, error) {
+	var req database.TicketBookingReq
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return database.TicketBookingReq{}, err
+	}
+	return req, nil
+}
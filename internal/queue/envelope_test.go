@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"ticketing/internal/database"
+)
+
+func TestDecodeBooking(t *testing.T) {
+	current, err := NewBookingEnvelope(database.TicketBookingReq{
+		TicketID: "t-1",
+		Email:    "ada@example.com",
+		UserID:   "u-1",
+		EventID:  "e-1",
+		Quantity: 2,
+	}, "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentBody, err := json.Marshal(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		version int
+		want    database.TicketBookingReq
+	}{
+		{
+			name:    "current envelope",
+			body:    string(currentBody),
+			version: CurrentBookingVersion,
+			want:    database.TicketBookingReq{TicketID: "t-1", Email: "ada@example.com", UserID: "u-1", EventID: "e-1", Quantity: 2},
+		},
+		{
+			name:    "bare v0 payload",
+			body:    `{"ticket_id":"t-2","email":"bob@example.com","user_id":"u-2","event_id":"e-2","quantity":1}`,
+			version: 0,
+			want:    database.TicketBookingReq{TicketID: "t-2", Email: "bob@example.com", UserID: "u-2", EventID: "e-2", Quantity: 1},
+		},
+		{
+			// Published before bookings carried the account
+			name:    "bare v0 payload without user_id",
+			body:    `{"ticket_id":"t-3","email":"cy@example.com","event_id":"e-3","quantity":4}`,
+			version: 0,
+			want:    database.TicketBookingReq{TicketID: "t-3", Email: "cy@example.com", EventID: "e-3", Quantity: 4},
+		},
+		{
+			name:    "v1 envelope without user_id",
+			body:    `{"type":"ticket.booking.requested","version":1,"message_id":"m-4","payload":{"ticket_id":"t-4","email":"di@example.com","event_id":"e-4","quantity":3}}`,
+			version: 1,
+			want:    database.TicketBookingReq{TicketID: "t-4", Email: "di@example.com", EventID: "e-4", Quantity: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := DecodeEnvelope([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("DecodeEnvelope: %v", err)
+			}
+			if env.Type != TypeTicketBooking || env.Version != tt.version {
+				t.Fatalf("envelope = %s v%d, want %s v%d", env.Type, env.Version, TypeTicketBooking, tt.version)
+			}
+			got, err := DecodeBooking(env)
+			if err != nil {
+				t.Fatalf("DecodeBooking: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeBooking = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBookingRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		env  Envelope
+		want error
+	}{
+		{"unknown type", Envelope{Type: "ticket.refund.requested", Version: 1}, ErrUnknownMessageType},
+		{"future version", Envelope{Type: TypeTicketBooking, Version: CurrentBookingVersion + 1}, ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeBooking(tt.env); !errors.Is(err, tt.want) {
+				t.Errorf("DecodeBooking error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -42,9 +42,14 @@ func NewQueueService() (*Service, error) {
 	return &Service{conn: conn, channel: ch, queue: q}, nil
 }
 
-// PublishTicketRequest enqueues a ticket booking request
-func (s *Service) PublishTicketRequest(req database.TicketBookingReq) error {
-	body, err := json.Marshal(req)
+// PublishTicketRequest wraps a ticket booking request in an envelope and enqueues it
+func (s *Service) PublishTicketRequest(req database.TicketBookingReq, correlationID string) error {
+	env, err := NewBookingEnvelope(req, correlationID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(env)
 	if err != nil {
 		return err
 	}
@@ -55,8 +60,12 @@ func (s *Service) PublishTicketRequest(req database.TicketBookingReq) error {
 		false,
 		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:   "application/json",
+			Type:          env.Type,
+			MessageId:     env.MessageID,
+			CorrelationId: env.CorrelationID,
+			Timestamp:     env.CreatedAt,
+			Body:          body,
 		},
 	)
 	if err != nil {
@@ -0,0 +1,230 @@
+// Package recurrence expands RRULE-style recurrence rules into concrete
+// occurrence start times.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported frequencies.
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// MaxOccurrences caps how many occurrences a single series may materialize.
+const MaxOccurrences = 366
+
+// DefaultHorizon bounds open-ended rules that have neither COUNT nor UNTIL.
+const DefaultHorizon = 365 * 24 * time.Hour
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Rule is the subset of RFC 5545 RRULE supported by the platform:
+// FREQ, INTERVAL, BYDAY (weekly only), COUNT and UNTIL.
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    *time.Time
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10".
+// An optional "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRule)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			t, err := parseUntil(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ", ErrInvalidRule)
+			}
+			rule.Until = &t
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := weekdays[strings.ToUpper(d)]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown BYDAY value %q", ErrInvalidRule, d)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %q", ErrInvalidRule, key)
+		}
+	}
+
+	switch rule.Freq {
+	case Daily, Monthly:
+		if len(rule.ByDay) > 0 {
+			return nil, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRule)
+		}
+	case Weekly:
+	default:
+		return nil, fmt.Errorf("%w: FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+
+	return rule, nil
+}
+
+func parseUntil(v string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// A date-only UNTIL includes the whole day.
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// Expand returns the sorted occurrence start times for a series beginning at
+// dtstart. Occurrences keep dtstart's wall-clock time in loc across DST
+// changes. Extra dates in rdates are added and any start in exdates is removed.
+// A nil rule yields only dtstart and rdates.
+func Expand(rule *Rule, dtstart time.Time, loc *time.Location, rdates, exdates []time.Time) []time.Time {
+	start := dtstart.In(loc)
+	var out []time.Time
+	if rule == nil {
+		out = append(out, start)
+	} else {
+		out = expandRule(rule, start, loc)
+	}
+
+	for _, d := range rdates {
+		out = append(out, d.In(loc))
+	}
+
+	excluded := make(map[int64]bool, len(exdates))
+	for _, d := range exdates {
+		excluded[d.Unix()] = true
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+
+	seen := make(map[int64]bool, len(out))
+	result := out[:0]
+	for _, t := range out {
+		if excluded[t.Unix()] || seen[t.Unix()] {
+			continue
+		}
+		seen[t.Unix()] = true
+		result = append(result, t)
+		if len(result) == MaxOccurrences {
+			break
+		}
+	}
+	return result
+}
+
+func expandRule(rule *Rule, start time.Time, loc *time.Location) []time.Time {
+	until := start.Add(DefaultHorizon)
+	if rule.Until != nil {
+		until = *rule.Until
+	}
+	limit := MaxOccurrences
+	if rule.Count > 0 && rule.Count < limit {
+		limit = rule.Count
+	}
+
+	h, m, sec := start.Clock()
+	at := func(y int, mo time.Month, d int) time.Time {
+		return time.Date(y, mo, d, h, m, sec, 0, loc)
+	}
+
+	var out []time.Time
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if t.After(until) || len(out) == limit {
+			return false
+		}
+		out = append(out, t)
+		return true
+	}
+
+	y, mo, d := start.Date()
+	switch rule.Freq {
+	case Daily:
+		for i := 0; ; i += rule.Interval {
+			if !emit(at(y, mo, d+i)) {
+				return out
+			}
+		}
+	case Weekly:
+		days := rule.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// Weeks start on Monday, as in the RFC 5545 default WKST.
+		offsets := make([]int, 0, len(days))
+		for _, wd := range days {
+			offsets = append(offsets, (int(wd)+6)%7)
+		}
+		sort.Ints(offsets)
+		weekStart := d - (int(start.Weekday())+6)%7
+		for w := 0; ; w += 7 * rule.Interval {
+			for _, off := range offsets {
+				if !emit(at(y, mo, weekStart+w+off)) {
+					return out
+				}
+			}
+		}
+	case Monthly:
+		for i := 0; ; i += rule.Interval {
+			t := at(y, mo+time.Month(i), d)
+			if t.Day() != d {
+				// The month is too short for this day; RFC 5545 skips it.
+				if t.After(until) {
+					return out
+				}
+				continue
+			}
+			if !emit(t) {
+				return out
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,264 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// format renders occurrences as local wall-clock times for comparison.
+func format(times []time.Time) []string {
+	out := make([]string, len(times))
+	for i, t := range times {
+		out[i] = t.Format("2006-01-02 Mon 15:04 MST")
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExpand(t *testing.T) {
+	utc := time.UTC
+	berlin := mustLoad(t, "Europe/Berlin")
+	newYork := mustLoad(t, "America/New_York")
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		loc     *time.Location
+		want    []string
+	}{
+		{
+			name:    "daily count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2030, 1, 30, 9, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-30 Wed 09:00 UTC", "2030-01-31 Thu 09:00 UTC", "2030-02-01 Fri 09:00 UTC"},
+		},
+		{
+			name:    "daily interval until date",
+			rule:    "FREQ=DAILY;INTERVAL=2;UNTIL=20300107",
+			dtstart: time.Date(2030, 1, 1, 18, 30, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-01 Tue 18:30 UTC", "2030-01-03 Thu 18:30 UTC", "2030-01-05 Sat 18:30 UTC", "2030-01-07 Mon 18:30 UTC"},
+		},
+		{
+			name:    "until timestamp excludes later start",
+			rule:    "FREQ=DAILY;UNTIL=20300103T090000Z",
+			dtstart: time.Date(2030, 1, 1, 10, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-01 Tue 10:00 UTC", "2030-01-02 Wed 10:00 UTC"},
+		},
+		{
+			name:    "weekly defaults to dtstart weekday",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2030, 1, 4, 20, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-04 Fri 20:00 UTC", "2030-01-11 Fri 20:00 UTC", "2030-01-18 Fri 20:00 UTC"},
+		},
+		{
+			name:    "weekly byday skips days before dtstart",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5",
+			dtstart: time.Date(2030, 1, 2, 7, 0, 0, 0, utc),
+			loc:     utc,
+			want: []string{
+				"2030-01-02 Wed 07:00 UTC", "2030-01-04 Fri 07:00 UTC", "2030-01-07 Mon 07:00 UTC",
+				"2030-01-09 Wed 07:00 UTC", "2030-01-11 Fri 07:00 UTC",
+			},
+		},
+		{
+			name:    "weekly interval byday",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=4",
+			dtstart: time.Date(2030, 1, 1, 12, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-01 Tue 12:00 UTC", "2030-01-03 Thu 12:00 UTC", "2030-01-15 Tue 12:00 UTC", "2030-01-17 Thu 12:00 UTC"},
+		},
+		{
+			name:    "byday order does not matter",
+			rule:    "FREQ=WEEKLY;BYDAY=SU,MO;COUNT=3",
+			dtstart: time.Date(2030, 1, 6, 10, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-06 Sun 10:00 UTC", "2030-01-07 Mon 10:00 UTC", "2030-01-13 Sun 10:00 UTC"},
+		},
+		{
+			name:    "monthly skips short months",
+			rule:    "FREQ=MONTHLY;COUNT=4",
+			dtstart: time.Date(2030, 1, 31, 19, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-01-31 Thu 19:00 UTC", "2030-03-31 Sun 19:00 UTC", "2030-05-31 Fri 19:00 UTC", "2030-07-31 Wed 19:00 UTC"},
+		},
+		{
+			name:    "monthly interval",
+			rule:    "FREQ=MONTHLY;INTERVAL=3;UNTIL=20301231",
+			dtstart: time.Date(2030, 2, 15, 9, 0, 0, 0, utc),
+			loc:     utc,
+			want:    []string{"2030-02-15 Fri 09:00 UTC", "2030-05-15 Wed 09:00 UTC", "2030-08-15 Thu 09:00 UTC", "2030-11-15 Fri 09:00 UTC"},
+		},
+		{
+			name:    "wall clock kept across spring forward",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2030, 3, 24, 19, 0, 0, 0, berlin),
+			loc:     berlin,
+			want:    []string{"2030-03-24 Sun 19:00 CET", "2030-03-31 Sun 19:00 CEST", "2030-04-07 Sun 19:00 CEST"},
+		},
+		{
+			name:    "wall clock kept across fall back",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2030, 11, 2, 8, 0, 0, 0, newYork),
+			loc:     newYork,
+			want:    []string{"2030-11-02 Sat 08:00 EDT", "2030-11-03 Sun 08:00 EST", "2030-11-04 Mon 08:00 EST"},
+		},
+		{
+			name:    "dtstart given in UTC is expanded in the series zone",
+			rule:    "FREQ=DAILY;COUNT=2",
+			dtstart: time.Date(2030, 3, 30, 18, 0, 0, 0, utc),
+			loc:     berlin,
+			want:    []string{"2030-03-30 Sat 19:00 CET", "2030-03-31 Sun 19:00 CEST"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := format(Expand(rule, tt.dtstart, tt.loc, nil, nil))
+			if !equal(got, tt.want) {
+				t.Errorf("Expand(%q)\n got %v\nwant %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSpringForwardGap(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	rule, err := Parse("FREQ=DAILY;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 02:30 does not exist on 2030-03-31 in Berlin; Go normalizes it forward
+	got := Expand(rule, time.Date(2030, 3, 30, 2, 30, 0, 0, berlin), berlin, nil, nil)
+	if len(got) != 3 {
+		t.Fatalf("got %d occurrences, want 3", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].After(got[i-1]) {
+			t.Errorf("occurrences out of order: %v", format(got))
+		}
+	}
+	if y, m, d := got[1].Date(); y != 2030 || m != time.March || d != 31 {
+		t.Errorf("second occurrence on %v, want 2030-03-31", got[1])
+	}
+}
+
+func TestExpandRDatesAndExDates(t *testing.T) {
+	utc := time.UTC
+	rule, err := Parse("FREQ=DAILY;COUNT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, utc)
+	rdates := []time.Time{
+		time.Date(2030, 1, 10, 9, 0, 0, 0, utc),
+		time.Date(2030, 1, 2, 9, 0, 0, 0, utc), // Duplicates a rule occurrence
+	}
+	exdates := []time.Time{time.Date(2030, 1, 3, 9, 0, 0, 0, utc)}
+
+	got := format(Expand(rule, start, utc, rdates, exdates))
+	want := []string{"2030-01-01 Tue 09:00 UTC", "2030-01-02 Wed 09:00 UTC", "2030-01-04 Fri 09:00 UTC", "2030-01-10 Thu 09:00 UTC"}
+	if !equal(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	// Without a rule only DTSTART and RDATEs occur
+	got = format(Expand(nil, start, utc, rdates[:1], nil))
+	want = []string{"2030-01-01 Tue 09:00 UTC", "2030-01-10 Thu 09:00 UTC"}
+	if !equal(got, want) {
+		t.Errorf("nil rule: got %v\nwant %v", got, want)
+	}
+}
+
+func TestExpandLimits(t *testing.T) {
+	utc := time.UTC
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, utc)
+
+	open, err := Parse("FREQ=WEEKLY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Expand(open, start, utc, nil, nil)
+	if last := got[len(got)-1]; last.After(start.Add(DefaultHorizon)) {
+		t.Errorf("open-ended rule ran past the horizon: %v", last)
+	}
+	if len(got) != 53 {
+		t.Errorf("open-ended weekly rule gave %d occurrences, want 53", len(got))
+	}
+
+	many, err := Parse("FREQ=DAILY;COUNT=1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Expand(many, start, utc, nil, nil); len(got) != MaxOccurrences {
+		t.Errorf("COUNT=1000 gave %d occurrences, want %d", len(got), MaxOccurrences)
+	}
+}
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("RRULE:freq=weekly;interval=2;byday=mo,fr;count=6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Freq != Weekly || rule.Interval != 2 || rule.Count != 6 || len(rule.ByDay) != 2 ||
+		rule.ByDay[0] != time.Monday || rule.ByDay[1] != time.Friday {
+		t.Errorf("Parse = %+v", rule)
+	}
+
+	until, err := Parse("FREQ=DAILY;UNTIL=20300105")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2030, 1, 5, 23, 59, 59, 0, time.UTC); !until.Until.Equal(want) {
+		t.Errorf("date-only UNTIL = %v, want %v", until.Until, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;UNTIL=2030-01-01",
+		"FREQ=WEEKLY;BYMONTH=1",
+		"FREQ",
+	} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", s, err)
+		}
+	}
+}
@@ -1,37 +1,156 @@
 package router
 
 import (
+	"strings"
+	"ticketing/internal/access"
 	"ticketing/internal/database"
 	"ticketing/internal/handler"
 	"ticketing/internal/middleware"
+	"ticketing/internal/oidc"
 	"ticketing/internal/queue"
+	"ticketing/internal/storage"
+	"ticketing/internal/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func RegisterRoutes(app *fiber.App, db database.Service, queueService queue.Service) {
+func RegisterRoutes(app *fiber.App, db database.Service, queueService queue.Service, store storage.Storage, sso oidc.IdentityProvider) {
 	// Handlers
 	helloHandler := handler.NewHelloHandler(db)
 	userHandler := handler.NewUserHandler(db)
 	eventHandler := handler.NewEventHandler(db)
 	ticketHandler := handler.NewTicketHandler(db, queueService)
+	venueHandler := handler.NewVenueHandler(db)
+	seriesHandler := handler.NewSeriesHandler(db)
+	detailsSchemaHandler := handler.NewDetailsSchemaHandler(db)
+	taxonomyHandler := handler.NewTaxonomyHandler(db)
+	templateHandler := handler.NewTemplateHandler(db)
+	mediaHandler := handler.NewMediaHandler(db, store)
+	teamHandler := handler.NewTeamHandler(db)
+	adminHandler := handler.NewAdminHandler(db)
+	keysHandler := handler.NewKeysHandler(utils.Keys)
 
 	rateLimit := middleware.RateLimitMiddleware(5, 5*time.Second)
+	canCreateEvents := middleware.RequirePermission(access.CreateEvents)
+	verified := middleware.RequireVerifiedEmail()
+	keyed := func(scope access.Scope) fiber.Handler { return middleware.JWTOrAPIKey(db, scope) }
+
+	app.Use(middleware.BodyLimit(fiber.DefaultBodyLimit, isMediaUpload))
 
 	// Routes
 	app.Get("/", helloHandler.HelloWorld)
 	app.Get("/health", helloHandler.Health)
+	app.Get("/.well-known/jwks.json", keysHandler.JWKS)
 
 	app.Post("/users/register", userHandler.RegisterUser)
 	app.Post("/users/login", userHandler.LoginUser)
+	app.Post("/users/login/mfa", rateLimit, userHandler.LoginMFA)
+	app.Post("/users/refresh", rateLimit, userHandler.RefreshToken)
+	app.Post("/users/logout", middleware.JWTProtected(), userHandler.LogoutUser)
+	app.Get("/users/verify", rateLimit, userHandler.VerifyEmail)
+	app.Get("/users/unlock", rateLimit, userHandler.UnlockAccount)
+	app.Post("/users/verify/resend", middleware.JWTProtected(), rateLimit, userHandler.ResendVerification)
+	app.Post("/users/password/forgot", rateLimit, userHandler.ForgotPassword)
+	app.Post("/users/password/reset", rateLimit, userHandler.ResetPassword)
+	app.Post("/users/password/change", middleware.JWTProtected(), rateLimit, userHandler.ChangePassword)
+	app.Post("/users/mfa/totp", middleware.JWTProtected(), rateLimit, userHandler.EnrollTOTP)
+	app.Post("/users/mfa/totp/confirm", middleware.JWTProtected(), rateLimit, userHandler.ConfirmTOTP)
+	app.Delete("/users/mfa/totp", middleware.JWTProtected(), rateLimit, userHandler.DisableTOTP)
+	app.Post("/users/mfa/recovery-codes", middleware.JWTProtected(), rateLimit, userHandler.RegenerateRecoveryCodes)
+	app.Get("/users/me", middleware.JWTProtected(), rateLimit, userHandler.GetProfile)
+	app.Patch("/users/me", middleware.JWTProtected(), rateLimit, userHandler.UpdateProfile)
+	app.Get("/users/me/tickets", middleware.JWTProtected(), rateLimit, userHandler.ListMyTickets)
+	app.Get("/users/me/events", middleware.JWTProtected(), rateLimit, userHandler.ListMyEvents)
+	app.Get("/users/email/confirm", rateLimit, userHandler.ConfirmEmailChange)
+	app.Post("/users/organizer-request", middleware.JWTProtected(), rateLimit, userHandler.RequestOrganizer)
+	app.Post("/users/api-keys", middleware.JWTProtected(), verified, canCreateEvents, rateLimit, userHandler.CreateAPIKey)
+	app.Get("/users/api-keys", middleware.JWTProtected(), rateLimit, userHandler.ListAPIKeys)
+	app.Delete("/users/api-keys/:keyID", middleware.JWTProtected(), rateLimit, userHandler.RevokeAPIKey)
+
+	// Single sign-on is only offered when a provider is configured
+	if sso != nil {
+		oidcHandler := handler.NewOIDCHandler(db, sso)
+		app.Get("/auth/oidc/login", rateLimit, oidcHandler.Login)
+		app.Get("/auth/oidc/callback", rateLimit, oidcHandler.Callback)
+	}
 
-	app.Post("/events", middleware.JWTProtected(), rateLimit, eventHandler.CreateEvent)
-	app.Get("/events/:id", middleware.JWTProtected(), rateLimit, eventHandler.GetEvent)
-	app.Put("/events/:id", middleware.JWTProtected(), rateLimit, eventHandler.UpdateEvent)
+	app.Post("/events", keyed(access.ScopeEventsWrite), verified, canCreateEvents, rateLimit, eventHandler.CreateEvent)
+	app.Get("/events", keyed(access.ScopeEventsRead), rateLimit, eventHandler.ListEvents)
+	app.Get("/events/deleted", middleware.JWTProtected(), rateLimit, eventHandler.ListDeletedEvents)
+	app.Get("/events/:id", keyed(access.ScopeEventsRead), rateLimit, eventHandler.GetEvent)
+	app.Put("/events/:id", keyed(access.ScopeEventsWrite), rateLimit, eventHandler.UpdateEvent)
+	app.Patch("/events/:id", keyed(access.ScopeEventsWrite), rateLimit, eventHandler.PatchEvent)
 	app.Delete("/events/:id", middleware.JWTProtected(), rateLimit, eventHandler.DeleteEvent)
+	app.Post("/events/:id/restore", middleware.JWTProtected(), rateLimit, eventHandler.RestoreEvent)
+	app.Post("/events/:id/publish", keyed(access.ScopeEventsWrite), rateLimit, eventHandler.PublishEvent)
+	app.Post("/events/:id/unpublish", keyed(access.ScopeEventsWrite), rateLimit, eventHandler.UnpublishEvent)
+	app.Post("/events/:id/open-sales", keyed(access.ScopeEventsWrite), rateLimit, eventHandler.OpenSales)
+	app.Post("/events/:id/cancel", middleware.JWTProtected(), rateLimit, eventHandler.CancelEvent)
+	app.Post("/events/:id/clone", middleware.JWTProtected(), verified, canCreateEvents, rateLimit, eventHandler.CloneEvent)
+	app.Post("/events/:id/media", middleware.JWTProtected(), rateLimit, mediaHandler.UploadEventMedia)
+	app.Get("/events/:id/media", middleware.JWTProtected(), rateLimit, mediaHandler.ListEventMedia)
+	app.Delete("/events/:id/media/:mediaID", middleware.JWTProtected(), rateLimit, mediaHandler.DeleteEventMedia)
+	app.Get("/media/:mediaID", mediaHandler.ServeMedia)
+
+	app.Get("/events/:id/team", middleware.JWTProtected(), rateLimit, teamHandler.ListTeam)
+	app.Post("/events/:id/team", middleware.JWTProtected(), rateLimit, teamHandler.InviteMember)
+	app.Post("/events/:id/team/accept", middleware.JWTProtected(), rateLimit, teamHandler.AcceptInvitation)
+	app.Patch("/events/:id/team/:userID", middleware.JWTProtected(), rateLimit, teamHandler.UpdateMember)
+	app.Delete("/events/:id/team/:userID", middleware.JWTProtected(), rateLimit, teamHandler.RemoveMember)
+	app.Get("/events/:id/attendees", keyed(access.ScopeTicketsRead), rateLimit, teamHandler.ListAttendees)
+	app.Post("/events/:id/tickets/:ticketID/checkin", keyed(access.ScopeCheckinWrite), rateLimit, teamHandler.CheckInTicket)
+	app.Post("/events/:id/tickets/:ticketID/cancel", middleware.JWTProtected(), rateLimit, teamHandler.CancelTicket)
+
+	app.Post("/templates", middleware.JWTProtected(), canCreateEvents, rateLimit, templateHandler.CreateTemplate)
+	app.Get("/templates", middleware.JWTProtected(), rateLimit, templateHandler.ListTemplates)
+	app.Get("/templates/:id", middleware.JWTProtected(), rateLimit, templateHandler.GetTemplate)
+	app.Delete("/templates/:id", middleware.JWTProtected(), rateLimit, templateHandler.DeleteTemplate)
+	app.Post("/templates/:id/events", middleware.JWTProtected(), verified, canCreateEvents, rateLimit, templateHandler.CreateEventFromTemplate)
+
+	app.Post("/series", middleware.JWTProtected(), verified, canCreateEvents, rateLimit, seriesHandler.CreateSeries)
+	app.Get("/series/:id", middleware.JWTProtected(), rateLimit, seriesHandler.GetSeries)
+	app.Patch("/series/:id/occurrences/:eventID", middleware.JWTProtected(), rateLimit, seriesHandler.EditOccurrences)
+
+	app.Post("/detail-schemas", middleware.JWTProtected(), canCreateEvents, rateLimit, detailsSchemaHandler.CreateDetailsSchema)
+	app.Get("/detail-schemas", middleware.JWTProtected(), rateLimit, detailsSchemaHandler.ListDetailsSchemas)
+	app.Get("/detail-schemas/:id", middleware.JWTProtected(), rateLimit, detailsSchemaHandler.GetDetailsSchema)
 
-	app.Post("/tickets", rateLimit, ticketHandler.AddTicketToQueue)
-	app.Get("/tickets/:ticketID", rateLimit, ticketHandler.GetTicketDetails)
+	app.Post("/categories", middleware.JWTProtected(), middleware.RequirePermission(access.ManageCategories), rateLimit, taxonomyHandler.CreateCategory)
+	app.Get("/categories", middleware.JWTProtected(), rateLimit, taxonomyHandler.ListCategories)
+	app.Get("/tags", middleware.JWTProtected(), rateLimit, taxonomyHandler.ListTags)
+	app.Post("/collections", middleware.JWTProtected(), canCreateEvents, rateLimit, taxonomyHandler.CreateCollection)
+	app.Get("/collections", middleware.JWTProtected(), rateLimit, taxonomyHandler.ListCollections)
+	app.Get("/collections/:slug", middleware.JWTProtected(), rateLimit, taxonomyHandler.GetCollection)
+	app.Post("/collections/:slug/events", middleware.JWTProtected(), canCreateEvents, rateLimit, taxonomyHandler.AddCollectionEvent)
+	app.Delete("/collections/:slug/events/:eventID", middleware.JWTProtected(), canCreateEvents, rateLimit, taxonomyHandler.RemoveCollectionEvent)
+
+	app.Post("/venues", middleware.JWTProtected(), canCreateEvents, rateLimit, venueHandler.CreateVenue)
+	app.Get("/venues/:id", middleware.JWTProtected(), rateLimit, venueHandler.GetVenue)
+
+	admin := app.Group("/admin", middleware.JWTProtected(), middleware.RequireRole(database.RoleAdmin), rateLimit)
+	admin.Get("/users", adminHandler.ListUsers)
+	admin.Get("/login-failures", adminHandler.ListFailedLogins)
+	admin.Patch("/users/:userID/role", adminHandler.SetUserRole)
+	admin.Post("/users/:userID/organizer/approve", adminHandler.ApproveOrganizer)
+	admin.Post("/users/:userID/organizer/reject", adminHandler.RejectOrganizer)
+	admin.Put("/events/:id/owner", adminHandler.TransferEvent)
+	admin.Get("/events/deleted", adminHandler.ListDeletedEvents)
+	admin.Post("/events/:id/restore", adminHandler.RestoreEvent)
+	admin.Post("/keys/rotate", keysHandler.RotateKeys)
+	admin.Get("/security-policy", adminHandler.GetSecurityPolicy)
+	admin.Put("/security-policy", adminHandler.UpdateSecurityPolicy)
+
+	app.Post("/tickets", middleware.JWTProtected(), verified, rateLimit, ticketHandler.AddTicketToQueue)
+	app.Get("/tickets/:ticketID", middleware.JWTProtected(), rateLimit, ticketHandler.GetTicketDetails)
 	app.Get("/queue/:eventID/length", rateLimit, ticketHandler.GetQueueLength)
 }
+
+// isMediaUpload matches POST /events/:id/media, the only route that accepts
+// bodies above the default limit. Paths match case-insensitively and with an
+// optional trailing slash, like the router.
+func isMediaUpload(c *fiber.Ctx) bool {
+	parts := strings.Split(strings.Trim(c.Path(), "/"), "/")
+	return c.Method() == fiber.MethodPost && len(parts) == 3 &&
+		strings.EqualFold(parts[0], "events") && strings.EqualFold(parts[2], "media")
+}
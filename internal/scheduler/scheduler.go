@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"ticketing/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock abstracts time so the scheduler can be driven by a fake clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now().UTC() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Handler runs a single job. Returning an error schedules a retry. The
+// context is cancelled if the job's lease is lost while the handler runs.
+type Handler func(ctx context.Context, job *database.Job) error
+
+// Scheduler polls the jobs table and dispatches due jobs to registered handlers.
+// Several replicas may run against the same database; leases keep them from
+// running a job concurrently, and expired leases are picked up again. The
+// lease of a running job is renewed every third of LeaseDuration, so only a
+// worker that died or stalled loses its jobs.
+type Scheduler struct {
+	db       database.Service
+	clock    Clock
+	workerID string
+
+	PollInterval  time.Duration // Delay between polls when no job was due
+	LeaseDuration time.Duration // How long a lease lasts without being renewed
+	BatchSize     int           // Jobs leased per poll
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New creates a scheduler with sensible defaults.
+func New(db database.Service, clock Clock) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:            db,
+		clock:         clock,
+		workerID:      fmt.Sprintf("%s-%s", host, uuid.New().String()[:8]),
+		PollInterval:  time.Second,
+		LeaseDuration: time.Minute,
+		BatchSize:     10,
+		handlers:      make(map[string]Handler),
+	}
+}
+
+// Register binds a handler to a job name.
+func (s *Scheduler) Register(name string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = h
+}
+
+// Schedule enqueues a job to run at runAt with payload encoded as JSON.
+func (s *Scheduler) Schedule(name string, payload interface{}, runAt time.Time) (*database.Job, error) {
+	return Enqueue(s.db, name, payload, runAt)
+}
+
+// Enqueue stores a job without needing a running scheduler, so the API can
+// schedule work that worker replicas will execute.
+func Enqueue(db database.Service, name string, payload interface{}, runAt time.Time) (*database.Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &database.Job{
+		Name:    name,
+		Payload: string(body),
+		RunAt:   runAt.UTC(),
+	}
+	if err := db.EnqueueJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Run polls for due jobs until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Scheduler %s started", s.workerID)
+	for {
+		n, err := s.RunOnce(ctx)
+		if err != nil {
+			log.Printf("Scheduler poll failed: %v", err)
+		}
+
+		// Keep draining while there is a backlog.
+		if n == s.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Scheduler %s stopped", s.workerID)
+			return
+		case <-s.clock.After(s.PollInterval):
+		}
+	}
+}
+
+// RunOnce leases one batch of due jobs and runs them, returning how many were leased.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	jobs, err := s.db.LeaseJobs(s.workerID, s.clock.Now(), s.LeaseDuration, s.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		s.runJob(ctx, &jobs[i])
+	}
+	return len(jobs), nil
+}
+
+func (s *Scheduler) runJob(ctx context.Context, job *database.Job) {
+	s.mu.RLock()
+	h, ok := s.handlers[job.Name]
+	s.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for job %q", job.Name)
+	} else {
+		ctx, cancel := context.WithCancel(ctx)
+		stop := s.heartbeat(job, cancel)
+		err = safeCall(ctx, h, job)
+		stop()
+		cancel()
+	}
+
+	now := s.clock.Now()
+	if err != nil {
+		log.Printf("Job %d (%s) attempt %d failed: %v", job.ID, job.Name, job.Attempts, err)
+		if ferr := s.db.FailJob(job.ID, s.workerID, now, now.Add(Backoff(job.Attempts)), err); ferr != nil {
+			log.Printf("Could not record failure for job %d: %v", job.ID, ferr)
+		}
+		return
+	}
+
+	if cerr := s.db.CompleteJob(job.ID, s.workerID, now); cerr != nil {
+		log.Printf("Could not complete job %d: %v", job.ID, cerr)
+	}
+}
+
+// heartbeat renews the lease on job until the returned function is called.
+// If the lease is lost, lost is called so the handler can stop early.
+func (s *Scheduler) heartbeat(job *database.Job, lost context.CancelFunc) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-s.clock.After(s.LeaseDuration / 3):
+			}
+
+			err := s.db.ExtendJobLease(job.ID, s.workerID, s.clock.Now().Add(s.LeaseDuration))
+			if errors.Is(err, database.ErrJobLeaseLost) {
+				log.Printf("Job %d (%s) lost its lease while running", job.ID, job.Name)
+				lost()
+				return
+			}
+			if err != nil {
+				log.Printf("Could not renew lease of job %d: %v", job.ID, err)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// safeCall runs a handler, turning a panic into an error so one bad job
+// cannot take down the worker.
+func safeCall(ctx context.Context, h Handler, job *database.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// Backoff returns the retry delay after the given number of attempts.
+func Backoff(attempts int) time.Duration {
+	d := time.Duration(attempts*attempts) * 30 * time.Second
+	if d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
@@ -0,0 +1,469 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"ticketing/internal/database"
+	"time"
+)
+
+// fakeClock only moves when the test advances it. Timers from After fire
+// once the clock is advanced past them.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// waitForTimer blocks until something is waiting on After.
+func (c *fakeClock) waitForTimer(t *testing.T) {
+	t.Helper()
+	waitFor(t, "a timer", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.timers) > 0
+	})
+}
+
+// waitFor polls cond until it holds, for state changed by other goroutines.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// memJobs keeps jobs in memory with the leasing rules of the jobs table.
+// The SQL itself is covered by the integration tests in internal/database.
+type memJobs struct {
+	database.Service
+	mu     sync.Mutex
+	jobs   []*database.Job
+	nextID uint
+}
+
+func (m *memJobs) EnqueueJob(job *database.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	job.ID = m.nextID
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = 5
+	}
+	stored := *job
+	m.jobs = append(m.jobs, &stored)
+	return nil
+}
+
+func (m *memJobs) LeaseJobs(workerID string, now time.Time, lease time.Duration, limit int) ([]database.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*database.Job
+	for _, j := range m.jobs {
+		if j.CompletedAt != nil || j.FailedAt != nil {
+			continue
+		}
+		expired := j.LockedUntil != nil && j.LockedUntil.Before(now)
+		if expired && j.Attempts >= j.MaxAttempts {
+			failedAt := now
+			j.FailedAt, j.LockedBy, j.LockedUntil = &failedAt, "", nil
+			continue
+		}
+		if j.Attempts < j.MaxAttempts && !j.RunAt.After(now) && (j.LockedUntil == nil || expired) {
+			due = append(due, j)
+		}
+	}
+	sort.Slice(due, func(a, b int) bool { return due[a].RunAt.Before(due[b].RunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	leased := make([]database.Job, 0, len(due))
+	for _, j := range due {
+		until := now.Add(lease)
+		j.LockedBy, j.LockedUntil = workerID, &until
+		j.Attempts++
+		leased = append(leased, *j)
+	}
+	return leased, nil
+}
+
+func (m *memJobs) ExtendJobLease(id uint, workerID string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil || j.LockedBy != workerID || j.CompletedAt != nil || j.FailedAt != nil {
+		return database.ErrJobLeaseLost
+	}
+	j.LockedUntil = &until
+	return nil
+}
+
+func (m *memJobs) CompleteJob(id uint, workerID string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil || j.LockedBy != workerID {
+		return database.ErrJobLeaseLost
+	}
+	j.CompletedAt, j.LockedBy, j.LockedUntil, j.LastError = &now, "", nil, ""
+	return nil
+}
+
+func (m *memJobs) FailJob(id uint, workerID string, now time.Time, retryAt time.Time, jobErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil || j.LockedBy != workerID {
+		return database.ErrJobLeaseLost
+	}
+	j.RunAt, j.LockedBy, j.LockedUntil, j.LastError = retryAt, "", nil, jobErr.Error()
+	if j.Attempts >= j.MaxAttempts {
+		j.FailedAt = &now
+	}
+	return nil
+}
+
+func (m *memJobs) find(id uint) *database.Job {
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (m *memJobs) job(t *testing.T, id uint) database.Job {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.find(id)
+	if j == nil {
+		t.Fatalf("job %d not found", id)
+	}
+	return *j
+}
+
+func newTestScheduler() (*Scheduler, *memJobs, *fakeClock) {
+	db := &memJobs{}
+	clock := &fakeClock{now: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return New(db, clock), db, clock
+}
+
+func runOnce(t *testing.T, s *Scheduler) int {
+	t.Helper()
+	n, err := s.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	return n
+}
+
+func TestJobRunsOnceDue(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	var runs int
+	s.Register("greet", func(ctx context.Context, job *database.Job) error {
+		runs++
+		if job.Payload != `{"name":"ada"}` {
+			t.Errorf("payload = %s", job.Payload)
+		}
+		return nil
+	})
+
+	job, err := s.Schedule("greet", map[string]string{"name": "ada"}, clock.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := runOnce(t, s); n != 0 {
+		t.Fatalf("leased %d jobs before run_at", n)
+	}
+	clock.Advance(time.Minute)
+	if n := runOnce(t, s); n != 1 {
+		t.Fatalf("leased %d jobs at run_at, want 1", n)
+	}
+	if got := db.job(t, job.ID); got.CompletedAt == nil || got.Attempts != 1 {
+		t.Errorf("job = %+v, want completed after 1 attempt", got)
+	}
+	if n := runOnce(t, s); n != 0 || runs != 1 {
+		t.Errorf("completed job ran again: leased %d, runs %d", n, runs)
+	}
+}
+
+func TestFailedJobBacksOffUntilMaxAttempts(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	var runs int
+	s.Register("flaky", func(context.Context, *database.Job) error {
+		runs++
+		return errors.New("boom")
+	})
+	job, err := s.Schedule("flaky", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 5; attempt++ {
+		if n := runOnce(t, s); n != 1 {
+			t.Fatalf("attempt %d: leased %d jobs, want 1", attempt, n)
+		}
+		got := db.job(t, job.ID)
+		if got.Attempts != attempt || got.LastError != "boom" {
+			t.Fatalf("attempt %d: job = %+v", attempt, got)
+		}
+		if attempt == 5 {
+			if got.FailedAt == nil {
+				t.Fatal("job not failed after max attempts")
+			}
+			break
+		}
+		if want := clock.Now().Add(Backoff(attempt)); !got.RunAt.Equal(want) {
+			t.Fatalf("attempt %d: retry at %v, want %v", attempt, got.RunAt, want)
+		}
+
+		// Not retried before the backoff has passed
+		clock.Advance(Backoff(attempt) - time.Second)
+		if n := runOnce(t, s); n != 0 {
+			t.Fatalf("attempt %d: retried before backoff", attempt)
+		}
+		clock.Advance(time.Second)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if n := runOnce(t, s); n != 0 || runs != 5 {
+		t.Errorf("failed job ran again: leased %d, runs %d", n, runs)
+	}
+}
+
+func TestExpiredLeaseIsRetriedThenFailed(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	s.Register("slow", func(context.Context, *database.Job) error { return nil })
+	job, err := s.Schedule("slow", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crashed worker leases the job on every attempt and never reports back
+	crashed := New(db, clock)
+	for attempt := 1; attempt <= 5; attempt++ {
+		jobs, err := db.LeaseJobs(crashed.workerID, clock.Now(), crashed.LeaseDuration, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 1 {
+			t.Fatalf("attempt %d: leased %d jobs, want 1", attempt, len(jobs))
+		}
+		if n := runOnce(t, s); n != 0 {
+			t.Fatalf("attempt %d: leased job taken while the lease was held", attempt)
+		}
+		clock.Advance(crashed.LeaseDuration + time.Second)
+	}
+
+	if n := runOnce(t, s); n != 0 {
+		t.Fatalf("job re-leased after max attempts")
+	}
+	if got := db.job(t, job.ID); got.FailedAt == nil || got.Attempts != 5 {
+		t.Errorf("job = %+v, want failed after 5 attempts", got)
+	}
+}
+
+func TestLostLeaseIsNotCompleted(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	job, err := s.Schedule("slow", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first worker overruns its lease and another worker takes the job
+	first := New(db, clock)
+	if _, err := db.LeaseJobs(first.workerID, clock.Now(), first.LeaseDuration, 10); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(first.LeaseDuration + time.Second)
+	if _, err := db.LeaseJobs(s.workerID, clock.Now(), s.LeaseDuration, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.CompleteJob(job.ID, first.workerID, clock.Now()); !errors.Is(err, database.ErrJobLeaseLost) {
+		t.Errorf("CompleteJob by the old worker = %v, want ErrJobLeaseLost", err)
+	}
+	if err := db.FailJob(job.ID, first.workerID, clock.Now(), clock.Now(), errors.New("boom")); !errors.Is(err, database.ErrJobLeaseLost) {
+		t.Errorf("FailJob by the old worker = %v, want ErrJobLeaseLost", err)
+	}
+	if got := db.job(t, job.ID); got.CompletedAt != nil || got.LockedBy != s.workerID {
+		t.Errorf("job = %+v, want still leased by the new worker", got)
+	}
+}
+
+func TestLeaseRenewedWhileHandlerRuns(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs int
+	s.Register("long", func(ctx context.Context, job *database.Job) error {
+		runs++
+		close(started)
+		<-release
+		return nil
+	})
+	job, err := s.Schedule("long", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := s.RunOnce(context.Background())
+		done <- err
+	}()
+	<-started
+
+	// The handler runs for three lease durations; the lease never expires
+	other := New(db, clock)
+	for i := 0; i < 9; i++ {
+		clock.waitForTimer(t)
+		clock.Advance(s.LeaseDuration / 3)
+		want := clock.Now().Add(s.LeaseDuration)
+		waitFor(t, "the lease to be renewed", func() bool {
+			j := db.job(t, job.ID)
+			return j.LockedUntil != nil && j.LockedUntil.Equal(want)
+		})
+		jobs, err := db.LeaseJobs(other.workerID, clock.Now(), other.LeaseDuration, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 0 {
+			t.Fatalf("heartbeat %d: running job leased by another worker", i+1)
+		}
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got := db.job(t, job.ID); got.CompletedAt == nil || got.Attempts != 1 || runs != 1 {
+		t.Errorf("job = %+v after %d runs, want completed after 1 attempt", got, runs)
+	}
+}
+
+func TestLostLeaseCancelsHandler(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	started := make(chan struct{})
+	s.Register("long", func(ctx context.Context, job *database.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	job, err := s.Schedule("long", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := s.RunOnce(context.Background())
+		done <- err
+	}()
+	<-started
+
+	// Another worker took the job, as if this one had stalled
+	db.mu.Lock()
+	db.find(job.ID).LockedBy = "other-worker"
+	db.mu.Unlock()
+	clock.waitForTimer(t)
+	clock.Advance(s.LeaseDuration / 3)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not cancelled after losing its lease")
+	}
+	if got := db.job(t, job.ID); got.CompletedAt != nil || got.LockedBy != "other-worker" {
+		t.Errorf("job = %+v, want left to the other worker", got)
+	}
+}
+
+func TestUnknownHandlerFails(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	job, err := s.Schedule("missing", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOnce(t, s)
+	if got := db.job(t, job.ID); got.CompletedAt != nil || got.LastError == "" {
+		t.Errorf("job = %+v, want a recorded failure", got)
+	}
+}
+
+func TestPanickingHandlerFails(t *testing.T) {
+	s, db, clock := newTestScheduler()
+	s.Register("panics", func(context.Context, *database.Job) error { panic("oops") })
+	job, err := s.Schedule("panics", nil, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runOnce(t, s)
+	if got := db.job(t, job.ID); got.LastError != "handler panicked: oops" {
+		t.Errorf("last error = %q", got.LastError)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, 2 * time.Minute},
+		{3, 4*time.Minute + 30*time.Second},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
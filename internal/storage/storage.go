@@ -0,0 +1,53 @@
+// Package storage stores uploaded files such as event images behind a small
+// interface so the local filesystem backend can be swapped for an
+// S3-compatible one without touching the handlers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNotFound is returned when no object is stored under a key.
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for keys that are empty or escape the store.
+var ErrInvalidKey = errors.New("invalid object key")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Storage is a flat key/value store for binary objects. Keys are
+// slash-separated paths such as "events/<event-id>/<media-id>.jpg".
+type Storage interface {
+	// Put stores the content read from r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Open returns a reader for the object under key. The caller closes it.
+	Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Delete removes the object under key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv builds the storage backend selected by MEDIA_STORAGE. Only "local"
+// (the default) is built in; it stores files under MEDIA_DIR, or ./media.
+func FromEnv() (Storage, error) {
+	switch backend := os.Getenv("MEDIA_STORAGE"); backend {
+	case "", "local":
+		root := os.Getenv("MEDIA_DIR")
+		if root == "" {
+			root = "media"
+		}
+		return NewLocal(root)
+	default:
+		return nil, fmt.Errorf("unsupported media storage backend %q", backend)
+	}
+}
@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of RFC 6238 appendix B, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// Appendix B lists 8-digit codes; 6-digit codes are their last 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("T=%d: code %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	got, err := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Counter(time.Unix(59, 0)))
+	if err != nil || got != "287082" {
+		t.Errorf("Code = %q, %v", got, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Counter(now)
+
+	for _, tt := range []struct {
+		step int64
+		ok   bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	} {
+		code, err := Code(rfcSecret, current+tt.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter, ok := Validate(rfcSecret, code, now)
+		if ok != tt.ok {
+			t.Errorf("step %+d: ok = %v, want %v", tt.step, ok, tt.ok)
+		}
+		if ok && counter != current+tt.step {
+			t.Errorf("step %+d: matched counter %d, want %d", tt.step, counter, current+tt.step)
+		}
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "05047a"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate accepted %q", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, " 050471 ", now); !ok {
+		t.Error("Validate rejected a code with surrounding spaces")
+	}
+}
@@ -2,14 +2,39 @@ package utils
 
 import (
 	"fmt"
+	"html"
+	"log"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/resend/resend-go/v2"
 )
 
-func sendEmail(subject, toEmail, htmlContent string) error {
-	apiKey := os.Getenv("RESEND_API_KEY") // Replace with your actual API key
-	client := resend.NewClient(apiKey)
+// Mailer delivers an HTML email.
+type Mailer interface {
+	Send(subject, toEmail, htmlContent string) error
+}
+
+// Mail is the mailer every email goes through. It is chosen by
+// EMAIL_PROVIDER: "resend" (the default) or "log", which only logs the
+// message for local development. Replace it to plug in another provider.
+var Mail Mailer = mailerFromEnv()
+
+func mailerFromEnv() Mailer {
+	if os.Getenv("EMAIL_PROVIDER") == "log" {
+		return LogMailer{}
+	}
+	return ResendMailer{APIKey: os.Getenv("RESEND_API_KEY")}
+}
+
+// ResendMailer sends email through the Resend API.
+type ResendMailer struct {
+	APIKey string
+}
+
+func (m ResendMailer) Send(subject, toEmail, htmlContent string) error {
+	client := resend.NewClient(m.APIKey)
 
 	params := &resend.SendEmailRequest{
 		From:    "Acme <onboarding@resend.dev>",
@@ -26,3 +51,80 @@ func sendEmail(subject, toEmail, htmlContent string) error {
 	fmt.Println("Email sent successfully, ID:", sent.Id)
 	return nil
 }
+
+// LogMailer writes emails to the log instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(subject, toEmail, htmlContent string) error {
+	log.Printf("Email to %s: %s\n%s", toEmail, subject, htmlContent)
+	return nil
+}
+
+func sendEmail(subject, toEmail, htmlContent string) error {
+	return Mail.Send(subject, toEmail, htmlContent)
+}
+
+// appURL is the public base URL used in links sent by email.
+func appURL() string {
+	if u := os.Getenv("APP_BASE_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// SendEventCancelledEmail tells a ticket holder that their event was cancelled.
+func SendEventCancelledEmail(toEmail, eventName string) error {
+	subject := fmt.Sprintf("%s has been cancelled", eventName)
+	body := fmt.Sprintf("<p>We're sorry, <strong>%s</strong> has been cancelled by the organizer.</p><p>Your tickets are no longer valid.</p>", html.EscapeString(eventName))
+	return sendEmail(subject, toEmail, body)
+}
+
+// SendTeamInviteEmail invites a user to join an event's team.
+func SendTeamInviteEmail(toEmail, eventName, role string) error {
+	subject := fmt.Sprintf("You've been invited to the %s team", eventName)
+	body := fmt.Sprintf("<p>You've been invited to help run <strong>%s</strong> as <strong>%s</strong>.</p><p>Sign in and accept the invitation to get access.</p>", html.EscapeString(eventName), html.EscapeString(strings.ReplaceAll(role, "_", " ")))
+	return sendEmail(subject, toEmail, body)
+}
+
+// SendVerificationEmail asks a new user to confirm their email address.
+func SendVerificationEmail(toEmail, token string) error {
+	link := appURL() + "/users/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("<p>Confirm your email address to start booking tickets.</p><p><a href=\"%s\">Verify my email</a></p><p>The link expires in %d hours.</p>", html.EscapeString(link), int(EmailVerificationTTL.Hours()))
+	return sendEmail("Verify your email address", toEmail, body)
+}
+
+// SendPasswordResetEmail mails a password reset link. The link points at
+// PASSWORD_RESET_URL, the client page that posts the token and the new
+// password to /users/password/reset.
+func SendPasswordResetEmail(toEmail, token string) error {
+	page := os.Getenv("PASSWORD_RESET_URL")
+	if page == "" {
+		page = appURL() + "/reset-password"
+	}
+	link := page + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("<p>Someone asked to reset the password of your account. If it was you, choose a new password here:</p><p><a href=\"%s\">Reset my password</a></p><p>The link expires in %d minutes. If you did not ask for it, you can ignore this email.</p>", html.EscapeString(link), int(PasswordResetTTL.Minutes()))
+	return sendEmail("Reset your password", toEmail, body)
+}
+
+// SendAccountLockedEmail tells a user their account was locked after repeated
+// failed logins and links to unlocking it right away.
+func SendAccountLockedEmail(toEmail, token string) error {
+	link := appURL() + "/users/unlock?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("<p>Your account was locked for %d minutes after too many failed login attempts.</p><p>If it was you, you can unlock it now:</p><p><a href=\"%s\">Unlock my account</a></p><p>If it wasn't you, consider resetting your password.</p>", int(AccountLockDuration.Minutes()), html.EscapeString(link))
+	return sendEmail("Your account was locked", toEmail, body)
+}
+
+// SendEmailChangeEmail asks a user to confirm the new address of their
+// account from its inbox.
+func SendEmailChangeEmail(toEmail, token string) error {
+	link := appURL() + "/users/email/confirm?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("<p>Confirm that you want to use this address for your account.</p><p><a href=\"%s\">Confirm my new email</a></p><p>The link expires in %d hours. Until then, your old address stays in use.</p>", html.EscapeString(link), int(EmailVerificationTTL.Hours()))
+	return sendEmail("Confirm your new email address", toEmail, body)
+}
+
+// SendEmailChangeNoticeEmail tells a user at their current address that a
+// change to another address was requested.
+func SendEmailChangeNoticeEmail(toEmail, newEmail string) error {
+	body := fmt.Sprintf("<p>Someone asked to change the email address of your account to <strong>%s</strong>. It changes once the new address is confirmed.</p><p>If it wasn't you, reset your password.</p>", html.EscapeString(newEmail))
+	return sendEmail("Your email address is being changed", toEmail, body)
+}
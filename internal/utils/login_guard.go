@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Failed login thresholds. Attempts are counted per account email and per
+// client IP when they start, taken back when they succeed, and expire
+// LoginFailureWindow after the first one.
+const (
+	LoginFailureWindow   = 15 * time.Minute
+	AccountLockThreshold = 10               // Account failures that lock the account
+	AccountLockDuration  = 30 * time.Minute // How long a locked account stays locked
+	accountDelayAfter    = 3                // Account failures before delays start
+	ipDelayAfter         = 20               // IP failures before delays start
+	maxLoginDelay        = 30 * time.Second
+)
+
+// Errors returned while a login is throttled.
+var (
+	ErrLoginThrottled = errors.New("too many failed login attempts, try again later")
+	ErrAccountLocked  = errors.New("account is temporarily locked after too many failed login attempts")
+)
+
+// Failures are keyed by email rather than user ID so unknown emails are
+// throttled and locked exactly like registered ones.
+func loginKey(kind, id string) string {
+	return "login:" + kind + ":" + id
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// reserveLoginScript checks the lock and delays of an account and client IP
+// and, if a login may be attempted, counts it as a failure up front and sets
+// the delay the next attempt has to wait. Doing both in one script means
+// concurrent attempts cannot all pass the check before any of them is
+// counted. At most AccountLockThreshold attempts can be pending for an
+// account, so the lock is reached however many requests race.
+//
+// KEYS: lock, delay:email, delay:ip, failures:email, failures:ip
+// ARGV: window ms, lock threshold, account delay after, IP delay after, max delay ms
+// Returns {0, 0} when allowed, {1, ttl} when locked and {2, ttl} when throttled.
+var reserveLoginScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then return {1, ttl} end
+for i = 2, 3 do
+	ttl = redis.call('PTTL', KEYS[i])
+	if ttl > 0 then return {2, ttl} end
+end
+if tonumber(redis.call('GET', KEYS[4]) or '0') >= tonumber(ARGV[2]) then
+	return {2, 1000}
+end
+
+local function count(key)
+	local n = redis.call('INCR', key)
+	if n == 1 then redis.call('PEXPIRE', key, ARGV[1]) end
+	return n
+end
+-- The wait doubles with every failure past the threshold: 1s, 2s, 4s and
+-- so on up to the maximum.
+local function delay(key, n, after)
+	if n < after then return end
+	local d = math.min(1000 * 2 ^ math.min(n - after, 16), tonumber(ARGV[5]))
+	redis.call('SET', key, 1, 'PX', d)
+end
+delay(KEYS[2], count(KEYS[4]), tonumber(ARGV[3]))
+delay(KEYS[3], count(KEYS[5]), tonumber(ARGV[4]))
+return {0, 0}
+`)
+
+// ReserveLogin reports whether a login for email from ip may be attempted
+// and, if so, counts it as failed until ClearLoginFailures says otherwise.
+// Call it before checking any credential. If the login may not be attempted,
+// it returns ErrAccountLocked or ErrLoginThrottled and how long the client
+// should wait.
+func ReserveLogin(email, ip string) (time.Duration, error) {
+	email = normalizeEmail(email)
+	res, err := reserveLoginScript.Run(context.Background(), Rdb,
+		[]string{
+			loginKey("lock", email), loginKey("delay:email", email), loginKey("delay:ip", ip),
+			loginKey("failures:email", email), loginKey("failures:ip", ip),
+		},
+		LoginFailureWindow.Milliseconds(), AccountLockThreshold, accountDelayAfter, ipDelayAfter,
+		maxLoginDelay.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	wait := time.Duration(res[1]) * time.Millisecond
+	switch res[0] {
+	case 1:
+		return wait, ErrAccountLocked
+	case 2:
+		return wait, ErrLoginThrottled
+	}
+	return 0, nil
+}
+
+// lockLoginScript locks the account once its counted failures reach the
+// threshold. The lock replaces the counter; failures start over once it
+// expires. Returns 1 if this call locked the account.
+//
+// KEYS: lock, failures:email, delay:email
+// ARGV: lock threshold, lock duration ms
+var lockLoginScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[2]) or '0') < tonumber(ARGV[1]) then return 0 end
+local locked = redis.call('SET', KEYS[1], 1, 'PX', ARGV[2], 'NX')
+redis.call('DEL', KEYS[2], KEYS[3])
+if locked then return 1 end
+return 0
+`)
+
+// RecordLoginFailure confirms that a login reserved with ReserveLogin failed
+// and locks the account once it has AccountLockThreshold failures. It reports
+// whether this failure locked the account.
+func RecordLoginFailure(email string) (bool, error) {
+	email = normalizeEmail(email)
+	locked, err := lockLoginScript.Run(context.Background(), Rdb,
+		[]string{loginKey("lock", email), loginKey("failures:email", email), loginKey("delay:email", email)},
+		AccountLockThreshold, AccountLockDuration.Milliseconds(),
+	).Int()
+	return locked == 1, err
+}
+
+// releaseIPScript takes back the attempt a successful login counted for its
+// client IP, without letting the counter go below zero.
+var releaseIPScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') > 0 then redis.call('DECR', KEYS[1]) end
+return 0
+`)
+
+// ReleaseLoginAttempt takes back the attempt ReserveLogin counted for ip once
+// the credential checked in it turned out right.
+func ReleaseLoginAttempt(ip string) error {
+	return releaseIPScript.Run(context.Background(), Rdb, []string{loginKey("failures:ip", ip)}).Err()
+}
+
+// ClearLoginFailures forgets the failed logins of an account after a
+// successful one and takes back the attempt ReserveLogin counted for ip.
+// Earlier per-IP failures are kept so an attacker cannot reset them with an
+// account of their own.
+func ClearLoginFailures(email, ip string) error {
+	email = normalizeEmail(email)
+	if err := Rdb.Del(context.Background(), loginKey("failures:email", email), loginKey("delay:email", email)).Err(); err != nil {
+		return err
+	}
+	return ReleaseLoginAttempt(ip)
+}
+
+// UnlockLogin lifts the lock and delays of an account.
+func UnlockLogin(email string) error {
+	email = normalizeEmail(email)
+	return Rdb.Del(context.Background(),
+		loginKey("lock", email), loginKey("failures:email", email), loginKey("delay:email", email)).Err()
+}
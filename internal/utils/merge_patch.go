@@ -0,0 +1,38 @@
+package utils
+
+import "encoding/json"
+
+// MergePatch applies an RFC 7386 JSON Merge Patch to target and returns the
+// resulting document. Objects are merged recursively, null removes a member
+// and any other value replaces it.
+func MergePatch(target, patch []byte) ([]byte, error) {
+	var t, p interface{}
+	if err := json.Unmarshal(target, &t); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(t, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], value)
+	}
+	return targetObj
+}
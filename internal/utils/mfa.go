@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// MFAChallengeTTL is how long the second step of a login may take.
+const MFAChallengeTTL = 5 * time.Minute
+
+// maxMFAAttempts is how many codes may be tried against one challenge.
+const maxMFAAttempts = 5
+
+// RecoveryCodeCount is how many recovery codes a user gets at a time.
+const RecoveryCodeCount = 10
+
+// ErrMFAChallengeInvalid is returned for unknown, expired or exhausted MFA
+// challenge tokens.
+var ErrMFAChallengeInvalid = errors.New("MFA token is invalid or expired, sign in again")
+
+// MFAIssuer names the service in authenticator apps, from TOTP_ISSUER.
+func MFAIssuer() string {
+	if iss := os.Getenv("TOTP_ISSUER"); iss != "" {
+		return iss
+	}
+	return "Ticketing"
+}
+
+func mfaChallengeKey(hash string) string {
+	return "mfa:challenge:" + hash
+}
+
+func mfaAttemptsKey(hash string) string {
+	return "mfa:attempts:" + hash
+}
+
+// NewMFAChallenge starts the second step of a login for the user and returns
+// the token the client presents with its code.
+func NewMFAChallenge(userID string) (string, error) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := Rdb.Set(context.Background(), mfaChallengeKey(hash), userID, MFAChallengeTTL).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// MFAChallengeUser returns the user an MFA challenge was issued to and counts
+// an attempt against it. The challenge is dropped after maxMFAAttempts.
+func MFAChallengeUser(token string) (string, error) {
+	ctx := context.Background()
+	hash := HashToken(token)
+
+	userID, err := Rdb.Get(ctx, mfaChallengeKey(hash)).Result()
+	if err == redis.Nil {
+		return "", ErrMFAChallengeInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	attempts, err := Rdb.Incr(ctx, mfaAttemptsKey(hash)).Result()
+	if err != nil {
+		return "", err
+	}
+	if attempts == 1 {
+		if err := Rdb.Expire(ctx, mfaAttemptsKey(hash), MFAChallengeTTL).Err(); err != nil {
+			return "", err
+		}
+	}
+	if attempts > maxMFAAttempts {
+		Rdb.Del(ctx, mfaChallengeKey(hash), mfaAttemptsKey(hash))
+		return "", ErrMFAChallengeInvalid
+	}
+	return userID, nil
+}
+
+// EndMFAChallenge consumes a challenge once its code was accepted. It
+// reports false if another request consumed it first.
+func EndMFAChallenge(token string) (bool, error) {
+	hash := HashToken(token)
+	n, err := Rdb.Del(context.Background(), mfaChallengeKey(hash), mfaAttemptsKey(hash)).Result()
+	return n > 0, err
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns RecoveryCodeCount codes formatted like
+// "abcde-fghij" and their hashes.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := recoveryEncoding.EncodeToString(buf)[:10]
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes
+// so codes can be typed loosely.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
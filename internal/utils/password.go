@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"log"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,3 +18,35 @@ func ComparePassword(hashedPassword, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// Password length limits. bcrypt ignores everything past 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// Password policy errors.
+var (
+	ErrPasswordLength = errors.New("password must be between 8 and 72 characters")
+	ErrPasswordWeak   = errors.New("password must contain at least one letter and one digit")
+)
+
+// ValidatePassword checks that a new password is strong enough to store.
+func ValidatePassword(p string) error {
+	if len(p) < MinPasswordLength || len(p) > MaxPasswordLength {
+		return ErrPasswordLength
+	}
+	var letter, digit bool
+	for _, r := range p {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return ErrPasswordWeak
+	}
+	return nil
+}
@@ -1,102 +1,288 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 	"os"
+	"ticketing/internal/database"
+	"ticketing/internal/keys"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
-func GenerateToken(id string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": id,
-	})
+// Token lifetimes, overridable with ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL
+// (Go durations such as "15m" or "720h").
+var (
+	AccessTokenTTL  = durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	RefreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+)
+
+// Lifetimes of the links mailed to users.
+const (
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+// ErrTokenRevoked is returned for access tokens on the deny-list.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Ignoring invalid %s %q", key, v)
+	}
+	return fallback
+}
+
+// tokenIssuer and tokenAudience identify tokens minted for this API.
+func tokenIssuer() string {
+	if iss := os.Getenv("JWT_ISSUER"); iss != "" {
+		return iss
+	}
+	return "ticketing"
+}
+
+func tokenAudience() string {
+	if aud := os.Getenv("JWT_AUDIENCE"); aud != "" {
+		return aud
+	}
+	return "ticketing-api"
+}
 
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	t, err := token.SignedString(secret)
+// Keys holds the keys access tokens are signed and verified with.
+var Keys *keys.Manager
+
+// InitKeys loads the signing keys, creating the first one if needed.
+func InitKeys(db database.Service) {
+	cfg, err := keys.ConfigFromEnv(AccessTokenTTL + time.Minute)
+	if err != nil {
+		log.Fatalf("Invalid signing key configuration: %v", err)
+	}
+	Keys, err = keys.NewManager(db, cfg)
+	if err != nil {
+		log.Fatalf("Could not init signing keys: %v", err)
+	}
+}
+
+// GenerateToken signs a short-lived access token carrying the user's ID,
+// platform role and email verification status with the current signing key.
+// mfaPending marks users the security policy requires 2FA of who have not
+// enabled it; they only keep attendee rights.
+func GenerateToken(user *database.User, mfaPending bool) (string, error) {
+	key, err := Keys.Current()
 	if err != nil {
-		log.Println("Signed string issue")
 		return "", err
 	}
 
-	return t, nil
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method(), jwt.MapClaims{
+		"user_id":        user.UserID,
+		"role":           user.Role,
+		"email_verified": user.EmailVerified(),
+		"mfa_pending":    mfaPending,
+		"sub":            user.UserID,
+		"iss":            tokenIssuer(),
+		"aud":            tokenAudience(),
+		"iat":            issuedAt(now),
+		"exp":            now.Add(AccessTokenTTL).Unix(),
+		"jti":            uuid.New().String(),
+	})
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Private)
 }
 
-func VerifyToken(tokenString string) (bool, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// Keyfunc resolves the public key a token was signed with from its kid
+// header. It rejects tokens whose alg does not match the key's.
+func Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+	key, err := Keys.Lookup(kid)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-
-	return token.Valid, nil
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public(), nil
 }
 
-// ExtractUserID extracts the user_id from the token if it's valid.
-func ExtractUserID(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// ValidateAccessClaims checks the claims of a token whose signature was
+// already verified: it must be unexpired, minted by this issuer for this
+// audience and not revoked.
+func ValidateAccessClaims(claims jwt.MapClaims) error {
+	now := time.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return errors.New("token has expired")
+	}
+	if !claims.VerifyIssuer(tokenIssuer(), true) || !claims.VerifyAudience(tokenAudience(), true) {
+		return errors.New("token was not issued for this service")
+	}
 
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("token has no jti")
+	}
+	revoked, err := IsTokenRevoked(jti)
 	if err != nil {
-		return "", err
+		// Fail closed: without the deny-list a revoked token could slip through
+		return fmt.Errorf("could not check token revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
 	}
 
-	// Check if the token is valid
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		log.Println(claims)
-		if userID, ok := claims["user_id"].(string); ok {
-			return userID, nil
-		}
-		return "", errors.New("user_id claim not found or invalid")
+	userID, _ := claims["user_id"].(string)
+	cutoff, err := userTokensRevokedAt(userID)
+	if err != nil {
+		return fmt.Errorf("could not check token revocation: %w", err)
 	}
+	if issuedBefore(claims, cutoff) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
 
-	return "", errors.New("invalid token")
+// issuedAt is the iat of a token minted at t, in seconds with millisecond
+// precision so that a revocation in the same second can tell tokens apart.
+func issuedAt(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
 }
 
-// ParseToken parses and validates the JWT token
-func ParseToken(tokenString string) (jwt.MapClaims, error) {
-	// Retrieve the secret key from the environment variable
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		return nil, errors.New("JWT_SECRET is not set in environment variables")
+// issuedBefore reports whether the token was issued before cutoff, a Unix
+// time in milliseconds. Tokens with a whole-second iat count as issued at
+// the start of that second.
+func issuedBefore(claims jwt.MapClaims, cutoff int64) bool {
+	iat, _ := claims["iat"].(float64)
+	return int64(math.Round(iat*1000)) < cutoff
+}
+
+func revokedKey(jti string) string {
+	return "jwt:revoked:" + jti
+}
+
+// RevokeToken puts an access token's jti on the deny-list until it expires.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
 	}
+	return Rdb.Set(context.Background(), revokedKey(jti), 1, ttl).Err()
+}
 
-	jwtSecret := []byte(secretKey)
+func userRevokedKey(userID string) string {
+	return "jwt:revoked-user:" + userID
+}
 
-	// Parse the token string
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Ensure that the signing method is HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
+// RevokeUserTokens invalidates every access token issued to the user before
+// at, e.g. after a password change. The marker outlives the longest-lived
+// access token and then expires.
+func RevokeUserTokens(userID string, at time.Time) error {
+	return Rdb.Set(context.Background(), userRevokedKey(userID), at.UnixMilli(), AccessTokenTTL).Err()
+}
+
+// userTokensRevokedAt returns the Unix time in milliseconds before which the
+// user's access tokens are revoked, or 0.
+func userTokensRevokedAt(userID string) (int64, error) {
+	cutoff, err := Rdb.Get(context.Background(), userRevokedKey(userID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return cutoff, err
+}
+
+// IsTokenRevoked reports whether an access token's jti is on the deny-list.
+func IsTokenRevoked(jti string) (bool, error) {
+	n, err := Rdb.Exists(context.Background(), revokedKey(jti)).Result()
+	return n > 0, err
+}
+
+// NewOpaqueToken returns a random URL-safe token and its hash. Only the hash
+// is stored so a database leak does not expose usable tokens.
+func NewOpaqueToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-	// Error handling for token parsing
+// ExtractUserID extracts the user_id from the token if it's valid.
+func ExtractUserID(tokenString string) (string, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	if userID, ok := claims["user_id"].(string); ok {
+		return userID, nil
+	}
+	return "", errors.New("user_id claim not found or invalid")
+}
+
+// ParseToken verifies the token's signature and claims and returns the claims.
+func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, Keyfunc)
 	if err != nil {
 		// Handle specific JWT parsing errors (invalid signature, expired token, etc.)
 		if ve, ok := err.(*jwt.ValidationError); ok {
-			// Depending on the error type, you can return different messages
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
 				return nil, errors.New("malformed token")
 			} else if ve.Errors&jwt.ValidationErrorExpired != 0 {
 				return nil, errors.New("token has expired")
 			} else if ve.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
 				return nil, errors.New("invalid signature")
-			} else {
-				return nil, errors.New("invalid token")
+			} else if ve.Inner != nil {
+				return nil, ve.Inner
 			}
+			return nil, errors.New("invalid token")
 		}
 		return nil, err
 	}
 
-	// If the token claims are valid, return them
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if err := ValidateAccessClaims(claims); err != nil {
+		return nil, err
 	}
+	return claims, nil
+}
+
+// apiKeyPrefix marks API keys so they are recognizable in configs and
+// secret scanners.
+const apiKeyPrefix = "tk_"
 
-	return nil, errors.New("invalid token")
+// NewAPIKey returns a new API key, the part of it shown in key lists and its
+// hash.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + token
+	return key, key[:len(apiKeyPrefix)+6], HashToken(key), nil
 }
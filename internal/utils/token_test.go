@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestIssuedBeforeRevocation(t *testing.T) {
+	revokedAt := time.Date(2024, 5, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	cutoff := revokedAt.UnixMilli()
+
+	tests := []struct {
+		name    string
+		iat     interface{}
+		revoked bool
+	}{
+		{"earlier in the same second", issuedAt(revokedAt.Add(-300 * time.Millisecond)), true},
+		{"a millisecond before", issuedAt(revokedAt.Add(-time.Millisecond)), true},
+		{"at the revocation", issuedAt(revokedAt), false},
+		{"later in the same second", issuedAt(revokedAt.Add(300 * time.Millisecond)), false},
+		{"the next second", issuedAt(revokedAt.Add(time.Second)), false},
+		{"whole-second iat in the same second", float64(revokedAt.Unix()), true},
+		{"whole-second iat the next second", float64(revokedAt.Unix() + 1), false},
+		{"no iat", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			if tt.iat != nil {
+				claims["iat"] = tt.iat
+			}
+			if got := issuedBefore(claims, cutoff); got != tt.revoked {
+				t.Errorf("issuedBefore = %v, want %v", got, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestIssuedBeforeWithoutRevocation(t *testing.T) {
+	claims := jwt.MapClaims{"iat": issuedAt(time.Now())}
+	if issuedBefore(claims, 0) {
+		t.Error("token revoked without a cutoff")
+	}
+}
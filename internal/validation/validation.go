@@ -0,0 +1,131 @@
+// Package validation checks request DTOs against their validate struct tags
+// and reports every failing field in a form clients can map back to inputs.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"ticketing/internal/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`           // JSON name of the field, dotted for nested fields
+	Rule    string `json:"rule"`            // Failed validate tag, e.g. required or min
+	Param   string `json:"param,omitempty"` // Tag parameter, e.g. 1 for min=1
+	Message string `json:"message"`
+}
+
+// Error is returned when a value fails validation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// phonePattern accepts E.164 numbers that fit the 15 character phone column.
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{6,13}$`)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by the names clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return utils.ValidatePassword(fl.Field().String()) == nil
+	})
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(fl.Field().String())
+	})
+	return v
+}
+
+// Struct validates s, which must be a struct or a pointer to one. It returns
+// an *Error listing every failing field, or nil.
+func Struct(s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return err
+	}
+
+	fields := make([]FieldError, len(failures))
+	for i, fe := range failures {
+		name := fieldName(fe)
+		fields[i] = FieldError{
+			Field:   name,
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(name, fe),
+		}
+	}
+	return &Error{Fields: fields}
+}
+
+// fieldName drops the struct type from the namespace, e.g.
+// "CreateEventDTO.venue.name" becomes "venue.name".
+func fieldName(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func message(name string, fe validator.FieldError) string {
+	isText := fe.Kind() == reflect.String
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", name)
+	case "required_with":
+		return fmt.Sprintf("%s is required when %s is set", name, strings.ToLower(fe.Param()))
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", name)
+	case "min":
+		if isText {
+			return fmt.Sprintf("%s must be at least %s characters", name, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", name, fe.Param())
+	case "max":
+		if isText {
+			return fmt.Sprintf("%s must be at most %s characters", name, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", name, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", name, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "password":
+		if err := utils.ValidatePassword(fmt.Sprint(fe.Value())); err != nil {
+			return err.Error()
+		}
+	case "phone":
+		return fmt.Sprintf("%s must be an international number such as +14155550123", name)
+	}
+	return fmt.Sprintf("%s failed the %s rule", name, fe.Tag())
+}